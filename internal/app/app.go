@@ -4,7 +4,9 @@ import (
 	"confam-api/internal/config"
 	"confam-api/internal/database"
 	"confam-api/internal/redis"
+	"confam-api/internal/repositories"
 	"confam-api/internal/server"
+	"confam-api/internal/services"
 	"confam-api/internal/validate"
 	"context"
 	"fmt"
@@ -18,6 +20,8 @@ type Application struct {
 	Config *config.Config
 	Redis  *redis.Client
 	Router *gin.Engine
+
+	stopWorkers context.CancelFunc
 }
 
 // New initializes the application and its dependencies
@@ -41,7 +45,12 @@ func New(ctx context.Context) (*Application, error) {
 	}
 	fmt.Println("Redis connected.")
 
+	if err := database.DeleteLegacySecretCache(ctx, rdb); err != nil {
+		return nil, err
+	}
+
 	router := server.NewRouter(rdb)
+	stopWorkers := startWorkers(ctx, rdb)
 
 	// if err := seeders.Seed(
 	// 	database.DB,
@@ -54,9 +63,30 @@ func New(ctx context.Context) (*Application, error) {
 		Config: cfg,
 		Redis:  rdb,
 		Router: router,
+
+		stopWorkers: stopWorkers,
 	}, nil
 }
 
+// startWorkers starts the background jobs. They stop when the returned
+// function is called.
+func startWorkers(ctx context.Context, rdb *redis.Client) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+
+	requestRepo := repositories.NewRequestRepository(database.DB)
+	appRepo := repositories.NewAppRepository(database.DB, rdb)
+	permissionRepo := repositories.NewCustomerPermissionRepository(database.DB)
+	webhookRepo := repositories.NewWebhookRepository(database.DB)
+	webhookService := services.NewWebhookService(appRepo, repositories.NewWebhookEndpointRepository(database.DB), webhookRepo)
+	lifecycle := services.NewRequestLifecycleService(requestRepo, webhookService)
+	permissions := services.NewPermissionService(permissionRepo, webhookService)
+	go services.NewRequestExpirySweeper(requestRepo, lifecycle).Run(ctx)
+	go services.NewPermissionExpirySweeper(permissionRepo, permissions).Run(ctx)
+	go services.NewWebhookDispatcher(webhookRepo, repositories.NewWebhookSecretRepository(database.DB)).Run(ctx)
+
+	return cancel
+}
+
 // Run starts the HTTP server
 func (a *Application) Run() error {
 	log.Printf("Server starting on port :%s", a.Config.Port)
@@ -64,6 +94,9 @@ func (a *Application) Run() error {
 }
 
 func (a *Application) Close() {
+	if a.stopWorkers != nil {
+		a.stopWorkers()
+	}
 	if a.Redis != nil {
 		if err := a.Redis.Close(); err != nil {
 			log.Printf("Error closing Redis: %v", err)
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSignAndParseToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	token, err := SignToken("company-id-1", PurposeEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatalf("SignToken() returned error: %v", err)
+	}
+
+	claims, err := ParseToken(token, PurposeEmailVerification)
+	if err != nil {
+		t.Fatalf("ParseToken() returned error: %v", err)
+	}
+	if claims.Subject != "company-id-1" {
+		t.Errorf("Subject = %v, want %v", claims.Subject, "company-id-1")
+	}
+}
+
+func TestParseToken_RejectsWrongPurpose(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	token, _ := SignToken("company-id-1", PurposeEmailVerification, time.Hour)
+	if _, err := ParseToken(token, "some_other_purpose"); err != ErrInvalidToken {
+		t.Errorf("ParseToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestParseToken_RejectsExpiredToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	token, _ := SignToken("company-id-1", PurposeEmailVerification, -time.Minute)
+	if _, err := ParseToken(token, PurposeEmailVerification); err != ErrInvalidToken {
+		t.Errorf("ParseToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestParseToken_RejectsWrongSecret(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	token, _ := SignToken("company-id-1", PurposeEmailVerification, time.Hour)
+
+	os.Setenv("JWT_SECRET", "another-secret")
+	defer os.Unsetenv("JWT_SECRET")
+	if _, err := ParseToken(token, PurposeEmailVerification); err != ErrInvalidToken {
+		t.Errorf("ParseToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestSignTokenKeepsSubSecondIssuedAt(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	before := time.Now().Truncate(time.Microsecond)
+	token, err := SignToken("user-1", PurposeAccess, time.Hour)
+	if err != nil {
+		t.Fatalf("SignToken() returned error: %v", err)
+	}
+	claims, err := ParseToken(token, PurposeAccess)
+	if err != nil {
+		t.Fatalf("ParseToken() returned error: %v", err)
+	}
+	// Parsing the float claim may lose a microsecond.
+	if claims.IssuedAt.Time.Before(before.Add(-time.Microsecond)) {
+		t.Errorf("IssuedAt = %v, want no earlier than %v", claims.IssuedAt.Time, before)
+	}
+}
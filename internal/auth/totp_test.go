@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 6238 Appendix B (SHA1), truncated to 6 digits.
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode() returned error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %v, want %v", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP_AllowsOnePeriodOfDrift(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() returned error: %v", err)
+	}
+	now := time.Now()
+	previous, _ := TOTPCode(secret, now.Add(-30*time.Second))
+	stale, _ := TOTPCode(secret, now.Add(-2*time.Minute))
+
+	if !ValidateTOTP(secret, previous, now) {
+		t.Error("ValidateTOTP() rejected the code from the previous period")
+	}
+	if stale != previous && ValidateTOTP(secret, stale, now) {
+		t.Error("ValidateTOTP() accepted a code from four periods ago")
+	}
+	if ValidateTOTP(secret, "12345", now) {
+		t.Error("ValidateTOTP() accepted a code of the wrong length")
+	}
+}
+
+func TestMatchTOTP_ReturnsTheCodesTimeStep(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() returned error: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	previous, _ := TOTPCode(secret, now.Add(-30*time.Second))
+
+	step, ok := MatchTOTP(secret, previous, now)
+	if !ok {
+		t.Fatal("MatchTOTP() rejected the previous period's code")
+	}
+	if want := now.Unix()/30 - 1; step != want {
+		t.Errorf("MatchTOTP() step = %d, want %d", step, want)
+	}
+}
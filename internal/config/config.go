@@ -2,14 +2,16 @@ package config
 
 import (
 	"confam-api/internal/database"
+	"errors"
 	"os"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DB   database.Config
-	Port string
+	DB        database.Config
+	Port      string
+	JWTSecret string
 }
 
 func Load() (*Config, error) {
@@ -23,13 +25,19 @@ func Load() (*Config, error) {
 		Database: os.Getenv("DB_DATABASE"),
 	}
 
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return nil, errors.New("JWT_SECRET must be set")
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
 	return &Config{
-		DB:   dbConfig,
-		Port: port,
+		DB:        dbConfig,
+		Port:      port,
+		JWTSecret: jwtSecret,
 	}, nil
 }
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	response "confam-api/internal/api"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	services "confam-api/internal/services"
+	structs "confam-api/internal/structs"
+	"confam-api/internal/validate"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// AllowController serves the steps of the customer-facing allow flow that
+// come after the request is fetched: the OTP and finishing the request.
+type AllowController struct {
+	otpService        services.IOTPService
+	completionService services.ICompletionService
+}
+
+func NewAllowController(otpService services.IOTPService, completionService services.ICompletionService) *AllowController {
+	return &AllowController{
+		otpService:        otpService,
+		completionService: completionService,
+	}
+}
+
+// SendOTP sends the customer an OTP for the KYC request. It can be called
+// again to resend one.
+func (ctrl *AllowController) SendOTP(c *gin.Context) {
+	var req structs.SendOTPRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				response.ValidationErrorResponse(c, validate.FormatValidationErrors(validationErrors))
+				return
+			}
+			response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+	}
+
+	delivery, err := ctrl.otpService.Send(c, c.Param("kyc_token"), models.OTPChannel(req.Channel))
+	if err != nil {
+		allowErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OTP sent successfully",
+		"results": delivery,
+		"error":   false,
+	})
+}
+
+// VerifyOTP checks the OTP and moves the KYC request on to processing.
+func (ctrl *AllowController) VerifyOTP(c *gin.Context) {
+	var req structs.VerifyOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			response.ValidationErrorResponse(c, validate.FormatValidationErrors(validationErrors))
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	request, err := ctrl.otpService.Verify(c, c.Param("kyc_token"), req.Code)
+	if err != nil {
+		allowErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OTP verified successfully",
+		"results": gin.H{
+			"status": request.Status,
+		},
+		"error": false,
+	})
+}
+
+// GrantConsent records the customer's consent to share their existing KYC
+// with the business. The redirect URL is only set when that completed the
+// request.
+func (ctrl *AllowController) GrantConsent(c *gin.Context) {
+	request, redirectURL, err := ctrl.completionService.GrantConsent(c, c.Param("kyc_token"))
+	if err != nil {
+		allowErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Consent granted successfully",
+		"results": gin.H{
+			"status":       request.Status,
+			"redirect_url": redirectURL,
+		},
+		"error": false,
+	})
+}
+
+// CompleteKyc completes the KYC request and returns the signed URL to send
+// the customer back to.
+func (ctrl *AllowController) CompleteKyc(c *gin.Context) {
+	request, redirectURL, err := ctrl.completionService.Complete(c, c.Param("kyc_token"))
+	if err != nil {
+		allowErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "KYC request completed successfully",
+		"results": gin.H{
+			"status":       request.Status,
+			"redirect_url": redirectURL,
+		},
+		"error": false,
+	})
+}
+
+// FailKyc fails the KYC request and returns the signed URL to send the
+// customer back to.
+func (ctrl *AllowController) FailKyc(c *gin.Context) {
+	var req structs.FailKycRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				response.ValidationErrorResponse(c, validate.FormatValidationErrors(validationErrors))
+				return
+			}
+			response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+	}
+
+	request, redirectURL, err := ctrl.completionService.Fail(c, c.Param("kyc_token"), req.Reason)
+	if err != nil {
+		allowErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "KYC request failed",
+		"results": gin.H{
+			"status":       request.Status,
+			"redirect_url": redirectURL,
+		},
+		"error": false,
+	})
+}
+
+func allowErrorResponse(c *gin.Context, err error) {
+	var status int
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = http.StatusNotFound
+		message = "KYC request not found"
+	case errors.Is(err, services.ErrKYCTokenExpired):
+		status = http.StatusGone
+		message = "KYC link has expired. Please request a new one."
+	case errors.Is(err, services.ErrKYCRequestCompleted),
+		errors.Is(err, services.ErrKYCRequestFailed):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrOTPNotExpected),
+		errors.Is(err, services.ErrKYCNotReady),
+		errors.Is(err, services.ErrCustomerNotConfirmed),
+		errors.Is(err, services.ErrKYCRequirementsNotMet),
+		errors.Is(err, services.ErrConsentRequired):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrIllegalTransition),
+		errors.Is(err, repositories.ErrRequestStatusChanged):
+		status = http.StatusConflict
+		message = "This KYC request has changed. Please reload and try again."
+	case errors.Is(err, services.ErrOTPChannelMissing):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrInvalidOTP),
+		errors.Is(err, services.ErrOTPExpired):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrTooManyOTPRequests),
+		errors.Is(err, services.ErrTooManyOTPAttempts):
+		status = http.StatusTooManyRequests
+	default:
+		log.Printf("Allow flow request failed: %v", err)
+		status = http.StatusInternalServerError
+		message = "Failed to process request"
+	}
+
+	c.JSON(status, gin.H{
+		"error":   true,
+		"message": message,
+	})
+}
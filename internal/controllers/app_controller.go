@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
+	services "confam-api/internal/services"
+	structs "confam-api/internal/structs"
+	"confam-api/internal/validate"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type AppController struct {
+	AppService services.IAppService
+}
+
+func NewAppController(appService services.IAppService) *AppController {
+	return &AppController{AppService: appService}
+}
+
+func (ac *AppController) CreateApp(c *gin.Context) {
+	var req structs.CreateAppRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	app, err := ac.AppService.CreateApp(c, user, req)
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "App created. Store the secret keys now; they will not be shown again.", app)
+}
+
+func (ac *AppController) ListApps(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	apps, err := ac.AppService.ListApps(c, user.CompanyID)
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Apps retrieved", apps)
+}
+
+func (ac *AppController) GetApp(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	app, err := ac.AppService.GetApp(c, user.CompanyID, c.Param("id"))
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "App retrieved", app)
+}
+
+func (ac *AppController) UpdateApp(c *gin.Context) {
+	var req structs.UpdateAppRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	app, err := ac.AppService.UpdateApp(c, user, c.Param("id"), req)
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "App updated", app)
+}
+
+func (ac *AppController) DeactivateApp(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	app, err := ac.AppService.DeactivateApp(c, user.CompanyID, c.Param("id"))
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "App deactivated", app)
+}
+
+func (ac *AppController) RotateKeys(c *gin.Context) {
+	var req structs.RotateKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	keys, err := ac.AppService.RotateKeys(c, user, c.Param("id"), req, c.ClientIP())
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Keys rotated. Store the new secret key now; it will not be shown again.", keys)
+}
+
+func (ac *AppController) ListKeyRotations(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	rotations, err := ac.AppService.ListKeyRotations(c, user.CompanyID, c.Param("id"))
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Key rotations retrieved", rotations)
+}
+
+func (ac *AppController) ListKeys(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	keys, err := ac.AppService.ListKeys(c, user, c.Param("id"))
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Keys retrieved", keys)
+}
+
+func (ac *AppController) RevokeKey(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := ac.AppService.RevokeKey(c, user, c.Param("id"), c.Param("key_id")); err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Key revoked", nil)
+}
+
+func (ac *AppController) ListWebhookSecrets(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	secrets, err := ac.AppService.ListWebhookSecrets(c, user.CompanyID, c.Param("id"))
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook secrets retrieved", secrets)
+}
+
+func (ac *AppController) RotateWebhookSecret(c *gin.Context) {
+	var req structs.RotateWebhookSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				errors := validate.FormatValidationErrors(validationErrors)
+				response.ValidationErrorResponse(c, errors)
+				return
+			}
+			response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	secret, err := ac.AppService.RotateWebhookSecret(c, user.CompanyID, c.Param("id"), req)
+	if err != nil {
+		appErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook secret rotated", secret)
+}
+
+func appErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAppNotFound),
+		errors.Is(err, services.ErrAPIKeyNotFound):
+		response.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, services.ErrInsufficientPermission):
+		response.ErrorResponse(c, http.StatusForbidden, err.Error(), nil)
+	default:
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+	}
+}
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
 	services "confam-api/internal/services"
 	structs "confam-api/internal/structs"
 	"confam-api/internal/validate"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -21,7 +23,7 @@ func NewAuthController(authService services.IAuthService) *AuthController {
 	return &AuthController{AuthService: authService}
 }
 
-func Register(c *gin.Context) {
+func (ac *AuthController) Register(c *gin.Context) {
 	var req structs.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
@@ -32,11 +34,51 @@ func Register(c *gin.Context) {
 		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Registration Successful",
+
+	company, err := ac.AuthService.Register(c, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasswordMismatch):
+			response.ValidationErrorResponse(c, map[string]string{"confirm_password": err.Error()})
+		case errors.Is(err, services.ErrEmailAlreadyTaken):
+			response.ErrorResponse(c, http.StatusConflict, err.Error(), nil)
+		default:
+			response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		}
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Registration Successful. Please check your email to verify your account.", gin.H{
+		"id":    company.ID,
+		"name":  company.Name,
+		"email": company.Email,
 	})
 }
 
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	var req structs.VerifyEmailRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	if err := ac.AuthService.VerifyEmail(c, req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			response.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Email verified successfully", nil)
+}
+
 func (ac *AuthController) Login(c *gin.Context) {
 	var req structs.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -49,17 +91,138 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	// Pass the request data to the service and receive the token.
-	token, err := ac.AuthService.Login(c, req)
+	// Pass the request data to the service and receive the tokens.
+	tokens, err := ac.AuthService.Login(c, req, clientInfo(c))
 	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			response.ErrorResponse(c, http.StatusForbidden, err.Error(), nil)
+			return
+		}
 		response.ErrorResponse(c, http.StatusUnauthorized, err.Error(), nil)
 		return
 	}
 
-	response.SuccessResponse(c, http.StatusOK, "Login Successful", gin.H{"token": token})
+	response.SuccessResponse(c, http.StatusOK, "Login Successful", tokens)
+}
+
+func (ac *AuthController) LoginTwoFactor(c *gin.Context) {
+	var req structs.LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	tokens, err := ac.AuthService.LoginTwoFactor(c, req, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMFAToken), errors.Is(err, services.ErrInvalidTwoFactorCode):
+			response.ErrorResponse(c, http.StatusUnauthorized, err.Error(), nil)
+		case errors.Is(err, services.ErrTooManyTwoFactorAttempts):
+			response.ErrorResponse(c, http.StatusTooManyRequests, err.Error(), nil)
+		default:
+			response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		}
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Login Successful", tokens)
 }
 
-func ForgotPassword(c *gin.Context) {
+func (ac *AuthController) RefreshToken(c *gin.Context) {
+	var req structs.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	tokens, err := ac.AuthService.RefreshToken(c, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			response.ErrorResponse(c, http.StatusUnauthorized, err.Error(), nil)
+			return
+		}
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Token refreshed successfully", tokens)
+}
+
+func (ac *AuthController) Logout(c *gin.Context) {
+	if err := ac.AuthService.Logout(c, middlewares.SessionIDFromContext(c)); err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+	response.SuccessResponse(c, http.StatusOK, "Logout Successful", nil)
+}
+
+func (ac *AuthController) ListSessions(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	sessions, err := ac.AuthService.ListSessions(c, user.ID)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	currentID := middlewares.SessionIDFromContext(c)
+	data := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		data = append(data, gin.H{
+			"id":           session.ID,
+			"ip":           session.IP,
+			"user_agent":   session.UserAgent,
+			"created_at":   session.CreatedAt,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == currentID,
+		})
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Sessions fetched successfully", data)
+}
+
+func (ac *AuthController) RevokeSession(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := ac.AuthService.RevokeSession(c, user.ID, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			response.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Session revoked successfully", nil)
+}
+
+func clientInfo(c *gin.Context) structs.ClientInfo {
+	return structs.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
 	var req structs.ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
@@ -71,17 +234,15 @@ func ForgotPassword(c *gin.Context) {
 		return
 	}
 
-	// if err := ac.AuthService.ForgotPassword(c, req); err != nil {
-	// 	response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
-	// 	return
-	// }
+	if err := ac.AuthService.ForgotPassword(c, req); err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Forgot Password Successful",
-	})
+	response.SuccessResponse(c, http.StatusOK, "If an account exists for this email, a password reset link has been sent.", nil)
 }
 
-func PasswordReset(c *gin.Context) {
+func (ac *AuthController) PasswordReset(c *gin.Context) {
 	var req structs.PasswordResetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
@@ -93,12 +254,22 @@ func PasswordReset(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Forgot Password Successful",
-	})
+	if err := ac.AuthService.PasswordReset(c, req); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasswordMismatch):
+			response.ValidationErrorResponse(c, map[string]string{"confirm_password": err.Error()})
+		case errors.Is(err, services.ErrInvalidResetToken):
+			response.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		default:
+			response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		}
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Password reset successful. Please log in with your new password.", nil)
 }
 
-func ChangePassword(c *gin.Context) {
+func (ac *AuthController) ChangePassword(c *gin.Context) {
 	var req structs.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		if validationErrors, ok := err.(validator.ValidationErrors); ok {
@@ -110,7 +281,23 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Change Password Successful",
-	})
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := ac.AuthService.ChangePassword(c, user, req); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasswordMismatch):
+			response.ValidationErrorResponse(c, map[string]string{"confirm_password": err.Error()})
+		case errors.Is(err, services.ErrIncorrectPassword):
+			response.ValidationErrorResponse(c, map[string]string{"old_password": err.Error()})
+		default:
+			response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		}
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Password changed successfully. Please log in again.", nil)
 }
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
 	models "confam-api/internal/models"
 	services "confam-api/internal/services"
 	structs "confam-api/internal/structs"
@@ -16,20 +17,26 @@ import (
 )
 
 type kycController struct {
-	kycService     services.IKycService
-	webhookService services.IWebhookService
+	kycService services.IKycService
 }
 
-func NewKycController(
-	kycService services.IKycService,
-	webhookService services.IWebhookService,
-) *kycController {
+func NewKycController(kycService services.IKycService) *kycController {
 	return &kycController{
-		kycService:     kycService,
-		webhookService: webhookService,
+		kycService: kycService,
 	}
 }
 
+// InitiateKyc starts a KYC request for the app. Once the request is finished
+// the customer is sent back to its redirect_url with the timestamp,
+// environment, reference, status and signature query parameters. To verify
+// the redirect, integrators compute the hex HMAC-SHA256 of
+//
+//	environment=<environment>&reference=<reference>&status=<status>&timestamp=<timestamp>
+//
+// with each value URL-encoded, keyed with the app's webhook secret, and
+// accept the redirect if it matches any signature parameter and the
+// timestamp is recent. There is one signature per unexpired webhook secret,
+// so redirects keep verifying while the secret is being rotated.
 func (ctrl *kycController) InitiateKyc(c *gin.Context) {
 	var req structs.KycRequestInput
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -42,11 +49,23 @@ func (ctrl *kycController) InitiateKyc(c *gin.Context) {
 		return
 	}
 
+	// Get app and environment from context (set by middleware)
+	app, ok := middlewares.AppFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required", "error": true})
+		return
+	}
+	environment, ok := middlewares.EnvironmentFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required", "error": true})
+		return
+	}
+
 	if req.Reference == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid reference, please retry with a unique reference", "error": true})
 		return
 	} else {
-		isUnique, _ := ctrl.kycService.IsReferenceUnique(c, req.Reference)
+		isUnique, _ := ctrl.kycService.IsReferenceUnique(c, *app, environment, req.Reference)
 		if !isUnique {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "Please provide a unique reference", "error": true})
 			return
@@ -58,6 +77,11 @@ func (ctrl *kycController) InitiateKyc(c *gin.Context) {
 		return
 	}
 
+	if req.AccessType == string(models.AccessTemporary) && req.AccessDays == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "access_days is required for temporary access.", "error": true})
+		return
+	}
+
 	if !ctrl.kycService.ValidateIdentityType(c, req.Customer.Identity.Type) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "Invalid identity type: " + req.Customer.Identity.Type + ". Must be one of BVN, NIN.",
@@ -66,55 +90,45 @@ func (ctrl *kycController) InitiateKyc(c *gin.Context) {
 		return
 	}
 
-	// Get app from context (set by middleware)
-	appVal, _ := c.Get("app")
-	app := appVal.(models.App)
-	webhookURL := ""
-	if app.WebhookURL != nil {
-		webhookURL = *app.WebhookURL
-	}
-
 	// Lookup or create customer
-	customer, err := ctrl.kycService.FindOrCreateCustomer(c, req.Customer)
+	customer, err := ctrl.kycService.FindOrCreateCustomer(c, environment, req.Customer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to process customer", "error": true})
 		return
 	}
 
 	// Create request in transaction
-	request, err := ctrl.kycService.CreateKYCRequest(c, app, req)
+	request, err := ctrl.kycService.CreateKYCRequest(c, *app, environment, customer, req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create request", "error": true})
 		return
 	}
 
-	// Send webhook (non-blocking, just log for now)
-	if webhookURL != "" {
-		data := map[string]interface{}{
-			"app":            app.ID,
-			"business":       app.CompanyID,
-			"id":             request.ID,
-			"status":         request.Status,
-			"reference":      request.Reference,
-			"created_at":     request.CreatedAt,
-			"kyc_level":      request.KYCLevel,
-			"bank_accounts":  request.BankAccountsRequested,
-			"is_blacklisted": false,
-			"meta":           map[string]interface{}{},
-		}
-		log.Println(data)
-		go ctrl.webhookService.SendWebhook(webhookURL, "kyc.initiation.requested", data)
+	// Verify the identity with the provider for the environment and identity
+	// type. The request is already stored, so a failure here is only logged:
+	// the app gets the request back with whatever status it reached.
+	if _, err := ctrl.kycService.VerifyIdentity(c, request, customer, req.Customer.Identity); err != nil {
+		log.Printf("Error verifying identity for request %s: %v", request.ID, err)
 	}
 
 	// Prepare response data
+	allowURL := ""
+	if request.AllowURL != nil {
+		allowURL = *request.AllowURL
+	}
 	data := gin.H{
 		"id":             request.KYCToken,
 		"customer":       customer.Token, // or request.KYCToken if no token
-		"allow_url":      request.AllowURL,
+		"allow_url":      allowURL,
+		"environment":    request.Environment,
 		"reference":      request.Reference,
 		"redirect_url":   request.RedirectURL,
 		"bank_accounts":  request.BankAccountsRequested,
+		"scopes":         request.RequestedScopes(),
+		"access_type":    request.AccessType,
+		"access_days":    request.AccessDays,
 		"kyc_level":      request.KYCLevel,
+		"status":         request.Status,
 		"is_blacklisted": false,
 	}
 
@@ -148,12 +162,15 @@ func (ctrl *kycController) FetchKycRequest(c *gin.Context) {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			status = http.StatusNotFound
 			message = "KYC request not found"
-		case err.Error() == "KYC request already completed":
+		case errors.Is(err, services.ErrKYCRequestCompleted):
 			status = http.StatusForbidden
 			message = "KYC request already completed."
-		case err.Error() == "KYC request failed":
+		case errors.Is(err, services.ErrKYCRequestFailed):
 			status = http.StatusForbidden
 			message = "KYC request failed. Please try again."
+		case errors.Is(err, services.ErrKYCTokenExpired):
+			status = http.StatusGone
+			message = "KYC link has expired. Please request a new one."
 		default:
 			status = http.StatusInternalServerError
 			message = "Failed to process request"
@@ -166,7 +183,13 @@ func (ctrl *kycController) FetchKycRequest(c *gin.Context) {
 		return
 	}
 
-	//customer["bank_accounts"] = []string{}
+	consentRequired, err := ctrl.kycService.ConsentRequired(c, request, customer)
+	if err != nil {
+		log.Printf("Error checking consent for request %s: %v", request.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Failed to process request"})
+		return
+	}
+
 	// Prepare the final response data based on the results from the service.
 	c.JSON(http.StatusOK, gin.H{
 		"message": "KYC request fetched successfully",
@@ -174,9 +197,159 @@ func (ctrl *kycController) FetchKycRequest(c *gin.Context) {
 			"redirect_url":            request.RedirectURL,
 			"kyc_level":               request.KYCLevel,
 			"bank_accounts_requested": request.BankAccountsRequested,
-			"customer":                customer,
+			"scopes":                  request.RequestedScopes(),
+			"access_type":             request.AccessType,
+			"access_days":             request.AccessDays,
+			"customer":                ctrl.kycService.AllowPageCustomer(request, customer),
 			"status":                  request.Status,
+			"requirements":            ctrl.kycService.EvaluateRequirements(request, customer),
+			"consent_required":        consentRequired,
+		},
+		"error": false,
+	})
+}
+
+// RenewKycToken issues a fresh KYC token and allow URL for one of the app's
+// unfinished requests, identified by its reference.
+func (ctrl *kycController) RenewKycToken(c *gin.Context) {
+	app, ok := middlewares.AppFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required", "error": true})
+		return
+	}
+	environment, ok := middlewares.EnvironmentFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required", "error": true})
+		return
+	}
+
+	request, err := ctrl.kycService.RenewKYCToken(c, *app, environment, c.Param("reference"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRequestNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"message": err.Error(), "error": true})
+		case errors.Is(err, services.ErrRequestFinished):
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error(), "error": true})
+		default:
+			log.Printf("Error renewing KYC token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to process request", "error": true})
+		}
+		return
+	}
+
+	allowURL := ""
+	if request.AllowURL != nil {
+		allowURL = *request.AllowURL
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "KYC token renewed successfully",
+		"results": gin.H{
+			"id":               request.KYCToken,
+			"reference":        request.Reference,
+			"allow_url":        allowURL,
+			"token_expires_at": request.TokenExpiresAt,
+			"environment":      request.Environment,
+			"status":           request.Status,
 		},
 		"error": false,
 	})
 }
+
+// GetRequest returns one of the app's requests by its reference, including
+// finished ones, so integrators can poll it from their servers. Customer data
+// is limited to the scopes the customer granted the app's company.
+func (ctrl *kycController) GetRequest(c *gin.Context) {
+	app, ok := middlewares.AppFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required", "error": true})
+		return
+	}
+	environment, ok := middlewares.EnvironmentFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Authentication required", "error": true})
+		return
+	}
+
+	details, err := ctrl.kycService.GetRequest(c, *app, environment, c.Param("reference"))
+	if err != nil {
+		if errors.Is(err, services.ErrRequestNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": err.Error(), "error": true})
+			return
+		}
+		log.Printf("Error fetching request %s: %v", c.Param("reference"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to process request", "error": true})
+		return
+	}
+
+	request := details.Request
+	history := make([]gin.H, 0, len(details.History))
+	for _, event := range details.History {
+		history = append(history, gin.H{
+			"from":        event.FromStatus,
+			"status":      event.ToStatus,
+			"reason":      event.Reason,
+			"occurred_at": event.CreatedAt,
+		})
+	}
+
+	var customer gin.H
+	if details.Customer != nil {
+		identities := []gin.H{}
+		for _, identity := range details.Customer.Identities {
+			if !identity.Verified {
+				continue
+			}
+			identities = append(identities, gin.H{
+				"type":        identity.Type,
+				"status":      identity.Status,
+				"verified_at": identity.VerifiedAt,
+			})
+		}
+		customer = gin.H{
+			"id":                 details.Customer.Token,
+			"status":             details.Customer.Status,
+			"kyc_level_achieved": details.Customer.KYCLevelAchieved,
+			"phone":              details.Customer.Phone,
+			"phone_verified_at":  details.Customer.PhoneVerifiedAt,
+			"address":            details.Customer.Address,
+			"identities":         identities,
+		}
+	}
+
+	var permission gin.H
+	if details.Permission != nil {
+		permission = gin.H{
+			"scopes":      details.Permission.Scopes,
+			"access_type": details.Permission.AccessType,
+			"granted_at":  details.Permission.GrantedAt,
+			"expires_at":  details.Permission.ExpiresAt,
+		}
+	}
+
+	results := gin.H{
+		"id":                      request.ID,
+		"reference":               request.Reference,
+		"environment":             request.Environment,
+		"status":                  request.Status,
+		"kyc_level":               request.KYCLevel,
+		"bank_accounts_requested": request.BankAccountsRequested,
+		"customer":                customer,
+		"permission":              permission,
+		"history":                 history,
+		"created_at":              request.CreatedAt,
+		"updated_at":              request.UpdatedAt,
+	}
+	if request.BankAccountsRequested && details.Permission != nil && details.Permission.Scopes.Has(models.ScopeBankAccounts) {
+		bankAccounts := details.BankAccounts
+		if bankAccounts == nil {
+			bankAccounts = []models.BankAccount{}
+		}
+		results["bank_accounts"] = bankAccounts
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Request fetched successfully",
+		"results": results,
+		"error":   false,
+	})
+}
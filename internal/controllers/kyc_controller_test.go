@@ -3,11 +3,13 @@ package controllers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"confam-api/internal/models"
+	"confam-api/internal/services"
 	"confam-api/internal/structs"
 
 	"github.com/gin-gonic/gin"
@@ -29,8 +31,8 @@ type MockKycService struct {
 	mock.Mock
 }
 
-func (m *MockKycService) IsReferenceUnique(ctx context.Context, ref string) (bool, error) {
-	args := m.Called(ctx, ref)
+func (m *MockKycService) IsReferenceUnique(ctx context.Context, app models.App, environment models.Environment, ref string) (bool, error) {
+	args := m.Called(ctx, app, environment, ref)
 	return args.Bool(0), args.Error(1)
 }
 
@@ -39,47 +41,79 @@ func (m *MockKycService) ValidateIdentityType(ctx context.Context, idType string
 	return args.Bool(0)
 }
 
-func (m *MockKycService) FindOrCreateCustomer(ctx context.Context, customer structs.CustomerInput) (*models.Customer, error) {
-	args := m.Called(ctx, customer)
+func (m *MockKycService) FindOrCreateCustomer(ctx context.Context, environment models.Environment, customer structs.CustomerInput) (*models.Customer, error) {
+	args := m.Called(ctx, environment, customer)
 	return args.Get(0).(*models.Customer), args.Error(1)
 }
 
-func (m *MockKycService) CreateKYCRequest(ctx context.Context, app models.App, req structs.KycRequestInput) (*models.Request, error) {
-	args := m.Called(ctx, app, req)
+func (m *MockKycService) CreateKYCRequest(ctx context.Context, app models.App, environment models.Environment, customer *models.Customer, req structs.KycRequestInput) (*models.Request, error) {
+	args := m.Called(ctx, app, environment, customer, req)
 	return args.Get(0).(*models.Request), args.Error(1)
 }
 
+func (m *MockKycService) RenewKYCToken(ctx context.Context, app models.App, environment models.Environment, reference string) (*models.Request, error) {
+	args := m.Called(ctx, app, environment, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Request), args.Error(1)
+}
+
+func (m *MockKycService) GetRequest(ctx context.Context, app models.App, environment models.Environment, reference string) (*services.RequestDetails, error) {
+	args := m.Called(ctx, app, environment, reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.RequestDetails), args.Error(1)
+}
+
+func (m *MockKycService) EvaluateRequirements(request *models.Request, customer *models.Customer) *services.KYCEvaluation {
+	args := m.Called(request, customer)
+	return args.Get(0).(*services.KYCEvaluation)
+}
+
+func (m *MockKycService) ConsentRequired(ctx context.Context, request *models.Request, customer *models.Customer) (bool, error) {
+	args := m.Called(ctx, request, customer)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockKycService) FetchKycRequest(ctx context.Context, kycToken string) (*models.Request, *models.Customer, error) {
 	args := m.Called(ctx, kycToken)
 	return args.Get(0).(*models.Request), args.Get(1).(*models.Customer), args.Error(2)
 }
 
-// MockWebhookService is a mock implementation of the IWebhookService interface.
-type MockWebhookService struct {
-	mock.Mock
+func (m *MockKycService) AllowPageCustomer(request *models.Request, customer *models.Customer) *services.AllowPageCustomer {
+	args := m.Called(request, customer)
+	return args.Get(0).(*services.AllowPageCustomer)
 }
 
-func (m *MockWebhookService) SendWebhook(webhookURL, event string, data any) {
-	m.Called(webhookURL, event, data)
+func (m *MockKycService) VerifyIdentity(ctx context.Context, request *models.Request, customer *models.Customer, input structs.Identity) (*services.IdentityCheck, error) {
+	args := m.Called(ctx, request, customer, input)
+	return args.Get(0).(*services.IdentityCheck), args.Error(1)
 }
 
 func TestInitiateKyc_Success(t *testing.T) {
 	mockKycService := new(MockKycService)
-	mockWebhookService := new(MockWebhookService)
-	ctrl := NewKycController(mockKycService, mockWebhookService)
+	ctrl := NewKycController(mockKycService)
 
 	// Mock service dependencies
-	mockKycService.On("IsReferenceUnique", mock.Anything, "unique-ref-123").Return(true, nil)
+	mockKycService.On("IsReferenceUnique", mock.Anything, mock.Anything, models.EnvironmentSandbox, "unique-ref-123").Return(true, nil)
 	mockKycService.On("ValidateIdentityType", mock.Anything, "BVN").Return(true)
-	mockKycService.On("FindOrCreateCustomer", mock.Anything, mock.Anything).Return(&models.Customer{Token: "customer-token"}, nil)
-	mockKycService.On("CreateKYCRequest", mock.Anything, mock.Anything, mock.Anything).Return(&models.Request{
+	mockKycService.On("FindOrCreateCustomer", mock.Anything, models.EnvironmentSandbox, mock.Anything).Return(&models.Customer{Token: "customer-token"}, nil)
+	mockKycService.On("CreateKYCRequest", mock.Anything, mock.Anything, models.EnvironmentSandbox, mock.Anything, mock.Anything).Return(&models.Request{
 		KYCToken:              "kyc-token-123",
 		RedirectURL:           "http://redirect.url",
 		Reference:             "unique-ref-123",
 		KYCLevel:              "LEVEL_1",
 		BankAccountsRequested: true,
+		AccessType:            models.AccessPermanent,
+		Environment:           models.EnvironmentSandbox,
+		Status:                models.RequestStatusInitiated,
+	}, nil)
+	mockKycService.On("VerifyIdentity", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&services.IdentityCheck{
+		Identity: &models.Identity{Type: models.IdentityTypeBVN, Status: models.IdentityStatusVerified},
+		Event:    "kyc.identity.verified",
 	}, nil)
-	mockWebhookService.On("SendWebhook", mock.Anything, mock.Anything, mock.Anything).Return()
 
 	// Create a request body
 	requestBody := []byte(`{
@@ -105,17 +139,121 @@ func TestInitiateKyc_Success(t *testing.T) {
 		WebhookURL: new(string),
 	}
 	*app.WebhookURL = "http://webhook.url"
-	c.Set("app", app)
+	c.Set("app", &app)
+	c.Set("environment", models.EnvironmentSandbox)
 
 	// Call the handler
 	ctrl.InitiateKyc(c)
 
 	// Assert the response
 	assert.Equal(t, http.StatusOK, w.Code)
-	expectedBody := `{"error":false,"message":"KYC process initiated successfully","results":{"allow_url":"","bank_accounts":true,"customer":"customer-token","id":"kyc-token-123","is_blacklisted":false,"kyc_level":"LEVEL_1","redirect_url":"http://redirect.url","reference":"unique-ref-123"}}`
+	expectedBody := `{"error":false,"message":"KYC process initiated successfully","results":{"access_days":null,"access_type":"permanent","allow_url":"","bank_accounts":true,"customer":"customer-token","environment":"sandbox","id":"kyc-token-123","is_blacklisted":false,"kyc_level":"LEVEL_1","redirect_url":"http://redirect.url","reference":"unique-ref-123","scopes":["identity","phone","address","bank_accounts"],"status":"initiated"}}`
 	assert.JSONEq(t, expectedBody, w.Body.String())
 
 	// Verify mock expectations
 	mockKycService.AssertExpectations(t)
-	mockWebhookService.AssertExpectations(t)
+}
+
+func TestFetchKycRequest_ExpiredToken(t *testing.T) {
+	mockKycService := new(MockKycService)
+	ctrl := NewKycController(mockKycService)
+
+	mockKycService.On("FetchKycRequest", mock.Anything, "expired-token").Return((*models.Request)(nil), (*models.Customer)(nil), services.ErrKYCTokenExpired)
+
+	c, w := createTestContext(http.MethodGet, "/api/v1/allow/expired-token", nil)
+	c.Params = gin.Params{{Key: "kyc_token", Value: "expired-token"}}
+
+	ctrl.FetchKycRequest(c)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.JSONEq(t, `{"error":true,"message":"KYC link has expired. Please request a new one."}`, w.Body.String())
+	mockKycService.AssertExpectations(t)
+}
+
+func TestFetchKycRequest_OnlyMaskedCustomer(t *testing.T) {
+	mockKycService := new(MockKycService)
+	ctrl := NewKycController(mockKycService)
+
+	request := &models.Request{ID: "request-1", KYCLevel: "tier_1", Status: models.RequestStatusInitiated}
+	phone := "+2348012345678"
+	customer := &models.Customer{
+		ID:         "customer-1",
+		Email:      "ada@example.com",
+		Phone:      &phone,
+		Identities: []models.Identity{{Type: models.IdentityTypeBVN, Value: "encrypted"}},
+	}
+	mockKycService.On("FetchKycRequest", mock.Anything, "kyc-token").Return(request, customer, nil)
+	mockKycService.On("ConsentRequired", mock.Anything, request, customer).Return(false, nil)
+	mockKycService.On("EvaluateRequirements", request, customer).Return(&services.KYCEvaluation{})
+	mockKycService.On("AllowPageCustomer", request, customer).Return(&services.AllowPageCustomer{Name: "Ada Lovelace", Email: "a**@example.com", Phone: "**********5678"})
+
+	c, w := createTestContext(http.MethodGet, "/api/v1/allow/kyc-token", nil)
+	c.Params = gin.Params{{Key: "kyc_token", Value: "kyc-token"}}
+
+	ctrl.FetchKycRequest(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Results struct {
+			Customer map[string]any `json:"customer"`
+		} `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{"name": "Ada Lovelace", "email": "a**@example.com", "phone": "**********5678"}, body.Results.Customer)
+	mockKycService.AssertExpectations(t)
+}
+
+func TestGetRequest_Completed(t *testing.T) {
+	mockKycService := new(MockKycService)
+	ctrl := NewKycController(mockKycService)
+
+	initiated := models.RequestStatusInitiated
+	mockKycService.On("GetRequest", mock.Anything, mock.Anything, models.EnvironmentLive, "ref-1").Return(&services.RequestDetails{
+		Request: &models.Request{
+			ID:          "request-1",
+			Reference:   "ref-1",
+			Environment: models.EnvironmentLive,
+			Status:      models.RequestStatusCompleted,
+			KYCLevel:    "tier_1",
+		},
+		History: []models.RequestEvent{
+			{ToStatus: models.RequestStatusInitiated},
+			{FromStatus: &initiated, ToStatus: models.RequestStatusCompleted},
+		},
+		Customer: &models.Customer{
+			Token:            "customer-token",
+			KYCLevelAchieved: "tier_1",
+			Identities: []models.Identity{
+				{Type: models.IdentityTypeBVN, Status: models.IdentityStatusVerified, Verified: true},
+				{Type: models.IdentityTypeNIN, Status: models.IdentityStatusRejected},
+			},
+		},
+	}, nil)
+
+	c, w := createTestContext(http.MethodGet, "/api/v1/requests/ref-1", nil)
+	c.Params = gin.Params{{Key: "reference", Value: "ref-1"}}
+	c.Set("app", &models.App{ID: "app-id-1", CompanyID: "company-id-1"})
+	c.Set("environment", models.EnvironmentLive)
+
+	ctrl.GetRequest(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Results struct {
+			Status   string `json:"status"`
+			History  []map[string]any
+			Customer struct {
+				KYCLevelAchieved string           `json:"kyc_level_achieved"`
+				Identities       []map[string]any `json:"identities"`
+			} `json:"customer"`
+			BankAccounts []any `json:"bank_accounts"`
+		} `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "completed", body.Results.Status)
+	assert.Len(t, body.Results.History, 2)
+	assert.Equal(t, "tier_1", body.Results.Customer.KYCLevelAchieved)
+	assert.Len(t, body.Results.Customer.Identities, 1)
+	assert.Nil(t, body.Results.BankAccounts)
+	mockKycService.AssertExpectations(t)
 }
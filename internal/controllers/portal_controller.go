@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
+	services "confam-api/internal/services"
+	structs "confam-api/internal/structs"
+	"confam-api/internal/validate"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// PortalController serves the customer portal, where customers see who ran
+// KYC against them and revoke access to their data.
+type PortalController struct {
+	PortalService services.ICustomerPortalService
+}
+
+func NewPortalController(portalService services.ICustomerPortalService) *PortalController {
+	return &PortalController{PortalService: portalService}
+}
+
+func (pc *PortalController) RequestLogin(c *gin.Context) {
+	var req structs.PortalLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	if err := pc.PortalService.RequestLogin(c, req.Email); err != nil {
+		portalErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "If we hold KYC for this email, a login link has been sent to it.", nil)
+}
+
+func (pc *PortalController) VerifyLogin(c *gin.Context) {
+	var req structs.PortalVerifyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	tokens, err := pc.PortalService.VerifyLogin(c, req)
+	if err != nil {
+		portalErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Login successful", tokens)
+}
+
+func (pc *PortalController) ListCompanies(c *gin.Context) {
+	customer, ok := middlewares.CustomerFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	companies, err := pc.PortalService.ListCompanies(c, customer)
+	if err != nil {
+		portalErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Businesses fetched successfully", companies)
+}
+
+func (pc *PortalController) RevokeAccess(c *gin.Context) {
+	customer, ok := middlewares.CustomerFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	permission, err := pc.PortalService.RevokeAccess(c, customer, c.Param("company_id"))
+	if err != nil {
+		portalErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Access revoked", permission)
+}
+
+func portalErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPortalLogin):
+		response.ErrorResponse(c, http.StatusUnauthorized, err.Error(), nil)
+	case errors.Is(err, services.ErrPermissionNotFound):
+		response.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, services.ErrTooManyPortalLogins),
+		errors.Is(err, services.ErrTooManyPortalAttempts):
+		response.ErrorResponse(c, http.StatusTooManyRequests, err.Error(), nil)
+	default:
+		log.Printf("Customer portal error: %v", err)
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to process request", nil)
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
+	services "confam-api/internal/services"
+	structs "confam-api/internal/structs"
+	"confam-api/internal/validate"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type TeamController struct {
+	TeamService services.ITeamService
+}
+
+func NewTeamController(teamService services.ITeamService) *TeamController {
+	return &TeamController{TeamService: teamService}
+}
+
+func (tc *TeamController) ListMembers(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	members, err := tc.TeamService.ListMembers(c, user.CompanyID)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Team members retrieved", members)
+}
+
+func (tc *TeamController) Invite(c *gin.Context) {
+	var req structs.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	invitation, err := tc.TeamService.Invite(c, user, req)
+	if err != nil {
+		teamErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Invitation sent", invitation)
+}
+
+func (tc *TeamController) ListInvitations(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	invitations, err := tc.TeamService.ListInvitations(c, user.CompanyID)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Pending invitations retrieved", invitations)
+}
+
+func (tc *TeamController) RevokeInvitation(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := tc.TeamService.RevokeInvitation(c, user.CompanyID, c.Param("id")); err != nil {
+		teamErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Invitation revoked", nil)
+}
+
+func (tc *TeamController) AcceptInvitation(c *gin.Context) {
+	var req structs.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, err := tc.TeamService.AcceptInvitation(c, req)
+	if err != nil {
+		teamErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Invitation accepted. You can now log in.", user)
+}
+
+func (tc *TeamController) UpdateMemberRole(c *gin.Context) {
+	var req structs.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	member, err := tc.TeamService.UpdateMemberRole(c, user, c.Param("id"), req)
+	if err != nil {
+		teamErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Team member updated", member)
+}
+
+func (tc *TeamController) RemoveMember(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := tc.TeamService.RemoveMember(c, user, c.Param("id")); err != nil {
+		teamErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Team member removed", nil)
+}
+
+func teamErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPasswordMismatch):
+		response.ValidationErrorResponse(c, map[string]string{"confirm_password": err.Error()})
+	case errors.Is(err, services.ErrInvalidInvitation):
+		response.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, services.ErrInvitationNotFound),
+		errors.Is(err, services.ErrMemberNotFound):
+		response.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, services.ErrEmailAlreadyTaken):
+		response.ErrorResponse(c, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, services.ErrCannotModifyOwner),
+		errors.Is(err, services.ErrCannotModifySelf):
+		response.ErrorResponse(c, http.StatusForbidden, err.Error(), nil)
+	default:
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+	}
+}
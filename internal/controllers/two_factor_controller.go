@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
+	services "confam-api/internal/services"
+	structs "confam-api/internal/structs"
+	"confam-api/internal/validate"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type TwoFactorController struct {
+	TwoFactorService services.ITwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService services.ITwoFactorService) *TwoFactorController {
+	return &TwoFactorController{TwoFactorService: twoFactorService}
+}
+
+func (tc *TwoFactorController) Setup(c *gin.Context) {
+	var req structs.TwoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	setup, err := tc.TwoFactorService.Setup(c, user, req)
+	if err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Scan the QR code with your authenticator app, then confirm a code to enable two-factor authentication.", setup)
+}
+
+func (tc *TwoFactorController) Enable(c *gin.Context) {
+	var req structs.EnableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	codes, err := tc.TwoFactorService.Enable(c, user, req)
+	if err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Two-factor authentication enabled. Store these recovery codes somewhere safe; they will not be shown again.", gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+func (tc *TwoFactorController) Disable(c *gin.Context) {
+	var req structs.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := tc.TwoFactorService.Disable(c, user, req); err != nil {
+		twoFactorErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+func twoFactorErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrIncorrectPassword):
+		response.ValidationErrorResponse(c, map[string]string{"password": err.Error()})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		response.ValidationErrorResponse(c, map[string]string{"code": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorNotSetUp):
+		response.ErrorResponse(c, http.StatusConflict, err.Error(), nil)
+	default:
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+	}
+}
@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	response "confam-api/internal/api"
+	"confam-api/internal/middlewares"
+	services "confam-api/internal/services"
+	structs "confam-api/internal/structs"
+	"confam-api/internal/validate"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type WebhookController struct {
+	deliveryService services.IWebhookDeliveryService
+	endpointService services.IWebhookEndpointService
+}
+
+func NewWebhookController(deliveryService services.IWebhookDeliveryService, endpointService services.IWebhookEndpointService) *WebhookController {
+	return &WebhookController{
+		deliveryService: deliveryService,
+		endpointService: endpointService,
+	}
+}
+
+// ListDeliveries lists the webhooks sent to an app, filtered by event,
+// status, request reference and creation time.
+func (wc *WebhookController) ListDeliveries(c *gin.Context) {
+	var req structs.ListWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	page, err := wc.deliveryService.List(c, user.CompanyID, c.Param("id"), req)
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook deliveries retrieved", page)
+}
+
+// GetDelivery returns a webhook with the request and response of every
+// attempt made at delivering it.
+func (wc *WebhookController) GetDelivery(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	delivery, err := wc.deliveryService.Get(c, user.CompanyID, c.Param("id"), c.Param("webhook_id"))
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook delivery retrieved", delivery)
+}
+
+func (wc *WebhookController) ReplayDelivery(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := wc.deliveryService.Replay(c, user.CompanyID, c.Param("id"), c.Param("webhook_id")); err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusAccepted, "Webhook queued for delivery", nil)
+}
+
+// ReplayFailed replays every failed webhook of the app created in a window.
+func (wc *WebhookController) ReplayFailed(c *gin.Context) {
+	var req structs.ReplayWebhooksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	replayed, err := wc.deliveryService.ReplayFailed(c, user.CompanyID, c.Param("id"), req)
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusAccepted, "Failed webhooks queued for delivery", structs.ReplayedWebhooks{Replayed: replayed})
+}
+
+func (wc *WebhookController) ListEndpoints(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	endpoints, err := wc.endpointService.List(c, user.CompanyID, c.Param("id"))
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook endpoints retrieved", endpoints)
+}
+
+func (wc *WebhookController) CreateEndpoint(c *gin.Context) {
+	var req structs.CreateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	endpoint, err := wc.endpointService.Create(c, user.CompanyID, c.Param("id"), req)
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Webhook endpoint created. Store the secret now; it will not be shown again.", endpoint)
+}
+
+func (wc *WebhookController) GetEndpoint(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	endpoint, err := wc.endpointService.Get(c, user.CompanyID, c.Param("id"), c.Param("endpoint_id"))
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook endpoint retrieved", endpoint)
+}
+
+func (wc *WebhookController) UpdateEndpoint(c *gin.Context) {
+	var req structs.UpdateWebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if validationErrors, ok := err.(validator.ValidationErrors); ok {
+			errors := validate.FormatValidationErrors(validationErrors)
+			response.ValidationErrorResponse(c, errors)
+			return
+		}
+		response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	endpoint, err := wc.endpointService.Update(c, user.CompanyID, c.Param("id"), c.Param("endpoint_id"), req)
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook endpoint updated", endpoint)
+}
+
+func (wc *WebhookController) DeleteEndpoint(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	if err := wc.endpointService.Delete(c, user.CompanyID, c.Param("id"), c.Param("endpoint_id")); err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook endpoint deleted", nil)
+}
+
+// SendTestEvent queues a test event for the endpoint. The webhook returned
+// can be followed in the delivery log.
+func (wc *WebhookController) SendTestEvent(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	webhook, err := wc.endpointService.SendTestEvent(c, user.CompanyID, c.Param("id"), c.Param("endpoint_id"))
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusAccepted, "Test event queued for delivery", webhook)
+}
+
+func (wc *WebhookController) ListEndpointSecrets(c *gin.Context) {
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	secrets, err := wc.endpointService.ListSecrets(c, user.CompanyID, c.Param("id"), c.Param("endpoint_id"))
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook secrets retrieved", secrets)
+}
+
+func (wc *WebhookController) RotateEndpointSecret(c *gin.Context) {
+	var req structs.RotateWebhookSecretRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if validationErrors, ok := err.(validator.ValidationErrors); ok {
+				errors := validate.FormatValidationErrors(validationErrors)
+				response.ValidationErrorResponse(c, errors)
+				return
+			}
+			response.ErrorResponse(c, http.StatusBadRequest, "Bad Request", nil)
+			return
+		}
+	}
+
+	user, ok := middlewares.UserFromContext(c)
+	if !ok {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Unauthenticated", nil)
+		return
+	}
+
+	secret, err := wc.endpointService.RotateSecret(c, user.CompanyID, c.Param("id"), c.Param("endpoint_id"), req)
+	if err != nil {
+		webhookErrorResponse(c, err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook secret rotated", secret)
+}
+
+func webhookErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAppNotFound),
+		errors.Is(err, services.ErrWebhookNotFound),
+		errors.Is(err, services.ErrWebhookEndpointNotFound):
+		response.ErrorResponse(c, http.StatusNotFound, err.Error(), nil)
+	case errors.Is(err, services.ErrWebhookPending):
+		response.ErrorResponse(c, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, services.ErrInvalidReplayWindow),
+		errors.Is(err, services.ErrUnknownWebhookEvent):
+		response.ErrorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	default:
+		response.ErrorResponse(c, http.StatusInternalServerError, err.Error(), nil)
+	}
+}
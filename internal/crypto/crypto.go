@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -35,6 +36,20 @@ func HashSHA256(value string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// HashAPIKey computes the SHA256 hash of an API key. Unlike HashSHA256 the key
+// is hashed as is, since API keys are case-sensitive.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// SignHMACSHA256 returns the hex encoded HMAC-SHA256 of the message under the key.
+func SignHMACSHA256(key, message string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(message))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // GenerateAPIKey creates a cryptographically secure, base64-encoded key with a prefix.
 func GenerateAPIKey(prefix string) (string, error) {
 	b := make([]byte, 32)
@@ -1,8 +1,12 @@
 package database
 
 import (
+	crypto "confam-api/internal/crypto"
 	"confam-api/internal/models"
+	client "confam-api/internal/redis"
+	"context"
 	"fmt"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -41,14 +45,236 @@ func Connect(c Config) error {
 }
 
 func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := hashPlainAPIKeys(db); err != nil {
+		return err
+	}
+	if err := dropLegacyUniqueIndexes(db); err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(
 		&models.Request{},
 		&models.Company{},
 		&models.App{},
 		&models.Customer{},
 		&models.Identity{},
 		&models.NextOfKin{},
+		&models.CompanyUser{},
+		&models.Invitation{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.KeyRotation{},
+		&models.RequestEvent{},
 		//&models.KycVerification{},
-		//&models.BankAccount{},
-	)
+		&models.BankAccount{},
+		&models.CustomerPermission{},
+		&models.Webhook{},
+		&models.WebhookSecret{},
+		&models.WebhookAttempt{},
+		&models.WebhookEndpoint{},
+	); err != nil {
+		return err
+	}
+	if err := backfillCompanyOwners(db); err != nil {
+		return err
+	}
+	if err := backfillAppKeys(db); err != nil {
+		return err
+	}
+	return backfillWebhookSecrets(db)
+}
+
+// hashPlainAPIKeys replaces the plain-text key column of api_keys with its
+// SHA256 hash, so existing keys keep working once only hashes are stored.
+func hashPlainAPIKeys(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable("api_keys") || !migrator.HasColumn("api_keys", "key") {
+		return nil
+	}
+	if !migrator.HasColumn("api_keys", "key_hash") {
+		if err := db.Exec("ALTER TABLE `api_keys` ADD COLUMN `key_hash` char(64) NULL").Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Exec("UPDATE `api_keys` SET `key_hash` = SHA2(`key`, 256) WHERE `key_hash` IS NULL").Error; err != nil {
+		return err
+	}
+	return db.Exec("ALTER TABLE `api_keys` DROP COLUMN `key`").Error
+}
+
+// dropLegacyUniqueIndexes drops the single-column unique indexes that made
+// customer emails and request references unique across environments. They
+// were replaced by the composite idx_customers_email_hash and
+// idx_requests_reference, which AutoMigrate does not drop them for. Depending
+// on the GORM version that created them they are named after the column or
+// uni_<table>_<column>.
+func dropLegacyUniqueIndexes(db *gorm.DB) error {
+	legacy := map[string][]string{
+		"customers": {"email_hash", "uni_customers_email_hash"},
+		"requests":  {"reference", "uni_requests_reference"},
+	}
+	migrator := db.Migrator()
+	for table, indexes := range legacy {
+		for _, index := range indexes {
+			if !migrator.HasIndex(table, index) {
+				continue
+			}
+			if err := migrator.DropIndex(table, index); err != nil {
+				return fmt.Errorf("failed to drop index %s of %s: %w", index, table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// backfillAppKeys stores the secrets of apps created before keys were issued
+// as APIKey records, which were derived from the public keys and SECRET_SALT.
+// Once stored, those keys no longer depend on SECRET_SALT. Each key is
+// backfilled on its own: a legacy secret already rotated out is stored with
+// the expiry of its grace period, unless that has passed.
+func backfillAppKeys(db *gorm.DB) error {
+	var apps []models.App
+	if err := db.Find(&apps).Error; err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		for _, keyType := range []string{models.APIKeyTypeTest, models.APIKeyTypeLive} {
+			if err := backfillAppKey(db, &app, keyType); err != nil {
+				return fmt.Errorf("failed to store %s key for app %s: %w", keyType, app.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// backfillAppKey stores the legacy secret of keyType of the app, if it had one.
+//
+// Apps created with APIKey records got one key of each type at creation and
+// one more per rotation, so they have more keys of a type than rotations of
+// it, revoked ones included. Legacy apps only got keys from rotations. Their
+// legacy secret belongs to the public key they were created with: the old
+// public key of their first rotation, or the current one if never rotated.
+func backfillAppKey(db *gorm.DB, app *models.App, keyType string) error {
+	var rotations []models.KeyRotation
+	err := db.
+		Where("app_id = ? AND environment = ?", app.ID, keyType).
+		Order("created_at").
+		Find(&rotations).Error
+	if err != nil {
+		return err
+	}
+	var keys int64
+	err = db.Unscoped().
+		Model(&models.APIKey{}).
+		Where("app_id = ? AND type = ?", app.ID, keyType).
+		Count(&keys).Error
+	if err != nil {
+		return err
+	}
+
+	publicKey := app.PublicKey(keyType)
+	var expiresAt *time.Time
+	if len(rotations) > 0 {
+		publicKey = rotations[0].OldPublicKey
+		expiresAt = &rotations[0].PreviousKeyExpiresAt
+	}
+	key := models.NewAPIKey(models.LegacySecretKey(keyType, publicKey), keyType)
+	key.AppID = app.ID
+	key.ExpiresAt = expiresAt
+
+	var stored int64
+	err = db.Unscoped().
+		Model(&models.APIKey{}).
+		Where("key_hash = ?", key.KeyHash).
+		Count(&stored).Error
+	if err != nil {
+		return err
+	}
+	if stored > 0 || keys > int64(len(rotations)) || key.IsExpired(time.Now()) {
+		return nil
+	}
+	return db.Create(key).Error
+}
+
+// DeleteLegacySecretCache deletes the secret:<key> entries that mapped plain
+// text secret keys to app IDs before only their hashes were stored. Run it
+// once the database has been migrated, so every key has its APIKey record.
+func DeleteLegacySecretCache(ctx context.Context, rdb *client.Client) error {
+	iter := rdb.Scan(ctx, 0, "secret:*", 100).Iterator()
+	batch := make([]string, 0, 100)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := rdb.Del(ctx, batch...).Err(); err != nil {
+				return fmt.Errorf("failed to delete legacy secrets: %w", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan legacy secrets: %w", err)
+	}
+	if len(batch) > 0 {
+		if err := rdb.Del(ctx, batch...).Err(); err != nil {
+			return fmt.Errorf("failed to delete legacy secrets: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillWebhookSecrets issues a webhook secret to every app created before
+// webhooks were signed. Integrators can read it from the dashboard.
+func backfillWebhookSecrets(db *gorm.DB) error {
+	var apps []models.App
+	err := db.
+		Where("NOT EXISTS (SELECT 1 FROM webhook_secrets WHERE webhook_secrets.app_id = apps.id AND webhook_secrets.endpoint_id IS NULL)").
+		Find(&apps).Error
+	if err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		plain, err := crypto.GenerateAPIKey("whsec")
+		if err != nil {
+			return err
+		}
+		secret, err := models.NewWebhookSecret(plain)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt webhook secret for app %s: %w", app.ID, err)
+		}
+		secret.AppID = app.ID
+		if err := db.Create(secret).Error; err != nil {
+			return fmt.Errorf("failed to store webhook secret for app %s: %w", app.ID, err)
+		}
+	}
+	return nil
+}
+
+// backfillCompanyOwners gives every company that still logs in with the
+// credentials stored on the companies table an owner account to log in with.
+func backfillCompanyOwners(db *gorm.DB) error {
+	var companies []models.Company
+	err := db.
+		Where("password IS NOT NULL AND password <> ''").
+		Where("NOT EXISTS (SELECT 1 FROM company_users WHERE company_users.company_id = companies.id)").
+		Where("NOT EXISTS (SELECT 1 FROM company_users WHERE company_users.email = companies.email)").
+		Find(&companies).Error
+	if err != nil {
+		return err
+	}
+
+	for _, company := range companies {
+		owner := models.CompanyUser{
+			CompanyID:       company.ID,
+			Email:           company.Email,
+			Password:        company.Password,
+			Role:            models.RoleOwner,
+			EmailVerifiedAt: company.EmailVerifiedAt,
+		}
+		if err := db.Create(&owner).Error; err != nil {
+			return fmt.Errorf("failed to create owner for company %s: %w", company.ID, err)
+		}
+	}
+	return nil
 }
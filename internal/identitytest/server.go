@@ -0,0 +1,138 @@
+// Package identitytest provides a fake identity provider for tests and local
+// development. It speaks the API services.HTTPIdentityProvider expects.
+package identitytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is an identity the fake provider knows about.
+type Record struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	DateOfBirth string `json:"date_of_birth"`
+	Phone       string `json:"phone"`
+	Photo       string `json:"photo"`
+	Reference   string `json:"reference"`
+}
+
+// Server is a fake identity provider. Numbers that were not added with Add
+// are answered with 404; numbers added with Review are answered with 202.
+type Server struct {
+	*httptest.Server
+
+	// APIKey, when set, is required as a bearer token.
+	APIKey string
+
+	mu       sync.Mutex
+	records  map[string]Record
+	review   map[string]bool
+	failures int
+	delay    time.Duration
+	requests int
+}
+
+// NewServer starts a fake identity provider. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		records: make(map[string]Record),
+		review:  make(map[string]bool),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func key(identityType, number string) string {
+	return strings.ToUpper(identityType) + ":" + number
+}
+
+// Add makes the provider return record for the identity.
+func (s *Server) Add(identityType, number string, record Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key(identityType, number)] = record
+}
+
+// Review makes the provider queue the identity for manual review.
+func (s *Server) Review(identityType, number string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.review[key(identityType, number)] = true
+}
+
+// FailNext makes the next n lookups respond with 503.
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// Delay makes every lookup wait d before responding.
+func (s *Server) Delay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// Requests returns how many lookups the provider has received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/identities/lookup" {
+		http.NotFound(w, r)
+		return
+	}
+	if s.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.APIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Type   string `json:"type"`
+		Number string `json:"number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	delay := s.delay
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	record, found := s.records[key(input.Type, input.Number)]
+	review := s.review[key(input.Type, input.Number)]
+	s.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case fail:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case review:
+		w.WriteHeader(http.StatusAccepted)
+	case !found:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(record)
+	}
+}
@@ -2,20 +2,26 @@ package middlewares
 
 import (
 	models "confam-api/internal/models"
-	client "confam-api/internal/redis"
+	repositories "confam-api/internal/repositories"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-func AuthenticateAppBySecretKey(
-	db *gorm.DB,
-	redisClient *client.Client,
-) gin.HandlerFunc {
+const (
+	appContextKey         = "app"
+	environmentContextKey = "environment"
+)
+
+// AuthenticateAppBySecretKey authenticates requests by the secret key in the
+// x-api-key header. It stores the app, including its company, in the context
+// as a *models.App, along with the environment of the key. Live keys are
+// rejected while the app is in SANDBOX mode.
+func AuthenticateAppBySecretKey(apiKeyRepo repositories.IAPIKeyRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
 		requestMethod := c.Request.Method
@@ -26,6 +32,7 @@ func AuthenticateAppBySecretKey(
 		rawToken := c.GetHeader("x-api-key")
 		token := ""
 		if rawToken != "" {
+			// If multiple headers, use the first
 			if strings.Contains(rawToken, ",") {
 				tokens := strings.Split(rawToken, ",")
 				token = strings.TrimSpace(tokens[0])
@@ -42,80 +49,69 @@ func AuthenticateAppBySecretKey(
 		}
 		log.Printf("Attempting authentication with key: %s... (masked)", maskToken(token))
 
-		// Lookup appId in Redis
-		appId, err := redisClient.Get(c, "secret:"+token).Result()
-		if err != nil || appId == "" {
-			log.Printf("Authentication failed: Invalid API key - %s...", maskToken(token))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid API key"})
-			c.Abort()
-			return
-		}
-
-		// Lookup App in DB, including Company
-		var app models.App
-		if err := db.Preload("Company").First(&app, "id = ?", appId).Error; err != nil {
-			log.Printf("Authentication failed: App not found for ID: %s", appId)
-			c.JSON(http.StatusNotFound, gin.H{"error": true, "message": "App not found"})
+		key, err := apiKeyRepo.FindBySecret(c, token)
+		if err != nil {
+			if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+				log.Printf("Authentication failed: Invalid API key - %s...", maskToken(token))
+				c.JSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid API key"})
+				c.Abort()
+				return
+			}
+			log.Printf("Authentication failed: could not look up API key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Failed to process request"})
 			c.Abort()
 			return
 		}
-		// Store app in context for downstream handlers
-		c.Set("app", app)
-
-		responseTime := time.Since(startTime).Milliseconds()
-		log.Printf("Authentication successful for App ID: %s (Company: %s). Request processed in %dms.", appId, app.Company.Name, responseTime)
-		c.Next()
-	}
-}
-
-func AuthAppBySecretKey(db *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		startTime := time.Now()
-		requestMethod := c.Request.Method
-		requestUrl := c.Request.RequestURI
-		clientIp := c.ClientIP()
-		log.Printf("Request received: %s %s from %s", requestMethod, requestUrl, clientIp)
-
-		rawToken := c.GetHeader("x-api-key")
-		token := ""
-		if rawToken != "" {
-			// If multiple headers, use the first
-			if strings.Contains(rawToken, ",") {
-				tokens := strings.Split(rawToken, ",")
-				token = strings.TrimSpace(tokens[0])
-				log.Printf("Multiple API keys found in 'x-api-key' header, using the first one.")
-			} else {
-				token = rawToken
-			}
-		}
-		if token == "" {
-			log.Printf("Authentication failed: Missing API key.")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Authentication failed: Missing API key."})
+		app := key.App
+		if !app.IsActive() {
+			log.Printf("Authentication failed: App %s is inactive", app.ID)
+			c.JSON(http.StatusForbidden, gin.H{"error": true, "message": "App is inactive"})
 			c.Abort()
 			return
 		}
-		log.Printf("Attempting authentication with key: %s... (masked)", maskToken(token))
-
-		var apiKey models.APIKey
-		var app *models.App
-		if err := db.Preload("App.Company").First(&apiKey, "key = ?", token).Error; err != nil {
-			log.Printf("Authentication failed: Invalid API key - %s...", maskToken(token))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid API key"})
+		environment := key.Environment()
+		if environment == models.EnvironmentLive && app.Mode != string(models.AppModeLive) {
+			log.Printf("Authentication failed: Live key used for App %s in %s mode", app.ID, app.Mode)
+			c.JSON(http.StatusForbidden, gin.H{"error": true, "message": "App is not live. Use your test secret key or switch the app to LIVE mode."})
 			c.Abort()
 			return
-		} else {
-			app = apiKey.App
 		}
 
 		// Store app in context for downstream handlers
-		c.Set("app", app)
+		c.Set(appContextKey, app)
+		c.Set(environmentContextKey, environment)
 
+		companyName := ""
+		if app.Company != nil {
+			companyName = app.Company.Name
+		}
 		responseTime := time.Since(startTime).Milliseconds()
-		log.Printf("Authentication successful for App ID: %s (Company: %s). Request processed in %dms.", app.ID, app.Company.Name, responseTime)
+		log.Printf("Authentication successful for App ID: %s (Company: %s). Request processed in %dms.", app.ID, companyName, responseTime)
 		c.Next()
 	}
 }
 
+// AppFromContext returns the app stored by AuthenticateAppBySecretKey.
+func AppFromContext(c *gin.Context) (*models.App, bool) {
+	value, exists := c.Get(appContextKey)
+	if !exists {
+		return nil, false
+	}
+	app, ok := value.(*models.App)
+	return app, ok && app != nil
+}
+
+// EnvironmentFromContext returns the environment of the key the request was
+// authenticated with by AuthenticateAppBySecretKey.
+func EnvironmentFromContext(c *gin.Context) (models.Environment, bool) {
+	value, exists := c.Get(environmentContextKey)
+	if !exists {
+		return "", false
+	}
+	environment, ok := value.(models.Environment)
+	return environment, ok
+}
+
 // Helper to mask token for logging
 func maskToken(token string) string {
 	if len(token) > 6 {
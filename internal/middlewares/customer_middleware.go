@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	auth "confam-api/internal/auth"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const customerContextKey = "customer"
+
+// AuthenticateCustomer validates the Bearer token issued by the customer
+// portal login and stores the authenticated *models.Customer in the context.
+func AuthenticateCustomer(customerRepo repositories.ICustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		tokenString = strings.TrimSpace(tokenString)
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Authentication failed: Missing bearer token."})
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString, auth.PurposeCustomerPortal)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+		customer, err := customerRepo.FindByID(c, claims.Subject)
+		if err != nil || customer.Environment != models.EnvironmentLive {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+
+		c.Set(customerContextKey, customer)
+		c.Next()
+	}
+}
+
+// CustomerFromContext returns the customer stored by AuthenticateCustomer.
+func CustomerFromContext(c *gin.Context) (*models.Customer, bool) {
+	value, exists := c.Get(customerContextKey)
+	if !exists {
+		return nil, false
+	}
+	customer, ok := value.(*models.Customer)
+	return customer, ok
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	auth "confam-api/internal/auth"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	companyContextKey = "company"
+	userContextKey    = "user"
+	sessionContextKey = "session_id"
+)
+
+// AuthenticateCompany validates the Bearer access token issued by AuthService.Login,
+// checks that its session has not been revoked, and stores the authenticated
+// *models.CompanyUser and its *models.Company in the context.
+func AuthenticateCompany(db *gorm.DB, sessionRepo repositories.ISessionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		tokenString = strings.TrimSpace(tokenString)
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Authentication failed: Missing bearer token."})
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString, auth.PurposeAccess)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+
+		if claims.SessionID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+		active, err := sessionRepo.Exists(c, claims.SessionID)
+		if err != nil {
+			log.Printf("Authentication failed: could not check session: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": true, "message": "Failed to process request"})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+
+		var user models.CompanyUser
+		if err := db.Preload("Company").First(&user, "id = ?", claims.Subject).Error; err != nil || user.Company == nil {
+			log.Printf("Authentication failed: User not found for ID: %s", claims.Subject)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+		if claims.IssuedAt == nil || user.IsTokenRevoked(claims.IssuedAt.Time) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Invalid or expired token"})
+			return
+		}
+
+		c.Set(userContextKey, &user)
+		c.Set(companyContextKey, user.Company)
+		c.Set(sessionContextKey, claims.SessionID)
+		c.Next()
+	}
+}
+
+// CompanyFromContext returns the company stored by AuthenticateCompany.
+func CompanyFromContext(c *gin.Context) (*models.Company, bool) {
+	value, exists := c.Get(companyContextKey)
+	if !exists {
+		return nil, false
+	}
+	company, ok := value.(*models.Company)
+	return company, ok
+}
+
+// UserFromContext returns the team member stored by AuthenticateCompany.
+func UserFromContext(c *gin.Context) (*models.CompanyUser, bool) {
+	value, exists := c.Get(userContextKey)
+	if !exists {
+		return nil, false
+	}
+	user, ok := value.(*models.CompanyUser)
+	return user, ok
+}
+
+// RequirePermission rejects requests from team members whose role does not
+// grant the permission. It must run after AuthenticateCompany.
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := UserFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": true, "message": "Authentication required"})
+			return
+		}
+		if !user.Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": true, "message": "You do not have permission to perform this action"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionIDFromContext returns the ID of the session the access token belongs to.
+func SessionIDFromContext(c *gin.Context) string {
+	return c.GetString(sessionContextKey)
+}
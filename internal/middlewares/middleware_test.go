@@ -1,12 +1,17 @@
 package middlewares
 
 import (
+	auth "confam-api/internal/auth"
 	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
-	"regexp"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
@@ -32,98 +37,261 @@ func setupMockDB() (*gorm.DB, sqlmock.Sqlmock, error) {
 	return gormDB, mock, err
 }
 
-func TestAuthAppBySecretKey(t *testing.T) {
+// fakeAPIKeyRepository resolves secrets from a fixed map.
+type fakeAPIKeyRepository struct {
+	repositories.IAPIKeyRepository
+	keys map[string]*models.APIKey
+	err  error
+}
+
+func (f *fakeAPIKeyRepository) FindBySecret(ctx context.Context, secret string) (*models.APIKey, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	key, ok := f.keys[secret]
+	if !ok {
+		return nil, repositories.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func TestAuthenticateAppBySecretKey(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	t.Run("missing API key", func(t *testing.T) {
-		gormDb, _, _ := setupMockDB()
-		w := httptest.NewRecorder() // Records the response
-		c, _ := gin.CreateTestContext(w)
+	app := &models.App{
+		ID:        "app-uuid-123",
+		Name:      "My App",
+		Mode:      string(models.AppModeSandbox),
+		Status:    string(models.AppStatusActive),
+		CompanyID: "company-uuid-456",
+		Company:   &models.Company{ID: "company-uuid-456", Name: "My Company"},
+	}
+	liveApp := &models.App{
+		ID:     "app-uuid-456",
+		Name:   "Live App",
+		Mode:   string(models.AppModeLive),
+		Status: string(models.AppStatusActive),
+	}
+	keys := &fakeAPIKeyRepository{keys: map[string]*models.APIKey{
+		"valid-token":        {Type: models.APIKeyTypeTest, App: app},
+		"sandbox-live-token": {Type: models.APIKeyTypeLive, App: app},
+		"live-token":         {Type: models.APIKeyTypeLive, App: liveApp},
+		"inactive-token": {Type: models.APIKeyTypeTest, App: &models.App{
+			ID:     "app-uuid-789",
+			Name:   "Old App",
+			Status: string(models.AppStatusInactive),
+		}},
+	}}
 
-		// 2. Create Request without Header
-		req, _ := http.NewRequest("GET", "/test", nil)
-		c.Request = req
+	run := func(repo repositories.IAPIKeyRepository, key string) (*gin.Context, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		if key != "" {
+			c.Request.Header.Set("x-api-key", key)
+		}
+		AuthenticateAppBySecretKey(repo)(c)
+		return c, w
+	}
 
-		// 3. Execute Middleware
-		middleware := AuthAppBySecretKey(gormDb)
-		middleware(c)
+	t.Run("missing API key", func(t *testing.T) {
+		_, w := run(keys, "")
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
-		//assert.Contains(t, w.Body.String(), "Missing API key")
 		var response map[string]interface{}
 		json.Unmarshal(w.Body.Bytes(), &response)
 		assert.Equal(t, true, response["error"])
 		assert.Contains(t, response["message"], "Missing API key")
 	})
 
-	t.Run("Invalid API Key (Not found in DB)", func(t *testing.T) {
-		gormDb, mock, _ := setupMockDB()
-		// Expect a query to find the key, but return no rows (error)
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "api_keys"`)).
-			WithArgs("invalid-token").
-			WillReturnError(gorm.ErrRecordNotFound)
+	t.Run("Invalid API Key", func(t *testing.T) {
+		_, w := run(keys, "invalid-token")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid API key")
+	})
+
+	t.Run("Lookup failure", func(t *testing.T) {
+		_, w := run(&fakeAPIKeyRepository{err: errors.New("connection refused")}, "valid-token")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("Valid API Key", func(t *testing.T) {
+		c, w := run(keys, "valid-token")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		app, exists := c.Get("app")
+		assert.True(t, exists, "Context should contain 'app' key")
+
+		appModel, ok := app.(*models.App)
+		assert.True(t, ok, "Should be *models.App type")
+		assert.NotNil(t, appModel)
+
+		assert.Equal(t, "app-uuid-123", appModel.ID)
+		assert.Equal(t, "My App", appModel.Name)
+
+		environment, ok := EnvironmentFromContext(c)
+		assert.True(t, ok)
+		assert.Equal(t, models.EnvironmentSandbox, environment)
+	})
 
-		w := httptest.NewRecorder() // Records the response
+	t.Run("Live key", func(t *testing.T) {
+		c, w := run(keys, "live-token")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		environment, _ := EnvironmentFromContext(c)
+		assert.Equal(t, models.EnvironmentLive, environment)
+	})
+
+	t.Run("Live key for sandbox app", func(t *testing.T) {
+		c, w := run(keys, "sandbox-live-token")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		_, exists := AppFromContext(c)
+		assert.False(t, exists)
+	})
+
+	t.Run("Inactive App", func(t *testing.T) {
+		c, w := run(keys, "inactive-token")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "App is inactive")
+		_, exists := AppFromContext(c)
+		assert.False(t, exists)
+	})
+}
+
+// fakeSessionRepository reports every session in active as existing.
+type fakeSessionRepository struct {
+	repositories.ISessionRepository
+	active map[string]bool
+}
+
+func (f *fakeSessionRepository) Exists(ctx context.Context, id string) (bool, error) {
+	return f.active[id], nil
+}
+
+func TestAuthenticateCompany(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+	sessions := &fakeSessionRepository{active: map[string]bool{"session-1": true}}
+
+	t.Run("missing bearer token", func(t *testing.T) {
+		gormDb, _, _ := setupMockDB()
+		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("PUT", "/test", nil)
 
-		// 2. Create Request without Header
-		req, _ := http.NewRequest("GET", "/test", nil)
-		req.Header.Set("x-api-key", "invalid-token")
-		c.Request = req
+		AuthenticateCompany(gormDb, sessions)(c)
 
-		// 3. Execute Middleware
-		middleware := AuthAppBySecretKey(gormDb)
-		middleware(c)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Missing bearer token")
+	})
+
+	t.Run("token with wrong purpose", func(t *testing.T) {
+		gormDb, _, _ := setupMockDB()
+		token, _ := auth.SignToken("company-uuid-456", auth.PurposeEmailVerification, time.Hour)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("PUT", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+
+		AuthenticateCompany(gormDb, sessions)(c)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
-		assert.Contains(t, w.Body.String(), "Invalid API key")
+		assert.Contains(t, w.Body.String(), "Invalid or expired token")
 	})
 
-	t.Run("Valid API Key", func(t *testing.T) {
+	t.Run("revoked session", func(t *testing.T) {
+		gormDb, _, _ := setupMockDB()
+		token, _ := auth.SignAccessToken("company-uuid-456", "session-2", time.Hour)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("PUT", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+
+		AuthenticateCompany(gormDb, sessions)(c)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("token issued before password change", func(t *testing.T) {
 		gormDb, mock, _ := setupMockDB()
-		mock.ExpectQuery("SELECT .* FROM `api_keys`").
-			WithArgs("valid-token", 1).
+		token, _ := auth.SignAccessToken("user-uuid-123", "session-1", time.Hour)
+		mock.ExpectQuery("SELECT .* FROM `company_users`").
+			WithArgs("user-uuid-123", 1).
 			WillReturnRows(
-				sqlmock.NewRows([]string{"id", "key", "app_id"}).
-					AddRow(1, "valid-token", "app-uuid-123"),
+				sqlmock.NewRows([]string{"id", "company_id", "role", "password_changed_at"}).
+					AddRow("user-uuid-123", "company-uuid-456", "owner", time.Now().Add(time.Hour)),
 			)
-
-		mock.ExpectQuery("SELECT .* FROM `apps`").
-			WithArgs("app-uuid-123").
+		mock.ExpectQuery("SELECT .* FROM `companies`").
+			WithArgs("company-uuid-456").
 			WillReturnRows(
-				sqlmock.NewRows([]string{"ID", "name", "company_id"}).
-					AddRow("app-uuid-123", "My App", "company-uuid-456"),
+				sqlmock.NewRows([]string{"id", "name"}).
+					AddRow("company-uuid-456", "My Company"),
 			)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("PUT", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+
+		AuthenticateCompany(gormDb, sessions)(c)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
 
+	t.Run("valid token", func(t *testing.T) {
+		gormDb, mock, _ := setupMockDB()
+		token, _ := auth.SignAccessToken("user-uuid-123", "session-1", time.Hour)
+		mock.ExpectQuery("SELECT .* FROM `company_users`").
+			WithArgs("user-uuid-123", 1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "company_id", "role"}).
+					AddRow("user-uuid-123", "company-uuid-456", "developer"),
+			)
 		mock.ExpectQuery("SELECT .* FROM `companies`").
 			WithArgs("company-uuid-456").
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "name"}).
 					AddRow("company-uuid-456", "My Company"),
 			)
-
-		w := httptest.NewRecorder() // Records the response
+		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("PUT", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
 
-		// 2. Create Request without Header
-		req, _ := http.NewRequest("GET", "/test", nil)
-		req.Header.Set("x-api-key", "valid-token")
-		c.Request = req
-
-		// 3. Execute Middleware
-		middleware := AuthAppBySecretKey(gormDb)
-		middleware(c)
+		AuthenticateCompany(gormDb, sessions)(c)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		company, ok := CompanyFromContext(c)
+		assert.True(t, ok, "Context should contain the company")
+		assert.Equal(t, "company-uuid-456", company.ID)
+		user, ok := UserFromContext(c)
+		assert.True(t, ok, "Context should contain the user")
+		assert.Equal(t, "user-uuid-123", user.ID)
+		assert.Equal(t, "session-1", SessionIDFromContext(c))
+	})
+}
 
-		app, exists := c.Get("app")
-		assert.True(t, exists, "Context should contain 'app' key")
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-		appModel, ok := app.(*models.App)
-		assert.True(t, ok, "Should be *models.App type")
-		assert.NotNil(t, appModel)
+	run := func(user *models.CompanyUser) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		if user != nil {
+			c.Set(userContextKey, user)
+		}
+		RequirePermission(models.PermissionViewLiveKeys)(c)
+		return w.Code
+	}
 
-		assert.Equal(t, "app-uuid-123", appModel.ID)
-		assert.Equal(t, "My App", appModel.Name)
-	})
+	assert.Equal(t, http.StatusUnauthorized, run(nil))
+	assert.Equal(t, http.StatusForbidden, run(&models.CompanyUser{Role: models.RoleDeveloper}))
+	assert.Equal(t, http.StatusOK, run(&models.CompanyUser{Role: models.RoleAdmin}))
+	assert.Equal(t, http.StatusOK, run(&models.CompanyUser{Role: models.RoleOwner}))
 }
@@ -1,28 +1,68 @@
 package models
 
 import (
+	crypto "confam-api/internal/crypto"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+const (
+	APIKeyTypeTest = "test"
+	APIKeyTypeLive = "live"
+)
+
+// apiKeyPrefixLength is how much of a secret key is kept in clear text so
+// that it can be recognised in the dashboard, e.g. "sk_live_AbCd".
+const apiKeyPrefixLength = 12
+
+// APIKey is a secret key an app authenticates with. Only a hash of the key is
+// stored. Keys replaced by a rotation keep working until ExpiresAt; revoked
+// keys are soft deleted.
 type APIKey struct {
 	ID        string         `gorm:"type:char(36);primaryKey" json:"id"`
-	Key       string         `gorm:"unique;not null"`
-	Type      string         `json:"type"`
-	AppID     string         `gorm:"type:char(36);not null" json:"app_id"`
-	App       *App           `gorm:"foreignKey:AppID" json:"app"`
+	KeyHash   string         `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	Prefix    string         `gorm:"type:varchar(16);not null" json:"prefix"`
+	Type      string         `gorm:"type:enum('test','live');not null" json:"type"`
+	AppID     string         `gorm:"type:char(36);not null;index" json:"app_id"`
+	App       *App           `gorm:"foreignKey:AppID" json:"app,omitempty"`
 	ExpiresAt *time.Time     `json:"expires_at"`
 	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index"` // Enables soft deletes
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // Enables soft deletes
 }
 
 func (APIKey) TableName() string {
 	return "api_keys"
 }
 
+// NewAPIKey builds the record for a plain-text secret key.
+func NewAPIKey(secret, keyType string) *APIKey {
+	prefix := secret
+	if len(prefix) > apiKeyPrefixLength {
+		prefix = prefix[:apiKeyPrefixLength]
+	}
+	return &APIKey{
+		KeyHash: crypto.HashAPIKey(secret),
+		Prefix:  prefix,
+		Type:    keyType,
+	}
+}
+
+// Environment is the environment requests made with the key belong to.
+func (api_key *APIKey) Environment() Environment {
+	if api_key.Type == APIKeyTypeLive {
+		return EnvironmentLive
+	}
+	return EnvironmentSandbox
+}
+
+// IsExpired reports whether the key stopped being valid at or before now.
+func (api_key *APIKey) IsExpired(now time.Time) bool {
+	return api_key.ExpiresAt != nil && !now.Before(*api_key.ExpiresAt)
+}
+
 func (api_key *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
 	if api_key.ID == "" {
 		api_key.ID = uuid.New().String()
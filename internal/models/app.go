@@ -20,6 +20,13 @@ const (
 	AppModeLive    AppMode = "LIVE"
 )
 
+type AppStatus string
+
+const (
+	AppStatusActive   AppStatus = "ACTIVE"
+	AppStatusInactive AppStatus = "INACTIVE"
+)
+
 var SecretSalt = func() string {
 	if s := os.Getenv("SECRET_SALT"); s != "" {
 		return s
@@ -36,7 +43,7 @@ type App struct {
 	LivePublicKey string    `gorm:"type:varchar(64);unique;not null" json:"live_public_key"`
 	Mode          string    `gorm:"type:enum('SANDBOX','LIVE');default:SANDBOX" json:"mode"`
 	Status        string    `gorm:"type:enum('ACTIVE','INACTIVE');default:ACTIVE" json:"status"`
-	WebhookURL    *string   `gorm:"type:varchar(255)" json:"webhook_url"`
+	WebhookURL    *string   `gorm:"type:varchar(255)" json:"webhook_url"` // receives every event; see WebhookEndpoint
 	CompanyID     string    `gorm:"type:char(36);not null" json:"company_id"`
 	Company       *Company  `gorm:"foreignKey:CompanyID" json:"company"`
 	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
@@ -47,16 +54,26 @@ func (App) TableName() string {
 	return "apps"
 }
 
-func (app *App) TestSecretKey() string {
-	h := hmac.New(sha256.New, []byte(SecretSalt))
-	h.Write([]byte(app.TestPublicKey))
-	return "sk_test_" + hex.EncodeToString(h.Sum(nil))
+// IsActive reports whether the app may authenticate API requests.
+func (app *App) IsActive() bool {
+	return app.Status != string(AppStatusInactive)
 }
 
-func (app *App) LiveSecretKey() string {
+// LegacySecretKey derives the secret of type keyType that apps created before
+// secret keys were issued as APIKey records were given for publicKey. It is
+// only used to backfill those records.
+func LegacySecretKey(keyType, publicKey string) string {
 	h := hmac.New(sha256.New, []byte(SecretSalt))
-	h.Write([]byte(app.LivePublicKey))
-	return "sk_live_" + hex.EncodeToString(h.Sum(nil))
+	h.Write([]byte(publicKey))
+	return "sk_" + keyType + "_" + hex.EncodeToString(h.Sum(nil))
+}
+
+// PublicKey returns the app's public key of type keyType.
+func (app *App) PublicKey(keyType string) string {
+	if keyType == APIKeyTypeLive {
+		return app.LivePublicKey
+	}
+	return app.TestPublicKey
 }
 
 func (app *App) BeforeCreate(tx *gorm.DB) (err error) {
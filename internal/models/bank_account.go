@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BankAccount is a bank account linked to a customer. The account number is
+// stored encrypted; only its last four digits are kept in clear text.
+type BankAccount struct {
+	ID                    string     `gorm:"type:char(36);primaryKey" json:"id"`
+	CustomerID            string     `gorm:"type:char(36);not null;index" json:"customer_id"`
+	Customer              *Customer  `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"-"`
+	BankCode              string     `gorm:"type:varchar(20);not null" json:"bank_code"`
+	BankName              string     `gorm:"type:varchar(100);not null" json:"bank_name"`
+	AccountName           string     `gorm:"type:varchar(191);not null" json:"account_name"`
+	AccountNumber         string     `gorm:"type:text;not null" json:"-"`
+	AccountNumberLastFour string     `gorm:"type:char(4);not null" json:"account_number_last_four"`
+	Verified              bool       `gorm:"not null;default:false" json:"verified"`
+	VerifiedAt            *time.Time `json:"verified_at"`
+	CreatedAt             time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt             time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (BankAccount) TableName() string {
+	return "bank_accounts"
+}
+
+func (account *BankAccount) BeforeCreate(tx *gorm.DB) (err error) {
+	if account.ID == "" {
+		account.ID = uuid.New().String()
+	}
+	return nil
+}
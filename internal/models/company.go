@@ -1,10 +1,10 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -13,7 +13,7 @@ type Company struct {
 	Name                 string     `gorm:"type:varchar(255);not null" json:"name"`
 	Logo                 *string    `gorm:"type:varchar(255)" json:"logo"`
 	Email                string     `gorm:"type:varchar(191);unique;not null" json:"email"`
-	Password             *string    `gorm:"type:varchar(255)" json:"password"`
+	Password             *string    `gorm:"type:varchar(255)" json:"-"` // Deprecated: credentials live on CompanyUser
 	Domain               *string    `gorm:"type:varchar(255)" json:"domain"`
 	WebhookURL           *string    `gorm:"type:varchar(255)" json:"webhook_url"`
 	Verified             bool       `gorm:"default:false" json:"verified"`
@@ -31,14 +31,6 @@ func (company *Company) BeforeCreate(tx *gorm.DB) (err error) {
 	if company.ID == "" {
 		company.ID = uuid.New().String()
 	}
-	if company.Password != nil && *company.Password != "" {
-		hashed, err := bcrypt.GenerateFromPassword([]byte(*company.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
-		pw := string(hashed)
-		company.Password = &pw
-	}
 	if company.Email != "" {
 		company.Email = lower(company.Email)
 	}
@@ -46,14 +38,6 @@ func (company *Company) BeforeCreate(tx *gorm.DB) (err error) {
 }
 
 func (company *Company) BeforeUpdate(tx *gorm.DB) (err error) {
-	if company.Password != nil && *company.Password != "" {
-		hashed, err := bcrypt.GenerateFromPassword([]byte(*company.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
-		pw := string(hashed)
-		company.Password = &pw
-	}
 	if company.Email != "" {
 		company.Email = lower(company.Email)
 	}
@@ -62,6 +46,5 @@ func (company *Company) BeforeUpdate(tx *gorm.DB) (err error) {
 
 // Helper to lowercase email
 func lower(s string) string {
-	return string([]rune(s))
-	// Or simply: return strings.ToLower(s)
+	return strings.ToLower(strings.TrimSpace(s))
 }
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleAdmin     Role = "admin"
+	RoleDeveloper Role = "developer"
+	RoleReviewer  Role = "reviewer"
+	RoleViewer    Role = "viewer"
+)
+
+type Permission string
+
+const (
+	PermissionManageTeam     Permission = "team:manage"
+	PermissionManageApps     Permission = "apps:manage"
+	PermissionViewLiveKeys   Permission = "keys:live:view"
+	PermissionManageWebhooks Permission = "webhooks:manage"
+	PermissionViewRequests   Permission = "requests:view"
+	PermissionReviewRequests Permission = "requests:review"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermissionManageTeam,
+		PermissionManageApps,
+		PermissionViewLiveKeys,
+		PermissionManageWebhooks,
+		PermissionViewRequests,
+		PermissionReviewRequests,
+	},
+	RoleAdmin: {
+		PermissionManageTeam,
+		PermissionManageApps,
+		PermissionViewLiveKeys,
+		PermissionManageWebhooks,
+		PermissionViewRequests,
+		PermissionReviewRequests,
+	},
+	RoleDeveloper: {
+		PermissionManageApps,
+		PermissionViewRequests,
+	},
+	RoleReviewer: {
+		PermissionViewRequests,
+		PermissionReviewRequests,
+	},
+	RoleViewer: {
+		PermissionViewRequests,
+	},
+}
+
+// IsValid reports whether r is one of the known roles.
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CompanyUser is a member of a company's team who can log in to the dashboard.
+type CompanyUser struct {
+	ID                string     `gorm:"type:char(36);primaryKey" json:"id"`
+	CompanyID         string     `gorm:"type:char(36);not null;index" json:"company_id"`
+	Company           *Company   `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"company,omitempty"`
+	FirstName         string     `gorm:"type:varchar(50)" json:"first_name"`
+	LastName          string     `gorm:"type:varchar(50)" json:"last_name"`
+	Email             string     `gorm:"type:varchar(191);unique;not null" json:"email"`
+	Password          *string    `gorm:"type:varchar(255)" json:"-"`
+	Role              Role       `gorm:"type:enum('owner','admin','developer','reviewer','viewer');default:'viewer'" json:"role"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	PasswordChangedAt *time.Time `gorm:"precision:6" json:"-"`
+	TwoFactorEnabled  bool       `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret   *string    `gorm:"type:text" json:"-"`
+	TwoFactorLastStep *int64     `json:"-"` // TOTP time step of the last code accepted
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (CompanyUser) TableName() string {
+	return "company_users"
+}
+
+func (user *CompanyUser) BeforeCreate(tx *gorm.DB) (err error) {
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	return user.normalize()
+}
+
+func (user *CompanyUser) BeforeUpdate(tx *gorm.DB) (err error) {
+	return user.normalize()
+}
+
+func (user *CompanyUser) normalize() error {
+	if user.Email != "" {
+		user.Email = lower(user.Email)
+	}
+	return nil
+}
+
+// SetPassword stores the bcrypt hash of a plain-text password. Password is
+// only ever written through here, so the hooks never have to guess whether a
+// value is already hashed.
+func (user *CompanyUser) SetPassword(password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	pw := string(hashed)
+	user.Password = &pw
+	return nil
+}
+
+// Can reports whether the user's role grants the permission.
+func (user *CompanyUser) Can(permission Permission) bool {
+	return user.Role.Can(permission)
+}
+
+// IsTokenRevoked reports whether a token issued at issuedAt was issued no
+// later than the user's last password change and must therefore no longer
+// be accepted. Both times have microsecond precision.
+func (user *CompanyUser) IsTokenRevoked(issuedAt time.Time) bool {
+	if user.PasswordChangedAt == nil {
+		return false
+	}
+	return !issuedAt.Truncate(time.Microsecond).After(user.PasswordChangedAt.Truncate(time.Microsecond))
+}
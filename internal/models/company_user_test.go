@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSetPasswordHashesHashLikePasswords(t *testing.T) {
+	planted, err := bcrypt.GenerateFromPassword([]byte("chosen"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user CompanyUser
+	if err := user.SetPassword(string(planted)); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	if *user.Password == string(planted) {
+		t.Fatal("SetPassword() stored a bcrypt-looking password as-is")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(*user.Password), planted) != nil {
+		t.Error("stored hash does not match the password")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte("chosen")) == nil {
+		t.Error("the planted hash's password logs in")
+	}
+}
+
+func TestIsTokenRevokedWithinTheSameSecond(t *testing.T) {
+	changedAt := time.Date(2026, 10, 18, 12, 0, 0, 500_000_000, time.UTC)
+	user := CompanyUser{PasswordChangedAt: &changedAt}
+
+	if !user.IsTokenRevoked(changedAt.Add(-400 * time.Millisecond)) {
+		t.Error("token issued earlier in the same second survived the change")
+	}
+	if !user.IsTokenRevoked(changedAt) {
+		t.Error("token issued at the change survived it")
+	}
+	if user.IsTokenRevoked(changedAt.Add(time.Millisecond)) {
+		t.Error("token issued after the change was revoked")
+	}
+}
@@ -26,24 +26,27 @@ const (
 )
 
 type Customer struct {
-	ID                      string     `gorm:"type:char(36);primaryKey" json:"id"`
-	Token                   string     `gorm:"type:varchar(191);unique;not null" json:"token"`
-	Phone                   *string    `gorm:"type:text" json:"phone"`
-	PhoneVerifiedAt         *time.Time `json:"phone_verified_at"`
-	EncryptedPII            *string    `gorm:"type:text" json:"encrypted_pii"`
-	Status                  Status     `gorm:"type:enum('pending','verified','rejected');default:'pending'" json:"status"`
-	KYCLevelAchieved        string     `gorm:"type:enum('none','tier_1','tier_2','tier_3');default:'none'" json:"kyc_level_achieved"`
-	VerifiedAt              *time.Time `json:"verified_at"`
-	IsBlacklisted           bool       `gorm:"default:false" json:"is_blacklisted"`
-	Email                   string     `gorm:"type:text;not null" json:"email"`
-	EmailHash               string     `gorm:"type:varchar(191);unique;not null" json:"email_hash"`
-	DOB                     *time.Time `json:"dob"`
-	Address                 *string    `gorm:"type:varchar(191)" json:"address"`
-	AccessType              AccessType `gorm:"type:enum('temporary','permanent');default:'permanent'" json:"access_type"`
-	FacialRecognitionPassed bool       `gorm:"default:false" json:"facial_recognition_passed"`
-	CreatedAt               time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt               time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
-	// Permissions []Permission `gorm:"foreignKey:CustomerID" json:"permissions"`
+	ID                      string               `gorm:"type:char(36);primaryKey" json:"id"`
+	Token                   string               `gorm:"type:varchar(191);unique;not null" json:"token"`
+	Phone                   *string              `gorm:"type:text" json:"phone"`
+	PhoneVerifiedAt         *time.Time           `json:"phone_verified_at"`
+	EncryptedPII            *string              `gorm:"type:text" json:"encrypted_pii"`
+	Status                  Status               `gorm:"type:enum('pending','verified','rejected');default:'pending'" json:"status"`
+	KYCLevelAchieved        string               `gorm:"type:enum('none','tier_1','tier_2','tier_3');default:'none'" json:"kyc_level_achieved"`
+	VerifiedAt              *time.Time           `json:"verified_at"`
+	IsBlacklisted           bool                 `gorm:"default:false" json:"is_blacklisted"`
+	Email                   string               `gorm:"type:text;not null" json:"email"`
+	EmailHash               string               `gorm:"type:varchar(191);not null;uniqueIndex:idx_customers_email_hash" json:"email_hash"`
+	Environment             Environment          `gorm:"type:enum('sandbox','live');default:'live';not null;uniqueIndex:idx_customers_email_hash" json:"environment"`
+	DOB                     *time.Time           `json:"dob"`
+	Address                 *string              `gorm:"type:varchar(191)" json:"address"`
+	AddressVerifiedAt       *time.Time           `json:"address_verified_at"`
+	AccessType              AccessType           `gorm:"type:enum('temporary','permanent');default:'permanent'" json:"access_type"` // Deprecated: see CustomerPermission
+	FacialRecognitionPassed bool                 `gorm:"default:false" json:"facial_recognition_passed"`
+	DocumentVerifiedAt      *time.Time           `json:"document_verified_at"`
+	CreatedAt               time.Time            `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt               time.Time            `gorm:"autoUpdateTime" json:"updated_at"`
+	Permissions             []CustomerPermission `gorm:"foreignKey:CustomerID" json:"permissions,omitempty"`
 	// Documents   []Document   `gorm:"foreignKey:CustomerID" json:"documents"`
 	Identities []Identity `gorm:"foreignKey:CustomerID" json:"identities"`
 }
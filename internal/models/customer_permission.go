@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PermissionScope is a part of a customer's KYC data a company may access.
+type PermissionScope string
+
+const (
+	ScopeIdentity     PermissionScope = "identity"
+	ScopePhone        PermissionScope = "phone"
+	ScopeAddress      PermissionScope = "address"
+	ScopeBankAccounts PermissionScope = "bank_accounts"
+)
+
+// PermissionScopes is stored as a MySQL SET column.
+type PermissionScopes []PermissionScope
+
+func (scopes PermissionScopes) Value() (driver.Value, error) {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ","), nil
+}
+
+func (scopes *PermissionScopes) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*scopes = nil
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan %T into PermissionScopes", value)
+	}
+
+	*scopes = PermissionScopes{}
+	for _, name := range strings.Split(s, ",") {
+		if name != "" {
+			*scopes = append(*scopes, PermissionScope(name))
+		}
+	}
+	return nil
+}
+
+// Has reports whether scope is one of the scopes.
+func (scopes PermissionScopes) Has(scope PermissionScope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the scopes include every one of other.
+func (scopes PermissionScopes) Covers(other PermissionScopes) bool {
+	for _, scope := range other {
+		if !scopes.Has(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Merge returns the scopes together with those of other.
+func (scopes PermissionScopes) Merge(other PermissionScopes) PermissionScopes {
+	merged := append(PermissionScopes{}, scopes...)
+	for _, scope := range other {
+		if !merged.Has(scope) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
+
+// CustomerPermission grants a company access to parts of a customer's KYC
+// data. It is named so as not to clash with the dashboard's Permission.
+// Temporary permissions stop granting access at ExpiresAt; the expiry sweeper
+// then sets ExpiredAt once it has told the company's app.
+type CustomerPermission struct {
+	ID         string           `gorm:"type:char(36);primaryKey" json:"id"`
+	CustomerID string           `gorm:"type:char(36);not null;index:idx_customer_permissions_customer_company" json:"customer_id"`
+	Customer   *Customer        `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"-"`
+	CompanyID  string           `gorm:"type:char(36);not null;index:idx_customer_permissions_customer_company" json:"company_id"`
+	Company    *Company         `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"company,omitempty"`
+	AppID      *string          `gorm:"type:char(36)" json:"app_id"`
+	RequestID  *string          `gorm:"type:char(36)" json:"request_id"`
+	Scopes     PermissionScopes `gorm:"type:set('identity','phone','address','bank_accounts');not null" json:"scopes"`
+	AccessType AccessType       `gorm:"type:enum('temporary','permanent');default:'permanent';not null" json:"access_type"`
+	GrantedAt  time.Time        `gorm:"not null" json:"granted_at"`
+	ExpiresAt  *time.Time       `gorm:"index" json:"expires_at"`
+	ExpiredAt  *time.Time       `json:"expired_at"`
+	RevokedAt  *time.Time       `json:"revoked_at"`
+	CreatedAt  time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (CustomerPermission) TableName() string {
+	return "customer_permissions"
+}
+
+func (permission *CustomerPermission) BeforeCreate(tx *gorm.DB) (err error) {
+	if permission.ID == "" {
+		permission.ID = uuid.New().String()
+	}
+	if permission.GrantedAt.IsZero() {
+		permission.GrantedAt = time.Now()
+	}
+	if permission.AccessType == "" {
+		permission.AccessType = AccessPermanent
+	}
+	return nil
+}
+
+// IsActive reports whether the permission grants access at now.
+func (permission *CustomerPermission) IsActive(now time.Time) bool {
+	if permission.RevokedAt != nil {
+		return false
+	}
+	return permission.ExpiresAt == nil || now.Before(*permission.ExpiresAt)
+}
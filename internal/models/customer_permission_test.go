@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPermissionScopesValueAndScan(t *testing.T) {
+	scopes := PermissionScopes{ScopeIdentity, ScopeBankAccounts}
+	value, err := scopes.Value()
+	if err != nil || value != "identity,bank_accounts" {
+		t.Fatalf("Value() = %v, %v, want identity,bank_accounts", value, err)
+	}
+
+	var scanned PermissionScopes
+	if err := scanned.Scan([]byte("identity,bank_accounts")); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !scanned.Covers(scopes) || !scopes.Covers(scanned) {
+		t.Errorf("Scan() = %v, want %v", scanned, scopes)
+	}
+
+	if err := scanned.Scan(""); err != nil || len(scanned) != 0 {
+		t.Errorf("Scan(\"\") = %v, %v, want no scopes", scanned, err)
+	}
+}
+
+func TestPermissionScopesCoversAndMerge(t *testing.T) {
+	granted := PermissionScopes{ScopeIdentity, ScopePhone}
+	requested := PermissionScopes{ScopeIdentity, ScopeBankAccounts}
+
+	if granted.Covers(requested) {
+		t.Errorf("%v should not cover %v", granted, requested)
+	}
+	merged := granted.Merge(requested)
+	if len(merged) != 3 || !merged.Covers(requested) || !merged.Covers(granted) {
+		t.Errorf("Merge() = %v", merged)
+	}
+	if len(granted) != 2 {
+		t.Errorf("Merge() changed the receiver: %v", granted)
+	}
+}
+
+func TestCustomerPermissionIsActive(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		permission CustomerPermission
+		want       bool
+	}{
+		{"permanent", CustomerPermission{}, true},
+		{"temporary", CustomerPermission{ExpiresAt: &future}, true},
+		{"expired", CustomerPermission{ExpiresAt: &past}, false},
+		{"revoked", CustomerPermission{RevokedAt: &past}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.permission.IsActive(now); got != tt.want {
+			t.Errorf("%s: IsActive() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
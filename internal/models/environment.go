@@ -0,0 +1,23 @@
+package models
+
+// Environment separates sandbox traffic from live traffic. It is decided by
+// the API key a request is made with.
+type Environment string
+
+const (
+	EnvironmentSandbox Environment = "sandbox"
+	EnvironmentLive    Environment = "live"
+)
+
+// IsValid reports whether e is one of the known environments.
+func (e Environment) IsValid() bool {
+	return e == EnvironmentSandbox || e == EnvironmentLive
+}
+
+// APIKeyType is the type of the API keys that act in the environment.
+func (e Environment) APIKeyType() string {
+	if e == EnvironmentLive {
+		return APIKeyTypeLive
+	}
+	return APIKeyTypeTest
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Invitation invites someone by email to join a company's team.
+// Only a hash of the invite token is stored.
+type Invitation struct {
+	ID          string       `gorm:"type:char(36);primaryKey" json:"id"`
+	CompanyID   string       `gorm:"type:char(36);not null;index" json:"company_id"`
+	Company     *Company     `gorm:"foreignKey:CompanyID;constraint:OnDelete:CASCADE;" json:"company,omitempty"`
+	Email       string       `gorm:"type:varchar(191);not null" json:"email"`
+	Role        Role         `gorm:"type:enum('owner','admin','developer','reviewer','viewer');not null" json:"role"`
+	TokenHash   string       `gorm:"type:varchar(64);unique;not null" json:"-"`
+	InvitedByID string       `gorm:"type:char(36);not null" json:"invited_by_id"`
+	InvitedBy   *CompanyUser `gorm:"foreignKey:InvitedByID" json:"invited_by,omitempty"`
+	ExpiresAt   time.Time    `gorm:"not null" json:"expires_at"`
+	AcceptedAt  *time.Time   `json:"accepted_at"`
+	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+func (invitation *Invitation) BeforeCreate(tx *gorm.DB) (err error) {
+	if invitation.ID == "" {
+		invitation.ID = uuid.New().String()
+	}
+	if invitation.Email != "" {
+		invitation.Email = lower(invitation.Email)
+	}
+	return nil
+}
+
+// IsPending reports whether the invitation can still be accepted.
+func (invitation *Invitation) IsPending() bool {
+	return invitation.AcceptedAt == nil && time.Now().Before(invitation.ExpiresAt)
+}
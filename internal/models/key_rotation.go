@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KeyRotation records a rotation of an app's key pair for one environment.
+type KeyRotation struct {
+	ID                   string       `gorm:"type:char(36);primaryKey" json:"id"`
+	AppID                string       `gorm:"type:char(36);not null;index" json:"app_id"`
+	App                  *App         `gorm:"foreignKey:AppID;constraint:OnDelete:CASCADE;" json:"app,omitempty"`
+	Environment          string       `gorm:"type:enum('test','live');not null" json:"environment"`
+	RotatedByID          string       `gorm:"type:char(36);not null" json:"rotated_by_id"`
+	RotatedBy            *CompanyUser `gorm:"foreignKey:RotatedByID" json:"rotated_by,omitempty"`
+	OldPublicKey         string       `gorm:"type:varchar(64);not null" json:"old_public_key"`
+	NewPublicKey         string       `gorm:"type:varchar(64);not null" json:"new_public_key"`
+	PreviousKeyExpiresAt time.Time    `gorm:"not null" json:"previous_key_expires_at"`
+	IP                   string       `gorm:"type:varchar(45)" json:"ip"`
+	CreatedAt            time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (KeyRotation) TableName() string {
+	return "key_rotations"
+}
+
+func (rotation *KeyRotation) BeforeCreate(tx *gorm.DB) (err error) {
+	if rotation.ID == "" {
+		rotation.ID = uuid.New().String()
+	}
+	return nil
+}
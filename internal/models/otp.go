@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type OTPChannel string
+
+const (
+	OTPChannelSMS   OTPChannel = "sms"
+	OTPChannelEmail OTPChannel = "email"
+)
+
+// OTP is a one-time password sent to a customer during a KYC request. OTPs
+// live in Redis rather than the database; only a hash of the code is kept.
+type OTP struct {
+	CodeHash  string     `json:"code_hash"`
+	Channel   OTPChannel `json:"channel"`
+	SentTo    string     `json:"sent_to"`
+	ExpiresAt time.Time  `json:"expires_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PortalLogin is a pending customer portal login. The customer is emailed a
+// magic link carrying the token and the code for typing in by hand; only
+// their hashes are kept, in Redis.
+type PortalLogin struct {
+	CustomerID string    `json:"customer_id"`
+	TokenHash  string    `json:"token_hash"`
+	CodeHash   string    `json:"code_hash"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
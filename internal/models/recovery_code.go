@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a single-use two-factor recovery code. Only its hash is stored.
+type RecoveryCode struct {
+	ID        string       `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    string       `gorm:"type:char(36);not null;index" json:"user_id"`
+	User      *CompanyUser `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"user"`
+	CodeHash  string       `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time   `json:"used_at"`
+	CreatedAt time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+func (code *RecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	if code.ID == "" {
+		code.ID = uuid.New().String()
+	}
+	return nil
+}
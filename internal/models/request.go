@@ -19,6 +19,29 @@ const (
 	RequestStatusFailed        RequestStatus = "failed"
 )
 
+// requestTransitions lists the statuses a request may move to from each
+// status. Completed and failed requests are final.
+var requestTransitions = map[RequestStatus][]RequestStatus{
+	RequestStatusInitiated:     {RequestStatusOTPPending, RequestStatusKYCProcessing, RequestStatusFailed},
+	RequestStatusOTPPending:    {RequestStatusKYCProcessing, RequestStatusFailed},
+	RequestStatusKYCProcessing: {RequestStatusCompleted, RequestStatusFailed},
+}
+
+// CanTransitionTo reports whether a request in this status may move to next.
+func (s RequestStatus) CanTransitionTo(next RequestStatus) bool {
+	for _, allowed := range requestTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether a request in this status can no longer change.
+func (s RequestStatus) IsFinal() bool {
+	return s == RequestStatusCompleted || s == RequestStatusFailed
+}
+
 type KYCLevel string
 
 const (
@@ -27,21 +50,42 @@ const (
 	KYCLevelTier3 KYCLevel = "tier_3"
 )
 
+// Rank orders KYC levels: none is 0 and tier_3 is 3. Unknown levels rank as none.
+func (l KYCLevel) Rank() int {
+	switch l {
+	case KYCLevelTier1:
+		return 1
+	case KYCLevelTier2:
+		return 2
+	case KYCLevelTier3:
+		return 3
+	}
+	return 0
+}
+
 type Request struct {
-	ID                    string        `gorm:"type:char(36);primaryKey;unique" json:"id"`
-	Reference             string        `gorm:"type:varchar(191);not null;unique" json:"reference"`
-	RedirectURL           string        `gorm:"type:varchar(191);not null" json:"redirect_url"`
-	KYCLevel              string        `gorm:"type:enum('tier_1','tier_2','tier_3');default:'tier_1'" json:"kyc_level"`
-	BankAccountsRequested bool          `gorm:"default:false" json:"bank_accounts_requested"`
-	EncryptedData         *string       `gorm:"type:text" json:"encrypted_data"`
-	AllowURL              *string       `gorm:"type:varchar(191)" json:"allow_url"`
-	KYCToken              string        `gorm:"type:varchar(191)" json:"kyc_token"`
-	TokenExpiresAt        time.Time     `gorm:"not null" json:"token_expires_at"`
-	CompanyID             string        `gorm:"type:char(36);not null" json:"company_id"`
-	Company               *Company      `gorm:"foreignKey:CompanyID" json:"company"`
-	Status                RequestStatus `gorm:"type:enum('initiated','otp_pending','kyc_processing','completed','failed');default:'initiated'" json:"status"`
-	CreatedAt             time.Time     `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt             time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                    string           `gorm:"type:char(36);primaryKey;unique" json:"id"`
+	Reference             string           `gorm:"type:varchar(191);not null;uniqueIndex:idx_requests_reference" json:"reference"`
+	RedirectURL           string           `gorm:"type:varchar(191);not null" json:"redirect_url"`
+	KYCLevel              string           `gorm:"type:enum('tier_1','tier_2','tier_3');default:'tier_1'" json:"kyc_level"`
+	BankAccountsRequested bool             `gorm:"default:false" json:"bank_accounts_requested"`
+	EncryptedData         *string          `gorm:"type:text" json:"encrypted_data"`
+	AllowURL              *string          `gorm:"type:varchar(191)" json:"allow_url"`
+	KYCToken              string           `gorm:"type:varchar(191)" json:"kyc_token"`
+	TokenExpiresAt        time.Time        `gorm:"not null" json:"token_expires_at"`
+	CompanyID             string           `gorm:"type:char(36);not null;uniqueIndex:idx_requests_reference" json:"company_id"`
+	Company               *Company         `gorm:"foreignKey:CompanyID" json:"company"`
+	AppID                 *string          `gorm:"type:char(36);index" json:"app_id"`
+	App                   *App             `gorm:"foreignKey:AppID" json:"app,omitempty"`
+	Environment           Environment      `gorm:"type:enum('sandbox','live');default:'live';not null;uniqueIndex:idx_requests_reference" json:"environment"`
+	CustomerID            *string          `gorm:"type:char(36);index" json:"customer_id"`
+	Customer              *Customer        `gorm:"foreignKey:CustomerID" json:"customer,omitempty"`
+	Status                RequestStatus    `gorm:"type:enum('initiated','otp_pending','kyc_processing','completed','failed');default:'initiated'" json:"status"`
+	Scopes                PermissionScopes `gorm:"type:set('identity','phone','address','bank_accounts')" json:"scopes"`
+	AccessType            AccessType       `gorm:"type:enum('temporary','permanent');default:'permanent'" json:"access_type"`
+	AccessDays            *int             `json:"access_days"`
+	CreatedAt             time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt             time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Request) TableName() string {
@@ -54,13 +98,45 @@ func (request *Request) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 
 	if request.AllowURL == nil || *request.AllowURL == "" {
-		generatedURL := "http://127.0.0.1:5173/" + request.KYCToken
+		generatedURL := allowURL(request.KYCToken)
 		request.AllowURL = &generatedURL
 	}
 
 	return nil
 }
 
+func allowURL(kycToken string) string {
+	return "http://127.0.0.1:5173/" + kycToken
+}
+
+// IssueToken gives the request a new KYC token, valid until expiresAt, and
+// the allow URL that goes with it.
+func (request *Request) IssueToken(kycToken string, expiresAt time.Time) {
+	generatedURL := allowURL(kycToken)
+	request.KYCToken = kycToken
+	request.TokenExpiresAt = expiresAt
+	request.AllowURL = &generatedURL
+}
+
+// RequestedScopes is the customer data the request asks access to. Requests
+// that name no scopes ask for identity, phone and address, and bank accounts
+// when they requested them.
+func (request *Request) RequestedScopes() PermissionScopes {
+	if len(request.Scopes) > 0 {
+		return request.Scopes
+	}
+	scopes := PermissionScopes{ScopeIdentity, ScopePhone, ScopeAddress}
+	if request.BankAccountsRequested {
+		scopes = append(scopes, ScopeBankAccounts)
+	}
+	return scopes
+}
+
+// IsTokenExpired reports whether the request's KYC token has expired.
+func (request *Request) IsTokenExpired(now time.Time) bool {
+	return !request.TokenExpiresAt.IsZero() && now.After(request.TokenExpiresAt)
+}
+
 func (r *Request) AfterFind(tx *gorm.DB) (err error) {
 	if r.EncryptedData != nil && *r.EncryptedData != "" {
 		decrypted, err := crypto.Decrypt(*r.EncryptedData)
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RequestEvent records one status change of a request. The event created
+// with the request has no FromStatus.
+type RequestEvent struct {
+	ID         string         `gorm:"type:char(36);primaryKey" json:"id"`
+	RequestID  string         `gorm:"type:char(36);not null;index" json:"request_id"`
+	Request    *Request       `gorm:"foreignKey:RequestID;constraint:OnDelete:CASCADE;" json:"-"`
+	FromStatus *RequestStatus `gorm:"type:enum('initiated','otp_pending','kyc_processing','completed','failed')" json:"from_status"`
+	ToStatus   RequestStatus  `gorm:"type:enum('initiated','otp_pending','kyc_processing','completed','failed');not null" json:"to_status"`
+	Reason     *string        `gorm:"type:varchar(191)" json:"reason"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (RequestEvent) TableName() string {
+	return "request_events"
+}
+
+func (event *RequestEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -149,3 +149,26 @@ func TestAfterFind_HandlesEmptyEncryptedData(t *testing.T) {
 		t.Errorf("AfterFind() modified empty EncryptedData")
 	}
 }
+
+func TestRequestStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to RequestStatus
+		want     bool
+	}{
+		{RequestStatusInitiated, RequestStatusOTPPending, true},
+		{RequestStatusInitiated, RequestStatusKYCProcessing, true},
+		{RequestStatusInitiated, RequestStatusCompleted, false},
+		{RequestStatusOTPPending, RequestStatusKYCProcessing, true},
+		{RequestStatusOTPPending, RequestStatusInitiated, false},
+		{RequestStatusKYCProcessing, RequestStatusCompleted, true},
+		{RequestStatusKYCProcessing, RequestStatusFailed, true},
+		{RequestStatusCompleted, RequestStatusFailed, false},
+		{RequestStatusFailed, RequestStatusKYCProcessing, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Session is a dashboard login session of a company user. Sessions live in
+// Redis rather than the database; each one owns a single rotating refresh token.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	CompanyID  string    `json:"company_id"`
+	TokenHash  string    `json:"token_hash"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -8,17 +9,34 @@ import (
 	"gorm.io/gorm"
 )
 
+type WebhookStatus string
+
+const (
+	WebhookStatusPending WebhookStatus = "pending"
+	WebhookStatusSuccess WebhookStatus = "success"
+	WebhookStatusFailed  WebhookStatus = "failed"
+)
+
+// Webhook is an event waiting to be, or already, delivered to an app. Rows
+// are written in the same transaction as the change they announce and the
+// dispatcher delivers pending ones once NextAttemptAt has passed.
 type Webhook struct {
-	ID            string `gorm:"type:char(36);primaryKey;unique" json:"id"`
-	EventType     string
-	Payload       string
-	TargetURL     string
-	Status        string `gorm:"type:enum('pending', 'success', 'failed');default:'pending'" json:"status"`
-	Attempts      int
-	LastAttemptAt *time.Time
-	ErrorMessage  *string
-	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID             string        `gorm:"type:char(36);primaryKey;unique" json:"id"`
+	AppID          *string       `gorm:"type:char(36);index" json:"app_id"`
+	EndpointID     *string       `gorm:"type:char(36);index" json:"endpoint_id"` // nil when sent to the app's webhook URL
+	EventType      string        `gorm:"type:varchar(100);not null" json:"event_type"`
+	Reference      *string       `gorm:"type:varchar(255);index" json:"reference"`
+	Environment    Environment   `gorm:"type:enum('sandbox','live');default:'live'" json:"environment"`
+	Payload        string        `gorm:"type:mediumtext;not null" json:"payload"`
+	TargetURL      string        `gorm:"type:varchar(255);not null" json:"target_url"`
+	Status         WebhookStatus `gorm:"type:enum('pending', 'success', 'failed');default:'pending';index:idx_webhooks_due" json:"status"`
+	Attempts       int           `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time     `gorm:"index:idx_webhooks_due" json:"next_attempt_at"`
+	LastAttemptAt  *time.Time    `json:"last_attempt_at"`
+	ResponseStatus *int          `json:"response_status"`
+	ErrorMessage   *string       `gorm:"type:text" json:"error_message"`
+	CreatedAt      time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Webhook) TableName() string {
@@ -29,5 +47,31 @@ func (webhook *Webhook) BeforeCreate(tx *gorm.DB) (err error) {
 	if webhook.ID == "" {
 		webhook.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
 	}
+	if webhook.NextAttemptAt.IsZero() {
+		webhook.NextAttemptAt = time.Now()
+	}
 	return nil
 }
+
+// NewWebhook builds a pending webhook for the event. The payload is the JSON
+// body sent to targetURL:
+//
+//	{"event": "<event>", "data": <data>}
+func NewWebhook(appID *string, environment Environment, targetURL, event string, data any) (*Webhook, error) {
+	payload, err := json.Marshal(map[string]any{
+		"event": event,
+		"data":  data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{
+		AppID:         appID,
+		EventType:     event,
+		Environment:   environment,
+		Payload:       string(payload),
+		TargetURL:     targetURL,
+		Status:        WebhookStatusPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebhookAttempt is one attempt at delivering a webhook, kept so integrators
+// can see what was sent and what their endpoint answered. The body sent is
+// the webhook's payload.
+type WebhookAttempt struct {
+	ID             string    `gorm:"type:char(36);primaryKey" json:"id"`
+	WebhookID      string    `gorm:"type:char(36);not null;index" json:"webhook_id"`
+	TargetURL      string    `gorm:"type:varchar(255);not null" json:"target_url"`
+	RequestHeaders string    `gorm:"type:text" json:"request_headers"` // JSON object of the headers sent
+	ResponseStatus *int      `json:"response_status"`
+	ResponseBody   *string   `gorm:"type:text" json:"response_body"`
+	ErrorMessage   *string   `gorm:"type:text" json:"error_message"`
+	LatencyMS      int64     `json:"latency_ms"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (WebhookAttempt) TableName() string {
+	return "webhook_attempts"
+}
+
+func (attempt *WebhookAttempt) BeforeCreate(tx *gorm.DB) (err error) {
+	if attempt.ID == "" {
+		attempt.ID = uuid.New().String()
+	}
+	return nil
+}
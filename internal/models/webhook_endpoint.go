@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebhookEventTypes are the webhook events an endpoint subscribes to, stored
+// comma separated.
+type WebhookEventTypes []string
+
+func (events WebhookEventTypes) Value() (driver.Value, error) {
+	return strings.Join(events, ","), nil
+}
+
+func (events *WebhookEventTypes) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*events = nil
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan %T into WebhookEventTypes", value)
+	}
+
+	*events = WebhookEventTypes{}
+	for _, name := range strings.Split(s, ",") {
+		if name != "" {
+			*events = append(*events, name)
+		}
+	}
+	return nil
+}
+
+// Has reports whether event is one of the events.
+func (events WebhookEventTypes) Has(event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpoint is a URL an app receives the webhook events it subscribed
+// to at. Each endpoint signs with secrets of its own.
+type WebhookEndpoint struct {
+	ID          string            `gorm:"type:char(36);primaryKey" json:"id"`
+	AppID       string            `gorm:"type:char(36);not null;index" json:"app_id"`
+	URL         string            `gorm:"type:varchar(255);not null" json:"url"`
+	Description *string           `gorm:"type:varchar(255)" json:"description"`
+	Events      WebhookEventTypes `gorm:"type:varchar(1024);not null" json:"events"`
+	Enabled     bool              `gorm:"not null" json:"enabled"`
+	CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// Subscribes reports whether the endpoint is enabled and subscribed to event.
+func (endpoint *WebhookEndpoint) Subscribes(event string) bool {
+	return endpoint.Enabled && endpoint.Events.Has(event)
+}
+
+func (endpoint *WebhookEndpoint) BeforeCreate(tx *gorm.DB) (err error) {
+	if endpoint.ID == "" {
+		endpoint.ID = uuid.New().String()
+	}
+	return nil
+}
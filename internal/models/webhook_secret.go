@@ -0,0 +1,62 @@
+package models
+
+import (
+	crypto "confam-api/internal/crypto"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// webhookSecretPrefixLength is how much of a webhook secret is kept in clear
+// text so that it can be recognised in the dashboard, e.g. "whsec_AbCdEf".
+const webhookSecretPrefixLength = 12
+
+// WebhookSecret is a secret the webhooks of an app are signed with: those
+// sent to one of its webhook endpoints when EndpointID is set, those sent to
+// the app's webhook URL otherwise. Unlike API keys it is needed in clear text
+// to sign, so it is stored encrypted. Secrets replaced by a rotation keep
+// signing alongside the new one until ExpiresAt.
+type WebhookSecret struct {
+	ID              string     `gorm:"type:char(36);primaryKey" json:"id"`
+	AppID           string     `gorm:"type:char(36);not null;index" json:"app_id"`
+	EndpointID      *string    `gorm:"type:char(36);index" json:"endpoint_id"`
+	EncryptedSecret string     `gorm:"type:text;not null" json:"-"`
+	Prefix          string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (WebhookSecret) TableName() string {
+	return "webhook_secrets"
+}
+
+// NewWebhookSecret builds the record for a plain-text webhook secret.
+func NewWebhookSecret(secret string) (*WebhookSecret, error) {
+	encrypted, err := crypto.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+	prefix := secret
+	if len(prefix) > webhookSecretPrefixLength {
+		prefix = prefix[:webhookSecretPrefixLength]
+	}
+	return &WebhookSecret{EncryptedSecret: encrypted, Prefix: prefix}, nil
+}
+
+// Secret decrypts the secret.
+func (secret *WebhookSecret) Secret() (string, error) {
+	return crypto.Decrypt(secret.EncryptedSecret)
+}
+
+// IsExpired reports whether the secret stopped signing at or before now.
+func (secret *WebhookSecret) IsExpired(now time.Time) bool {
+	return secret.ExpiresAt != nil && !now.Before(*secret.ExpiresAt)
+}
+
+func (secret *WebhookSecret) BeforeCreate(tx *gorm.DB) (err error) {
+	if secret.ID == "" {
+		secret.ID = uuid.New().String()
+	}
+	return nil
+}
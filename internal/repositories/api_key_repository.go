@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	crypto "confam-api/internal/crypto"
+	"confam-api/internal/models"
+	client "confam-api/internal/redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+const (
+	apiKeyCacheTTL     = 10 * time.Minute
+	apiKeyMissCacheTTL = time.Minute
+	apiKeyCacheMiss    = "-"
+)
+
+type IAPIKeyRepository interface {
+	FindBySecret(ctx context.Context, secret string) (*models.APIKey, error)
+	ListKeys(ctx context.Context, appID string) ([]models.APIKey, error)
+	Revoke(ctx context.Context, appID, id string) (*models.APIKey, error)
+	Rotate(ctx context.Context, app *models.App, newKey *models.APIKey, rotation *models.KeyRotation) error
+	ListRotations(ctx context.Context, appID string) ([]models.KeyRotation, error)
+}
+
+// APIKeyRepository stores hashes of app secret keys in the database and caches
+// lookups in Redis:
+//
+//	apikey:<hash>    JSON encoded cachedAPIKey, or "-" for a key that does not exist
+type APIKeyRepository struct {
+	db    *gorm.DB
+	Redis *client.Client
+}
+
+func NewAPIKeyRepository(db *gorm.DB, rdb *client.Client) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:    db,
+		Redis: rdb,
+	}
+}
+
+// cachedAPIKey is what the cache remembers about a key that exists.
+type cachedAPIKey struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	AppID     string     `json:"app_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func apiKeyCacheKey(keyHash string) string {
+	return fmt.Sprintf("apikey:%s", keyHash)
+}
+
+// invalidateAPIKeys drops cached lookups of the given key hashes.
+func invalidateAPIKeys(ctx context.Context, rdb *client.Client, keyHashes ...string) error {
+	if len(keyHashes) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(keyHashes))
+	for _, hash := range keyHashes {
+		keys = append(keys, apiKeyCacheKey(hash))
+	}
+	if err := rdb.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate cached api keys: %w", err)
+	}
+	return nil
+}
+
+// FindBySecret returns the key a secret belongs to, with its app and the
+// app's company loaded. Expired and revoked keys return ErrAPIKeyNotFound.
+// Redis errors are logged and the lookup falls back to the database.
+func (r *APIKeyRepository) FindBySecret(ctx context.Context, secret string) (*models.APIKey, error) {
+	keyHash := crypto.HashAPIKey(secret)
+
+	cached, err := r.Redis.Get(ctx, apiKeyCacheKey(keyHash)).Result()
+	switch {
+	case err == nil && cached == apiKeyCacheMiss:
+		return nil, ErrAPIKeyNotFound
+	case err == nil:
+		var entry cachedAPIKey
+		if err := json.Unmarshal([]byte(cached), &entry); err == nil {
+			key := models.APIKey{
+				ID:        entry.ID,
+				KeyHash:   keyHash,
+				Type:      entry.Type,
+				AppID:     entry.AppID,
+				ExpiresAt: entry.ExpiresAt,
+			}
+			if key.IsExpired(time.Now()) {
+				return nil, ErrAPIKeyNotFound
+			}
+			var app models.App
+			if err := r.db.WithContext(ctx).Preload("Company").First(&app, "id = ?", entry.AppID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, ErrAPIKeyNotFound
+				}
+				return nil, err
+			}
+			key.App = &app
+			return &key, nil
+		}
+	case !errors.Is(err, goredis.Nil):
+		log.Printf("Failed to read cached api key: %v", err)
+	}
+
+	var key models.APIKey
+	err = r.db.WithContext(ctx).Preload("App.Company").First(&key, "key_hash = ?", keyHash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			r.cache(ctx, keyHash, apiKeyCacheMiss, apiKeyMissCacheTTL)
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	ttl := apiKeyCacheTTL
+	if key.ExpiresAt != nil {
+		ttl = min(ttl, time.Until(*key.ExpiresAt))
+	}
+	if ttl > 0 {
+		entry, err := json.Marshal(cachedAPIKey{
+			ID:        key.ID,
+			Type:      key.Type,
+			AppID:     key.AppID,
+			ExpiresAt: key.ExpiresAt,
+		})
+		if err == nil {
+			r.cache(ctx, keyHash, string(entry), ttl)
+		}
+	}
+
+	if key.IsExpired(time.Now()) || key.App == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) cache(ctx context.Context, keyHash, value string, ttl time.Duration) {
+	if err := r.Redis.Set(ctx, apiKeyCacheKey(keyHash), value, ttl).Err(); err != nil {
+		log.Printf("Failed to cache api key: %v", err)
+	}
+}
+
+// ListKeys returns the app's keys that have not been revoked, newest first.
+func (r *APIKeyRepository) ListKeys(ctx context.Context, appID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.WithContext(ctx).
+		Where("app_id = ?", appID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// Revoke soft deletes one of the app's keys and drops its cached lookup.
+func (r *APIKeyRepository) Revoke(ctx context.Context, appID, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).First(&key, "id = ? AND app_id = ?", id, appID).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Delete(&key).Error; err != nil {
+		return nil, err
+	}
+	if err := invalidateAPIKeys(ctx, r.Redis, key.KeyHash); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Rotate saves the app's new public key, schedules the previous keys of the
+// same type to expire at rotation.PreviousKeyExpiresAt, stores the new key and
+// records the rotation, all in one transaction. Cached lookups of the affected
+// keys are dropped afterwards.
+func (r *APIKeyRepository) Rotate(ctx context.Context, app *models.App, newKey *models.APIKey, rotation *models.KeyRotation) error {
+	var previousHashes []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Company").Save(app).Error; err != nil {
+			return err
+		}
+
+		previous := tx.Model(&models.APIKey{}).
+			Where("app_id = ? AND type = ?", app.ID, newKey.Type).
+			Where("(expires_at IS NULL OR expires_at > ?)", rotation.PreviousKeyExpiresAt).
+			Session(&gorm.Session{})
+		if err := previous.Pluck("key_hash", &previousHashes).Error; err != nil {
+			return err
+		}
+		if err := previous.Update("expires_at", rotation.PreviousKeyExpiresAt).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(newKey).Error; err != nil {
+			return err
+		}
+		return tx.Create(rotation).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return invalidateAPIKeys(ctx, r.Redis, append(previousHashes, newKey.KeyHash)...)
+}
+
+func (r *APIKeyRepository) ListRotations(ctx context.Context, appID string) ([]models.KeyRotation, error) {
+	var rotations []models.KeyRotation
+	err := r.db.WithContext(ctx).
+		Preload("RotatedBy").
+		Where("app_id = ?", appID).
+		Order("created_at DESC").
+		Find(&rotations).Error
+	return rotations, err
+}
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"bufio"
+	"confam-api/internal/crypto"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// fakeRedis is a Redis server that understands just enough RESP for the
+// cache: GET, SET and DEL. Other commands, such as the HELLO the client
+// starts with, are answered with an error.
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *goredis.Client) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeRedis{data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	rdb := goredis.NewClient(&goredis.Options{Addr: listener.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		rdb.Close()
+		listener.Close()
+	})
+	return server, rdb
+}
+
+func (s *fakeRedis) get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if value, ok := s.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			s.data[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := s.data[key]; ok {
+					delete(s.data, key)
+					deleted++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", deleted)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func newTestAPIKeyRepository(t *testing.T) (*APIKeyRepository, sqlmock.Sqlmock, *fakeRedis) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, rdb := newFakeRedis(t)
+	return NewAPIKeyRepository(db, rdb), mock, cache
+}
+
+const testSecret = "sk_test_secret"
+
+func expectKeyLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `api_keys` WHERE key_hash = ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash", "type", "app_id"}).
+			AddRow("key-1", crypto.HashAPIKey(testSecret), "test", "app-1"))
+	expectAppLookup(mock)
+}
+
+func expectAppLookup(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `apps`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "company_id"}).AddRow("app-1", "company-1"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `companies`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("company-1"))
+}
+
+func TestAPIKeyRepositoryFindBySecretCachesHits(t *testing.T) {
+	repo, mock, cache := newTestAPIKeyRepository(t)
+
+	expectKeyLookup(mock)
+	key, err := repo.FindBySecret(context.Background(), testSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "app-1", key.App.ID)
+	cached, ok := cache.get(apiKeyCacheKey(crypto.HashAPIKey(testSecret)))
+	assert.True(t, ok)
+	assert.Contains(t, cached, `"id":"key-1"`)
+
+	// Served from the cache, only the app is loaded.
+	expectAppLookup(mock)
+	key, err = repo.FindBySecret(context.Background(), testSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", key.ID)
+	assert.Equal(t, "company-1", key.App.Company.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepositoryFindBySecretCachesMisses(t *testing.T) {
+	repo, mock, cache := newTestAPIKeyRepository(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `api_keys` WHERE key_hash = ?")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	_, err := repo.FindBySecret(context.Background(), "sk_test_unknown")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	cached, _ := cache.get(apiKeyCacheKey(crypto.HashAPIKey("sk_test_unknown")))
+	assert.Equal(t, apiKeyCacheMiss, cached)
+
+	_, err = repo.FindBySecret(context.Background(), "sk_test_unknown")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAPIKeyRepositoryRevokeInvalidatesTheCache(t *testing.T) {
+	repo, mock, cache := newTestAPIKeyRepository(t)
+
+	expectKeyLookup(mock)
+	_, err := repo.FindBySecret(context.Background(), testSecret)
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `api_keys` WHERE (id = ? AND app_id = ?)")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash", "type", "app_id"}).
+			AddRow("key-1", crypto.HashAPIKey(testSecret), "test", "app-1"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `api_keys` SET `deleted_at`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	_, err = repo.Revoke(context.Background(), "app-1", "key-1")
+	assert.NoError(t, err)
+	_, ok := cache.get(apiKeyCacheKey(crypto.HashAPIKey(testSecret)))
+	assert.False(t, ok)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `api_keys` WHERE key_hash = ?")).
+		WillReturnError(gorm.ErrRecordNotFound)
+	_, err = repo.FindBySecret(context.Background(), testSecret)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
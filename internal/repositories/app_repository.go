@@ -4,13 +4,15 @@ import (
 	"confam-api/internal/models"
 	client "confam-api/internal/redis"
 	"context"
-	"fmt"
 
 	"gorm.io/gorm"
 )
 
 type IAppRepository interface {
-	CreateApp(ctx context.Context, app *models.App) error
+	CreateApp(ctx context.Context, app *models.App, webhookSecret *models.WebhookSecret, keys ...*models.APIKey) error
+	FindAppsByCompany(ctx context.Context, companyID string) ([]models.App, error)
+	FindCompanyApp(ctx context.Context, companyID, id string) (*models.App, error)
+	UpdateApp(ctx context.Context, app *models.App) error
 }
 
 type AppRepository struct {
@@ -25,35 +27,56 @@ func NewAppRepository(db *gorm.DB, rdb *client.Client) *AppRepository {
 	}
 }
 
-func (r *AppRepository) CreateApp(ctx context.Context, app *models.App) error {
-	if err := r.db.WithContext(ctx).Create(app).Error; err != nil {
+// CreateApp creates the app together with its webhook secret and secret keys.
+func (r *AppRepository) CreateApp(ctx context.Context, app *models.App, webhookSecret *models.WebhookSecret, keys ...*models.APIKey) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(app).Error; err != nil {
+			return err
+		}
+		if webhookSecret != nil {
+			webhookSecret.AppID = app.ID
+			if err := tx.Create(webhookSecret).Error; err != nil {
+				return err
+			}
+		}
+		for _, key := range keys {
+			key.AppID = app.ID
+			if err := tx.Create(key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
-	testSecret := app.TestSecretKey()
-	liveSecret := app.LiveSecretKey()
-	fmt.Println("Test Secret:", testSecret)
-	fmt.Println("Live Secret:", liveSecret)
-
-	// Write Test Secret to Redis
-	if err := r.Redis.Set(
-		ctx,
-		fmt.Sprintf("secret:%s", testSecret),
-		app.ID,
-		0,
-	).Err(); err != nil {
-		return fmt.Errorf("failed to save test secret to redis: %w", err)
+	// Drop any cached "not found" lookups of the new keys.
+	hashes := make([]string, 0, len(keys))
+	for _, key := range keys {
+		hashes = append(hashes, key.KeyHash)
 	}
+	return invalidateAPIKeys(ctx, r.Redis, hashes...)
+}
+
+func (r *AppRepository) FindAppsByCompany(ctx context.Context, companyID string) ([]models.App, error) {
+	var apps []models.App
+	err := r.db.WithContext(ctx).
+		Where("company_id = ?", companyID).
+		Order("created_at DESC").
+		Find(&apps).Error
+	return apps, err
+}
 
-	// Write Live Secret to Redis
-	if err := r.Redis.Set(
-		ctx,
-		fmt.Sprintf("secret:%s", liveSecret),
-		app.ID,
-		0,
-	).Err(); err != nil {
-		return fmt.Errorf("failed to save live secret to redis: %w", err)
+// FindCompanyApp finds an app by ID among the apps of a company.
+func (r *AppRepository) FindCompanyApp(ctx context.Context, companyID, id string) (*models.App, error) {
+	var app models.App
+	if err := r.db.WithContext(ctx).First(&app, "id = ? AND company_id = ?", id, companyID).Error; err != nil {
+		return nil, err
 	}
+	return &app, nil
+}
 
-	return nil
+func (r *AppRepository) UpdateApp(ctx context.Context, app *models.App) error {
+	return r.db.WithContext(ctx).Omit("Company").Save(app).Error
 }
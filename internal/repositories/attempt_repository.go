@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	client "confam-api/internal/redis"
+	"context"
+	"fmt"
+	"time"
+)
+
+type IAttemptRepository interface {
+	Increment(ctx context.Context, key string, window time.Duration) (int64, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// AttemptRepository counts failed attempts in Redis. The counter starts
+// expiring from the first attempt, so it resets once the window has passed.
+type AttemptRepository struct {
+	Redis *client.Client
+}
+
+func NewAttemptRepository(rdb *client.Client) *AttemptRepository {
+	return &AttemptRepository{Redis: rdb}
+}
+
+func attemptKey(key string) string {
+	return fmt.Sprintf("attempts:%s", key)
+}
+
+func (r *AttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	count, err := r.Redis.Incr(ctx, attemptKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count attempt: %w", err)
+	}
+	if count == 1 {
+		r.Redis.Expire(ctx, attemptKey(key), window)
+	}
+	return count, nil
+}
+
+func (r *AttemptRepository) Reset(ctx context.Context, key string) error {
+	return r.Redis.Del(ctx, attemptKey(key)).Err()
+}
@@ -9,10 +9,12 @@ import (
 
 type ICompanyRepository interface {
 	Create(ctx context.Context, company *models.Company) error
+	CreateWithOwner(ctx context.Context, company *models.Company, owner *models.CompanyUser) error
 	FindByID(ctx context.Context, id string) (*models.Company, error)
 	FindByEmail(ctx context.Context, email string) (*models.Company, error)
 	GetAll(ctx context.Context, limit, offset int) ([]models.Company, error)
 	Update(ctx context.Context, company *models.Company) error
+	UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error
 	Delete(ctx context.Context, id string) error
 	// Utility
 	Count(ctx context.Context) (int64, error)
@@ -31,6 +33,18 @@ func (r *CompanyRepository) Create(ctx context.Context, company *models.Company)
 	return r.db.WithContext(ctx).Create(company).Error
 }
 
+// CreateWithOwner saves a new company together with its owner account.
+func (r *CompanyRepository) CreateWithOwner(ctx context.Context, company *models.Company, owner *models.CompanyUser) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(company).Error; err != nil {
+			return err
+		}
+		owner.CompanyID = company.ID
+		owner.Role = models.RoleOwner
+		return tx.Create(owner).Error
+	})
+}
+
 func (r *CompanyRepository) FindByID(ctx context.Context, id string) (*models.Company, error) {
 	var company models.Company
 	if err := r.db.WithContext(ctx).First(&company, "id = ?", id).Error; err != nil {
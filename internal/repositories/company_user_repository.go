@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type ICompanyUserRepository interface {
+	Create(ctx context.Context, user *models.CompanyUser) error
+	FindByID(ctx context.Context, id string) (*models.CompanyUser, error)
+	FindByEmail(ctx context.Context, email string) (*models.CompanyUser, error)
+	FindByCompany(ctx context.Context, companyID, id string) (*models.CompanyUser, error)
+	ListByCompany(ctx context.Context, companyID string) ([]models.CompanyUser, error)
+	Update(ctx context.Context, user *models.CompanyUser) error
+	UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error
+	AcceptTOTPStep(ctx context.Context, id string, step int64) (bool, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type CompanyUserRepository struct {
+	db *gorm.DB
+}
+
+func NewCompanyUserRepository(db *gorm.DB) *CompanyUserRepository {
+	return &CompanyUserRepository{db: db}
+}
+
+func (r *CompanyUserRepository) Create(ctx context.Context, user *models.CompanyUser) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+// FindByID finds a user by its ID, including its company.
+func (r *CompanyUserRepository) FindByID(ctx context.Context, id string) (*models.CompanyUser, error) {
+	var user models.CompanyUser
+	if err := r.db.WithContext(ctx).Preload("Company").First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByEmail finds a user by its email, including its company.
+func (r *CompanyUserRepository) FindByEmail(ctx context.Context, email string) (*models.CompanyUser, error) {
+	var user models.CompanyUser
+	if err := r.db.WithContext(ctx).Preload("Company").First(&user, "email = ?", email).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByCompany finds a user by ID among the members of a company.
+func (r *CompanyUserRepository) FindByCompany(ctx context.Context, companyID, id string) (*models.CompanyUser, error) {
+	var user models.CompanyUser
+	if err := r.db.WithContext(ctx).First(&user, "id = ? AND company_id = ?", id, companyID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *CompanyUserRepository) ListByCompany(ctx context.Context, companyID string) ([]models.CompanyUser, error) {
+	var users []models.CompanyUser
+	err := r.db.WithContext(ctx).
+		Where("company_id = ?", companyID).
+		Order("created_at").
+		Find(&users).Error
+	return users, err
+}
+
+func (r *CompanyUserRepository) Update(ctx context.Context, user *models.CompanyUser) error {
+	return r.db.WithContext(ctx).Omit("Company").Save(user).Error
+}
+
+func (r *CompanyUserRepository) UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error {
+	return r.db.WithContext(ctx).
+		Model(&models.CompanyUser{}).
+		Where("id = ?", id).
+		Updates(fields).Error
+}
+
+// AcceptTOTPStep records step as the user's last accepted TOTP time step,
+// unless a code from that step or a later one was already accepted, in which
+// case it reports false.
+func (r *CompanyUserRepository) AcceptTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.CompanyUser{}).
+		Where("id = ?", id).
+		Where("two_factor_last_step IS NULL OR two_factor_last_step < ?", step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *CompanyUserRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Delete(&models.CompanyUser{}, "id = ?", id).Error
+}
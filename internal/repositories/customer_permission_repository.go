@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ICustomerPermissionRepository interface {
+	Create(ctx context.Context, permission *models.CustomerPermission) error
+	Update(ctx context.Context, permission *models.CustomerPermission) error
+	FindActive(ctx context.Context, customerID, companyID string) (*models.CustomerPermission, error)
+	FindExpired(ctx context.Context, now time.Time, limit int) ([]models.CustomerPermission, error)
+	ListByCustomer(ctx context.Context, customerID string) ([]models.CustomerPermission, error)
+	MarkExpired(ctx context.Context, permissionID string, at time.Time, outbox ...models.Webhook) (bool, error)
+	Revoke(ctx context.Context, permissionID string, at time.Time, outbox ...models.Webhook) (bool, error)
+}
+
+type CustomerPermissionRepository struct {
+	db *gorm.DB
+}
+
+func NewCustomerPermissionRepository(db *gorm.DB) *CustomerPermissionRepository {
+	return &CustomerPermissionRepository{db: db}
+}
+
+func (r *CustomerPermissionRepository) Create(ctx context.Context, permission *models.CustomerPermission) error {
+	return r.db.WithContext(ctx).Omit("Customer", "Company").Create(permission).Error
+}
+
+// Update saves the permission's scopes, access type and expiry.
+func (r *CustomerPermissionRepository) Update(ctx context.Context, permission *models.CustomerPermission) error {
+	return r.db.WithContext(ctx).Model(permission).
+		Select("Scopes", "AccessType", "ExpiresAt", "AppID", "RequestID").
+		Updates(permission).Error
+}
+
+// FindActive finds the customer's permission for the company that is neither
+// revoked nor expired.
+func (r *CustomerPermissionRepository) FindActive(ctx context.Context, customerID, companyID string) (*models.CustomerPermission, error) {
+	var permission models.CustomerPermission
+	err := r.db.WithContext(ctx).
+		Where("customer_id = ? AND company_id = ? AND revoked_at IS NULL", customerID, companyID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("granted_at DESC").
+		First(&permission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+// FindExpired returns up to limit unrevoked permissions whose expiry has
+// passed by now but that have not been marked expired yet, with their customer.
+func (r *CustomerPermissionRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]models.CustomerPermission, error) {
+	var permissions []models.CustomerPermission
+	err := r.db.WithContext(ctx).
+		Preload("Customer").
+		Where("expires_at <= ? AND expired_at IS NULL AND revoked_at IS NULL", now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&permissions).Error
+	return permissions, err
+}
+
+// ListByCustomer returns every permission the customer ever granted, with
+// its company, newest first.
+func (r *CustomerPermissionRepository) ListByCustomer(ctx context.Context, customerID string) ([]models.CustomerPermission, error) {
+	var permissions []models.CustomerPermission
+	err := r.db.WithContext(ctx).
+		Preload("Company").
+		Where("customer_id = ?", customerID).
+		Order("granted_at DESC").
+		Find(&permissions).Error
+	return permissions, err
+}
+
+// MarkExpired records that the permission expired, together with the
+// webhooks announcing it, reporting false if it had already been marked
+// expired or revoked.
+func (r *CustomerPermissionRepository) MarkExpired(ctx context.Context, permissionID string, at time.Time, outbox ...models.Webhook) (bool, error) {
+	return r.updateOnce(ctx, "id = ? AND expired_at IS NULL AND revoked_at IS NULL", permissionID, "expired_at", at, outbox)
+}
+
+// Revoke revokes the permission, together with the webhooks announcing it,
+// reporting false if it had already been revoked.
+func (r *CustomerPermissionRepository) Revoke(ctx context.Context, permissionID string, at time.Time, outbox ...models.Webhook) (bool, error) {
+	return r.updateOnce(ctx, "id = ? AND revoked_at IS NULL", permissionID, "revoked_at", at, outbox)
+}
+
+func (r *CustomerPermissionRepository) updateOnce(ctx context.Context, where, permissionID, column string, at time.Time, outbox []models.Webhook) (bool, error) {
+	updated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.CustomerPermission{}).Where(where, permissionID).Update(column, at)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		updated = true
+		return createOutbox(tx, outbox)
+	})
+	return updated, err
+}
@@ -10,10 +10,13 @@ import (
 type ICustomerRepository interface {
 	FindByID(ctx context.Context, id string) (*models.Customer, error)
 	FindByEmail(ctx context.Context, email string) (*models.Customer, error)
-	FindByEmailHash(email_hash string) (*models.Customer, error)
+	FindByEmailHash(email_hash string, environment models.Environment) (*models.Customer, error)
 	Create(customer *models.Customer) error
 	CreateIdentity(identity *models.Identity) error
+	UpdateIdentity(identity *models.Identity, outbox ...models.Webhook) error
+	UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error
 	CreateNextOfKin(next_of_kin *models.NextOfKin) error
+	ListBankAccounts(ctx context.Context, customerID string) ([]models.BankAccount, error)
 }
 
 type CustomerRepository struct {
@@ -26,7 +29,7 @@ func NewCustomerRepository(db *gorm.DB) *CustomerRepository {
 
 func (r *CustomerRepository) FindByID(ctx context.Context, id string) (*models.Customer, error) {
 	var customer models.Customer
-	if err := r.db.WithContext(ctx).First(&customer, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Identities").First(&customer, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 	return &customer, nil
@@ -39,10 +42,10 @@ func (r *CustomerRepository) FindByEmail(ctx context.Context, email string) (*mo
 	return &customer, result.Error
 }
 
-// FindByEmailHash finds a Customer by its email_hash.
-func (r *CustomerRepository) FindByEmailHash(email_hash string) (*models.Customer, error) {
+// FindByEmailHash finds a Customer of an environment by its email_hash.
+func (r *CustomerRepository) FindByEmailHash(email_hash string, environment models.Environment) (*models.Customer, error) {
 	var customer models.Customer
-	result := r.db.Preload("Identities").First(&customer, "email_hash = ?", email_hash)
+	result := r.db.Preload("Identities").First(&customer, "email_hash = ? AND environment = ?", email_hash, environment)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -58,6 +61,33 @@ func (r *CustomerRepository) CreateIdentity(identity *models.Identity) error {
 	return r.db.Create(identity).Error
 }
 
+// UpdateIdentity saves the identity along with the webhooks announcing the
+// change.
+func (r *CustomerRepository) UpdateIdentity(identity *models.Identity, outbox ...models.Webhook) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Customer").Save(identity).Error; err != nil {
+			return err
+		}
+		return createOutbox(tx, outbox)
+	})
+}
+
+func (r *CustomerRepository) UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Customer{}).
+		Where("id = ?", id).
+		Updates(fields).Error
+}
+
 func (r *CustomerRepository) CreateNextOfKin(next_of_kin *models.NextOfKin) error {
 	return r.db.Create(next_of_kin).Error
 }
+
+func (r *CustomerRepository) ListBankAccounts(ctx context.Context, customerID string) ([]models.BankAccount, error) {
+	var accounts []models.BankAccount
+	err := r.db.WithContext(ctx).
+		Where("customer_id = ?", customerID).
+		Order("created_at ASC").
+		Find(&accounts).Error
+	return accounts, err
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IInvitationRepository interface {
+	Create(ctx context.Context, invitation *models.Invitation) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error)
+	FindByCompany(ctx context.Context, companyID, id string) (*models.Invitation, error)
+	ListPendingByCompany(ctx context.Context, companyID string) ([]models.Invitation, error)
+	Accept(ctx context.Context, invitation *models.Invitation, user *models.CompanyUser) error
+	Delete(ctx context.Context, id string) error
+}
+
+type InvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+func (r *InvitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *InvitationRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := r.db.WithContext(ctx).Preload("Company").First(&invitation, "token_hash = ?", tokenHash).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *InvitationRepository) FindByCompany(ctx context.Context, companyID, id string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := r.db.WithContext(ctx).First(&invitation, "id = ? AND company_id = ?", id, companyID).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *InvitationRepository) ListPendingByCompany(ctx context.Context, companyID string) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.WithContext(ctx).
+		Where("company_id = ? AND accepted_at IS NULL AND expires_at > ?", companyID, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// Accept creates the invited user and marks the invitation accepted in one transaction.
+// The update is conditional so an invitation can only ever be accepted once.
+func (r *InvitationRepository) Accept(ctx context.Context, invitation *models.Invitation, user *models.CompanyUser) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.ID).
+			Update("accepted_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Create(user).Error
+	})
+}
+
+func (r *InvitationRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Delete(&models.Invitation{}, "id = ?", id).Error
+}
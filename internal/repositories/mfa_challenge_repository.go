@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	client "confam-api/internal/redis"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var ErrMFAChallengeNotFound = errors.New("mfa challenge not found")
+
+type IMFAChallengeRepository interface {
+	Save(ctx context.Context, userID, tokenHash string, ttl time.Duration) error
+	Consume(ctx context.Context, tokenHash string) (string, error)
+}
+
+// MFAChallengeRepository stores the hashes of the challenge tokens issued to
+// users who still have to enter a two-factor code, so each can be used once.
+type MFAChallengeRepository struct {
+	Redis *client.Client
+}
+
+func NewMFAChallengeRepository(rdb *client.Client) *MFAChallengeRepository {
+	return &MFAChallengeRepository{Redis: rdb}
+}
+
+func mfaChallengeKey(tokenHash string) string {
+	return fmt.Sprintf("mfa_challenge:%s", tokenHash)
+}
+
+func (r *MFAChallengeRepository) Save(ctx context.Context, userID, tokenHash string, ttl time.Duration) error {
+	if err := r.Redis.Set(ctx, mfaChallengeKey(tokenHash), userID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save mfa challenge: %w", err)
+	}
+	return nil
+}
+
+// Consume atomically fetches and deletes a challenge, returning the user ID it belongs to.
+func (r *MFAChallengeRepository) Consume(ctx context.Context, tokenHash string) (string, error) {
+	userID, err := r.Redis.GetDel(ctx, mfaChallengeKey(tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return "", ErrMFAChallengeNotFound
+		}
+		return "", fmt.Errorf("failed to consume mfa challenge: %w", err)
+	}
+	return userID, nil
+}
@@ -1,12 +1,22 @@
 package mocks
 
-import "confam-api/internal/models"
+import (
+	"confam-api/internal/models"
+	"confam-api/internal/repositories"
+	"time"
+)
 
 type MockRequestRepository struct {
 	FindByTokenFunc      func(kyc_token string) (*models.Request, error)
 	CountByReferenceFunc func(reference string) (int64, error)
 	FindByReferenceFunc  func(reference string) (*models.Request, error)
 	CreateFunc           func(request *models.Request) error
+	TransitionFunc       func(request *models.Request, to models.RequestStatus, reason string) (*models.RequestEvent, error)
+	CompleteFunc         func(request *models.Request, completion repositories.RequestCompletion) error
+	ListEventsFunc       func(requestID string) ([]models.RequestEvent, error)
+	UpdateTokenFunc      func(request *models.Request) error
+	FindExpiredFunc      func(before time.Time, limit int) ([]models.Request, error)
+	ListByCustomerFunc   func(customerID string) ([]models.Request, error)
 
 	// Fields to track calls and arguments
 	FindByTokenCalled     bool
@@ -20,6 +30,9 @@ type MockRequestRepository struct {
 
 	CreateCalled     bool
 	CreateCalledWith *models.Request
+
+	// Outbox collects the webhooks passed to Create, Transition and Complete.
+	Outbox []models.Webhook
 }
 
 func (m *MockRequestRepository) FindByToken(kyc_token string) (*models.Request, error) {
@@ -32,7 +45,7 @@ func (m *MockRequestRepository) FindByToken(kyc_token string) (*models.Request,
 	return nil, nil
 }
 
-func (m *MockRequestRepository) CountByReference(reference string) (int64, error) {
+func (m *MockRequestRepository) CountByReference(companyID string, environment models.Environment, reference string) (int64, error) {
 	m.CountByReferenceCalled = true
 	m.CountByReferenceCalledWith = reference
 
@@ -42,7 +55,7 @@ func (m *MockRequestRepository) CountByReference(reference string) (int64, error
 	return 0, nil
 }
 
-func (m *MockRequestRepository) FindByReference(reference string) (*models.Request, error) {
+func (m *MockRequestRepository) FindByReference(companyID string, environment models.Environment, reference string) (*models.Request, error) {
 	m.FindByReferenceCalled = true
 	m.FindByReferenceCalledWith = reference
 
@@ -52,12 +65,69 @@ func (m *MockRequestRepository) FindByReference(reference string) (*models.Reque
 	return nil, nil
 }
 
-func (m *MockRequestRepository) Create(request *models.Request) error {
+func (m *MockRequestRepository) Create(request *models.Request, outbox ...models.Webhook) error {
 	m.CreateCalled = true
 	m.CreateCalledWith = request
 
 	if m.CreateFunc != nil {
-		return m.CreateFunc(request)
+		if err := m.CreateFunc(request); err != nil {
+			return err
+		}
 	}
+	m.Outbox = append(m.Outbox, outbox...)
 	return nil
 }
+
+func (m *MockRequestRepository) Transition(request *models.Request, to models.RequestStatus, reason string, outbox ...models.Webhook) (*models.RequestEvent, error) {
+	if m.TransitionFunc != nil {
+		event, err := m.TransitionFunc(request, to, reason)
+		if err == nil {
+			m.Outbox = append(m.Outbox, outbox...)
+		}
+		return event, err
+	}
+	from := request.Status
+	request.Status = to
+	m.Outbox = append(m.Outbox, outbox...)
+	return &models.RequestEvent{RequestID: request.ID, FromStatus: &from, ToStatus: to}, nil
+}
+
+func (m *MockRequestRepository) Complete(request *models.Request, completion repositories.RequestCompletion, outbox ...models.Webhook) (*models.RequestEvent, error) {
+	if m.CompleteFunc != nil {
+		if err := m.CompleteFunc(request, completion); err != nil {
+			return nil, err
+		}
+	}
+	from := request.Status
+	request.Status = models.RequestStatusCompleted
+	m.Outbox = append(m.Outbox, outbox...)
+	return &models.RequestEvent{RequestID: request.ID, FromStatus: &from, ToStatus: request.Status}, nil
+}
+
+func (m *MockRequestRepository) ListEvents(requestID string) ([]models.RequestEvent, error) {
+	if m.ListEventsFunc != nil {
+		return m.ListEventsFunc(requestID)
+	}
+	return nil, nil
+}
+
+func (m *MockRequestRepository) UpdateToken(request *models.Request) error {
+	if m.UpdateTokenFunc != nil {
+		return m.UpdateTokenFunc(request)
+	}
+	return nil
+}
+
+func (m *MockRequestRepository) FindExpired(before time.Time, limit int) ([]models.Request, error) {
+	if m.FindExpiredFunc != nil {
+		return m.FindExpiredFunc(before, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockRequestRepository) ListByCustomer(customerID string) ([]models.Request, error) {
+	if m.ListByCustomerFunc != nil {
+		return m.ListByCustomerFunc(customerID)
+	}
+	return nil, nil
+}
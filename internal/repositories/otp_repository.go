@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	client "confam-api/internal/redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var ErrOTPNotFound = errors.New("otp not found")
+
+type IOTPRepository interface {
+	Save(ctx context.Context, requestID string, otp *models.OTP, ttl time.Duration) error
+	Find(ctx context.Context, requestID string) (*models.OTP, error)
+	Delete(ctx context.Context, requestID string) error
+}
+
+// OTPRepository keeps the pending OTP of each KYC request in Redis. Only the
+// latest OTP sent for a request is kept.
+type OTPRepository struct {
+	Redis *client.Client
+}
+
+func NewOTPRepository(rdb *client.Client) *OTPRepository {
+	return &OTPRepository{Redis: rdb}
+}
+
+func otpKey(requestID string) string {
+	return fmt.Sprintf("otp:%s", requestID)
+}
+
+func (r *OTPRepository) Save(ctx context.Context, requestID string, otp *models.OTP, ttl time.Duration) error {
+	data, err := json.Marshal(otp)
+	if err != nil {
+		return fmt.Errorf("failed to encode otp: %w", err)
+	}
+	if err := r.Redis.Set(ctx, otpKey(requestID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save otp: %w", err)
+	}
+	return nil
+}
+
+func (r *OTPRepository) Find(ctx context.Context, requestID string) (*models.OTP, error) {
+	data, err := r.Redis.Get(ctx, otpKey(requestID)).Bytes()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrOTPNotFound
+		}
+		return nil, fmt.Errorf("failed to read otp: %w", err)
+	}
+
+	var otp models.OTP
+	if err := json.Unmarshal(data, &otp); err != nil {
+		return nil, fmt.Errorf("failed to decode otp: %w", err)
+	}
+	return &otp, nil
+}
+
+func (r *OTPRepository) Delete(ctx context.Context, requestID string) error {
+	return r.Redis.Del(ctx, otpKey(requestID)).Err()
+}
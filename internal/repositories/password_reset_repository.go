@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	client "confam-api/internal/redis"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var ErrResetTokenNotFound = errors.New("password reset token not found")
+
+type IPasswordResetRepository interface {
+	Save(ctx context.Context, userID, tokenHash string, ttl time.Duration) error
+	Consume(ctx context.Context, tokenHash string) (string, error)
+}
+
+// PasswordResetRepository stores hashed password reset tokens in Redis.
+// Only the latest token issued for a user is kept; issuing a new one revokes the previous.
+type PasswordResetRepository struct {
+	Redis *client.Client
+}
+
+func NewPasswordResetRepository(rdb *client.Client) *PasswordResetRepository {
+	return &PasswordResetRepository{Redis: rdb}
+}
+
+func resetTokenKey(tokenHash string) string {
+	return fmt.Sprintf("password_reset:%s", tokenHash)
+}
+
+func resetUserKey(userID string) string {
+	return fmt.Sprintf("password_reset:user:%s", userID)
+}
+
+func (r *PasswordResetRepository) Save(ctx context.Context, userID, tokenHash string, ttl time.Duration) error {
+	previous, err := r.Redis.Get(ctx, resetUserKey(userID)).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return fmt.Errorf("failed to read previous reset token: %w", err)
+	}
+
+	pipe := r.Redis.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, resetTokenKey(previous))
+	}
+	pipe.Set(ctx, resetTokenKey(tokenHash), userID, ttl)
+	pipe.Set(ctx, resetUserKey(userID), tokenHash, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save reset token: %w", err)
+	}
+	return nil
+}
+
+// Consume atomically fetches and deletes a reset token, returning the user ID it belongs to.
+func (r *PasswordResetRepository) Consume(ctx context.Context, tokenHash string) (string, error) {
+	userID, err := r.Redis.GetDel(ctx, resetTokenKey(tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return "", ErrResetTokenNotFound
+		}
+		return "", fmt.Errorf("failed to consume reset token: %w", err)
+	}
+	r.Redis.Del(ctx, resetUserKey(userID))
+	return userID, nil
+}
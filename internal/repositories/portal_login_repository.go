@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	client "confam-api/internal/redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var ErrPortalLoginNotFound = errors.New("portal login not found")
+
+type IPortalLoginRepository interface {
+	Save(ctx context.Context, login *models.PortalLogin, ttl time.Duration) error
+	FindByCustomer(ctx context.Context, customerID string) (*models.PortalLogin, error)
+	FindByToken(ctx context.Context, tokenHash string) (*models.PortalLogin, error)
+	Delete(ctx context.Context, login *models.PortalLogin) error
+}
+
+// PortalLoginRepository keeps pending customer portal logins in Redis. Only
+// the latest login issued for a customer is kept; issuing a new one revokes
+// the previous.
+//
+//	portal_login:<customer id>       JSON encoded models.PortalLogin
+//	portal_login:token:<token hash>  ID of the customer the magic link is for
+type PortalLoginRepository struct {
+	Redis *client.Client
+}
+
+func NewPortalLoginRepository(rdb *client.Client) *PortalLoginRepository {
+	return &PortalLoginRepository{Redis: rdb}
+}
+
+func portalLoginKey(customerID string) string {
+	return fmt.Sprintf("portal_login:%s", customerID)
+}
+
+func portalLoginTokenKey(tokenHash string) string {
+	return fmt.Sprintf("portal_login:token:%s", tokenHash)
+}
+
+func (r *PortalLoginRepository) Save(ctx context.Context, login *models.PortalLogin, ttl time.Duration) error {
+	previous, err := r.FindByCustomer(ctx, login.CustomerID)
+	if err != nil && !errors.Is(err, ErrPortalLoginNotFound) {
+		return err
+	}
+	data, err := json.Marshal(login)
+	if err != nil {
+		return fmt.Errorf("failed to encode portal login: %w", err)
+	}
+
+	pipe := r.Redis.TxPipeline()
+	if previous != nil {
+		pipe.Del(ctx, portalLoginTokenKey(previous.TokenHash))
+	}
+	pipe.Set(ctx, portalLoginKey(login.CustomerID), data, ttl)
+	pipe.Set(ctx, portalLoginTokenKey(login.TokenHash), login.CustomerID, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save portal login: %w", err)
+	}
+	return nil
+}
+
+func (r *PortalLoginRepository) FindByCustomer(ctx context.Context, customerID string) (*models.PortalLogin, error) {
+	data, err := r.Redis.Get(ctx, portalLoginKey(customerID)).Bytes()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrPortalLoginNotFound
+		}
+		return nil, fmt.Errorf("failed to read portal login: %w", err)
+	}
+	var login models.PortalLogin
+	if err := json.Unmarshal(data, &login); err != nil {
+		return nil, fmt.Errorf("failed to decode portal login: %w", err)
+	}
+	return &login, nil
+}
+
+func (r *PortalLoginRepository) FindByToken(ctx context.Context, tokenHash string) (*models.PortalLogin, error) {
+	customerID, err := r.Redis.Get(ctx, portalLoginTokenKey(tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrPortalLoginNotFound
+		}
+		return nil, fmt.Errorf("failed to read portal login: %w", err)
+	}
+	return r.FindByCustomer(ctx, customerID)
+}
+
+// Delete removes the login so that neither its link nor its code can be used again.
+func (r *PortalLoginRepository) Delete(ctx context.Context, login *models.PortalLogin) error {
+	return r.Redis.Del(ctx, portalLoginKey(login.CustomerID), portalLoginTokenKey(login.TokenHash)).Err()
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IRecoveryCodeRepository interface {
+	Replace(ctx context.Context, userID string, codeHashes []string) error
+	Consume(ctx context.Context, userID, codeHash string) (bool, error)
+	DeleteAll(ctx context.Context, userID string) error
+}
+
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+// Replace deletes the user's existing recovery codes and stores the new set.
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID string, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// Consume marks an unused recovery code as used, reporting whether one matched.
+func (r *RecoveryCodeRepository) Consume(ctx context.Context, userID, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *RecoveryCodeRepository) DeleteAll(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&models.RecoveryCode{}).Error
+}
@@ -2,15 +2,37 @@ package repositories
 
 import (
 	"confam-api/internal/models"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// ErrRequestStatusChanged is returned by Transition when the request's status
+// was changed by someone else since it was read.
+var ErrRequestStatusChanged = errors.New("request status has changed")
+
 type IRequestRepository interface {
 	FindByToken(kyc_token string) (*models.Request, error)
-	CountByReference(reference string) (int64, error)
-	FindByReference(reference string) (*models.Request, error)
-	Create(request *models.Request) error
+	CountByReference(companyID string, environment models.Environment, reference string) (int64, error)
+	FindByReference(companyID string, environment models.Environment, reference string) (*models.Request, error)
+	Create(request *models.Request, outbox ...models.Webhook) error
+	Transition(request *models.Request, to models.RequestStatus, reason string, outbox ...models.Webhook) (*models.RequestEvent, error)
+	Complete(request *models.Request, completion RequestCompletion, outbox ...models.Webhook) (*models.RequestEvent, error)
+	ListEvents(requestID string) ([]models.RequestEvent, error)
+	UpdateToken(request *models.Request) error
+	FindExpired(before time.Time, limit int) ([]models.Request, error)
+	ListByCustomer(customerID string) ([]models.Request, error)
+}
+
+// RequestCompletion is what completing a request changes besides its status:
+// the customer's fields and the permission granted to the request's company.
+// A permission without an ID is created, any other is updated. Nil fields and
+// a nil permission are left alone.
+type RequestCompletion struct {
+	CustomerID     string
+	CustomerFields map[string]interface{}
+	Permission     *models.CustomerPermission
 }
 
 type RequestRepository struct {
@@ -27,20 +49,150 @@ func (r *RequestRepository) FindByToken(kyc_token string) (*models.Request, erro
 	return &request, result.Error
 }
 
-func (r *RequestRepository) CountByReference(reference string) (int64, error) {
+// CountByReference counts the company's requests in an environment that use the reference.
+func (r *RequestRepository) CountByReference(companyID string, environment models.Environment, reference string) (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Request{}).
-		Where("reference = ?", reference).
+		Where("company_id = ? AND environment = ? AND reference = ?", companyID, environment, reference).
 		Count(&count).Error
 	return count, err
 }
 
-func (r *RequestRepository) FindByReference(reference string) (*models.Request, error) {
+// FindByReference finds one of the company's requests in an environment by its reference.
+func (r *RequestRepository) FindByReference(companyID string, environment models.Environment, reference string) (*models.Request, error) {
 	var request models.Request
-	result := r.db.First(&request, "reference = ?", reference)
+	result := r.db.First(&request, "company_id = ? AND environment = ? AND reference = ?", companyID, environment, reference)
 	return &request, result.Error
 }
 
-func (r *RequestRepository) Create(request *models.Request) error {
-	return r.db.Create(request).Error
+// Create creates the request together with the event recording its initial
+// status and the webhooks announcing it.
+func (r *RequestRepository) Create(request *models.Request, outbox ...models.Webhook) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		status := request.Status
+		if status == "" {
+			status = models.RequestStatusInitiated
+		}
+		if err := tx.Create(&models.RequestEvent{RequestID: request.ID, ToStatus: status}).Error; err != nil {
+			return err
+		}
+		return createOutbox(tx, outbox)
+	})
+}
+
+// Transition moves the request from its current status to the given one and
+// records the change along with the webhooks announcing it. The update only
+// applies if the status stored is still request.Status, otherwise
+// ErrRequestStatusChanged is returned.
+func (r *RequestRepository) Transition(request *models.Request, to models.RequestStatus, reason string, outbox ...models.Webhook) (*models.RequestEvent, error) {
+	return r.transition(request, to, reason, outbox, nil)
+}
+
+// Complete moves the request to completed like Transition and, in the same
+// transaction, applies the completion to the customer and their permissions.
+func (r *RequestRepository) Complete(request *models.Request, completion RequestCompletion, outbox ...models.Webhook) (*models.RequestEvent, error) {
+	return r.transition(request, models.RequestStatusCompleted, "", outbox, func(tx *gorm.DB) error {
+		if len(completion.CustomerFields) > 0 {
+			err := tx.Model(&models.Customer{}).
+				Where("id = ?", completion.CustomerID).
+				Updates(completion.CustomerFields).Error
+			if err != nil {
+				return err
+			}
+		}
+		permission := completion.Permission
+		if permission == nil {
+			return nil
+		}
+		if permission.ID == "" {
+			return tx.Omit("Customer", "Company").Create(permission).Error
+		}
+		return tx.Model(permission).
+			Select("Scopes", "AccessType", "ExpiresAt", "AppID", "RequestID").
+			Updates(permission).Error
+	})
+}
+
+// transition runs the status change of Transition and, if given, apply in a
+// single transaction.
+func (r *RequestRepository) transition(request *models.Request, to models.RequestStatus, reason string, outbox []models.Webhook, apply func(tx *gorm.DB) error) (*models.RequestEvent, error) {
+	from := request.Status
+	event := &models.RequestEvent{
+		RequestID:  request.ID,
+		FromStatus: &from,
+		ToStatus:   to,
+	}
+	if reason != "" {
+		event.Reason = &reason
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Request{}).
+			Where("id = ? AND status = ?", request.ID, from).
+			Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRequestStatusChanged
+		}
+		if apply != nil {
+			if err := apply(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		return createOutbox(tx, outbox)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = to
+	return event, nil
+}
+
+// ListEvents returns the request's status history, oldest first.
+func (r *RequestRepository) ListEvents(requestID string) ([]models.RequestEvent, error) {
+	var events []models.RequestEvent
+	err := r.db.Where("request_id = ?", requestID).Order("created_at ASC").Find(&events).Error
+	return events, err
+}
+
+// UpdateToken saves the request's KYC token, its expiry and the allow URL.
+func (r *RequestRepository) UpdateToken(request *models.Request) error {
+	return r.db.Model(&models.Request{}).
+		Where("id = ?", request.ID).
+		Updates(map[string]interface{}{
+			"kyc_token":        request.KYCToken,
+			"token_expires_at": request.TokenExpiresAt,
+			"allow_url":        request.AllowURL,
+		}).Error
+}
+
+// FindExpired returns up to limit requests still waiting on the customer
+// whose token expired before the given time.
+func (r *RequestRepository) FindExpired(before time.Time, limit int) ([]models.Request, error) {
+	var requests []models.Request
+	err := r.db.
+		Where("token_expires_at < ? AND status IN ?", before, []models.RequestStatus{models.RequestStatusInitiated, models.RequestStatusOTPPending}).
+		Order("token_expires_at ASC").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+// ListByCustomer returns the customer's requests with their company, newest first.
+func (r *RequestRepository) ListByCustomer(customerID string) ([]models.Request, error) {
+	var requests []models.Request
+	err := r.db.Preload("Company").
+		Where("customer_id = ?", customerID).
+		Order("created_at DESC").
+		Find(&requests).Error
+	return requests, err
 }
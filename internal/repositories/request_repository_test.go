@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newTestRequestRepository(t *testing.T) (IRequestRepository, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRequestRepository(db), mock
+}
+
+func testCompletion() RequestCompletion {
+	return RequestCompletion{
+		CustomerID:     "customer-1",
+		CustomerFields: map[string]interface{}{"kyc_level_achieved": models.KYCLevelTier1},
+		Permission: &models.CustomerPermission{
+			CustomerID: "customer-1",
+			CompanyID:  "company-1",
+			Scopes:     models.PermissionScopes{models.ScopeIdentity},
+			AccessType: models.AccessPermanent,
+		},
+	}
+}
+
+func TestRequestRepositoryCompleteCommitsEverythingTogether(t *testing.T) {
+	repo, mock := newTestRequestRepository(t)
+	request := &models.Request{ID: "request-1", Status: models.RequestStatusKYCProcessing}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `requests` SET `status`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `customers` SET `kyc_level_achieved`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `customer_permissions`")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `request_events`")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, err := repo.Complete(request, testCompletion())
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusCompleted, event.ToStatus)
+	assert.Equal(t, models.RequestStatusCompleted, request.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestRepositoryCompleteRollsBackWhenTheGrantFails(t *testing.T) {
+	repo, mock := newTestRequestRepository(t)
+	request := &models.Request{ID: "request-1", Status: models.RequestStatusKYCProcessing}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `requests` SET `status`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `customers` SET `kyc_level_achieved`=?")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `customer_permissions`")).WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	_, err := repo.Complete(request, testCompletion())
+	assert.Error(t, err)
+	assert.Equal(t, models.RequestStatusKYCProcessing, request.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestRepositoryCompleteRefusesAChangedStatus(t *testing.T) {
+	repo, mock := newTestRequestRepository(t)
+	request := &models.Request{ID: "request-1", Status: models.RequestStatusKYCProcessing}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `requests` SET `status`=?")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err := repo.Complete(request, testCompletion())
+	assert.ErrorIs(t, err, ErrRequestStatusChanged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
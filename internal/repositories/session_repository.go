@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	client "confam-api/internal/redis"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+type ISessionRepository interface {
+	Create(ctx context.Context, session *models.Session, ttl time.Duration) error
+	Find(ctx context.Context, id string) (*models.Session, error)
+	Exists(ctx context.Context, id string) (bool, error)
+	Rotate(ctx context.Context, id, presentedHash, newHash string, ttl time.Duration) (*models.Session, error)
+	ListByUser(ctx context.Context, userID string) ([]models.Session, error)
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+}
+
+// SessionRepository keeps login sessions in Redis.
+//
+//	session:<id>               JSON encoded models.Session
+//	session:<id>:used          refresh token hashes already rotated out of the session
+//	sessions:user:<id>         IDs of the user's sessions
+type SessionRepository struct {
+	Redis *client.Client
+}
+
+func NewSessionRepository(rdb *client.Client) *SessionRepository {
+	return &SessionRepository{Redis: rdb}
+}
+
+func sessionKey(id string) string {
+	return fmt.Sprintf("session:%s", id)
+}
+
+func sessionUsedKey(id string) string {
+	return fmt.Sprintf("session:%s:used", id)
+}
+
+func userSessionsKey(userID string) string {
+	return fmt.Sprintf("sessions:user:%s", userID)
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	pipe := r.Redis.TxPipeline()
+	pipe.Set(ctx, sessionKey(session.ID), data, ttl)
+	pipe.SAdd(ctx, userSessionsKey(session.UserID), session.ID)
+	pipe.Expire(ctx, userSessionsKey(session.UserID), ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Find(ctx context.Context, id string) (*models.Session, error) {
+	data, err := r.Redis.Get(ctx, sessionKey(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var session models.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *SessionRepository) Exists(ctx context.Context, id string) (bool, error) {
+	count, err := r.Redis.Exists(ctx, sessionKey(id)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return count > 0, nil
+}
+
+// maxRotateAttempts bounds how many times Rotate retries after the session
+// changed between reading and writing it.
+const maxRotateAttempts = 5
+
+// Rotate replaces the session's refresh token hash when presentedHash is the current one.
+// Presenting a hash that was already rotated out returns ErrRefreshTokenReused. When the
+// session changes while it is being rotated, the rotation is retried against the new state,
+// so only a token that really was rotated out is reported as reused.
+func (r *SessionRepository) Rotate(ctx context.Context, id, presentedHash, newHash string, ttl time.Duration) (*models.Session, error) {
+	for attempt := 0; attempt < maxRotateAttempts; attempt++ {
+		session, err := r.rotate(ctx, id, presentedHash, newHash, ttl)
+		if errors.Is(err, goredis.TxFailedErr) {
+			continue
+		}
+		return session, err
+	}
+	return nil, fmt.Errorf("failed to rotate session: %w", goredis.TxFailedErr)
+}
+
+func (r *SessionRepository) rotate(ctx context.Context, id, presentedHash, newHash string, ttl time.Duration) (*models.Session, error) {
+	var session models.Session
+	err := r.Redis.Watch(ctx, func(tx *goredis.Tx) error {
+		data, err := tx.Get(ctx, sessionKey(id)).Bytes()
+		if err != nil {
+			if errors.Is(err, goredis.Nil) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+		if err := json.Unmarshal(data, &session); err != nil {
+			return fmt.Errorf("failed to decode session: %w", err)
+		}
+
+		if session.TokenHash != presentedHash {
+			used, err := tx.SIsMember(ctx, sessionUsedKey(id), presentedHash).Result()
+			if err != nil {
+				return err
+			}
+			if used {
+				return ErrRefreshTokenReused
+			}
+			return ErrSessionNotFound
+		}
+
+		now := time.Now()
+		session.TokenHash = newHash
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(ttl)
+		encoded, err := json.Marshal(session)
+		if err != nil {
+			return fmt.Errorf("failed to encode session: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Set(ctx, sessionKey(id), encoded, ttl)
+			pipe.SAdd(ctx, sessionUsedKey(id), presentedHash)
+			pipe.Expire(ctx, sessionUsedKey(id), ttl)
+			pipe.Expire(ctx, userSessionsKey(session.UserID), ttl)
+			return nil
+		})
+		return err
+	}, sessionKey(id))
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListByUser returns the user's live sessions, pruning IDs whose session has expired.
+func (r *SessionRepository) ListByUser(ctx context.Context, userID string) ([]models.Session, error) {
+	ids, err := r.Redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]models.Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := r.Find(ctx, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			r.Redis.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, id string) error {
+	session, err := r.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.Redis.TxPipeline()
+	pipe.Del(ctx, sessionKey(id), sessionUsedKey(id))
+	pipe.SRem(ctx, userSessionsKey(session.UserID), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	ids, err := r.Redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	pipe := r.Redis.TxPipeline()
+	for _, id := range ids {
+		pipe.Del(ctx, sessionKey(id), sessionUsedKey(id))
+	}
+	pipe.Del(ctx, userSessionsKey(userID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
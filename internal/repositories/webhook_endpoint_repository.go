@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type IWebhookEndpointRepository interface {
+	Create(ctx context.Context, endpoint *models.WebhookEndpoint, secret *models.WebhookSecret) error
+	List(ctx context.Context, appID string) ([]models.WebhookEndpoint, error)
+	Find(ctx context.Context, appID, id string) (*models.WebhookEndpoint, error)
+	Update(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	Delete(ctx context.Context, endpoint *models.WebhookEndpoint) error
+}
+
+type WebhookEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEndpointRepository(db *gorm.DB) *WebhookEndpointRepository {
+	return &WebhookEndpointRepository{db: db}
+}
+
+// Create creates the endpoint together with the first secret it signs with.
+func (r *WebhookEndpointRepository) Create(ctx context.Context, endpoint *models.WebhookEndpoint, secret *models.WebhookSecret) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(endpoint).Error; err != nil {
+			return err
+		}
+		secret.AppID = endpoint.AppID
+		secret.EndpointID = &endpoint.ID
+		return tx.Create(secret).Error
+	})
+}
+
+// List returns the app's endpoints, oldest first.
+func (r *WebhookEndpointRepository) List(ctx context.Context, appID string) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.WithContext(ctx).
+		Where("app_id = ?", appID).
+		Order("created_at ASC").
+		Find(&endpoints).Error
+	return endpoints, err
+}
+
+// Find finds an endpoint by ID among the endpoints of an app.
+func (r *WebhookEndpointRepository) Find(ctx context.Context, appID, id string) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := r.db.WithContext(ctx).First(&endpoint, "id = ? AND app_id = ?", id, appID).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *WebhookEndpointRepository) Update(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return r.db.WithContext(ctx).Save(endpoint).Error
+}
+
+// Delete soft deletes the endpoint and fails the webhooks still waiting to be
+// delivered to it, in one transaction.
+func (r *WebhookEndpointRepository) Delete(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(endpoint).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Webhook{}).
+			Where("endpoint_id = ? AND status = ?", endpoint.ID, models.WebhookStatusPending).
+			Updates(map[string]interface{}{
+				"status":        models.WebhookStatusFailed,
+				"error_message": "the webhook endpoint was deleted",
+			}).Error
+	})
+}
@@ -0,0 +1,227 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IWebhookRepository interface {
+	Create(ctx context.Context, webhooks []models.Webhook) error
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Webhook, error)
+	RecordAttempt(ctx context.Context, webhook *models.Webhook, attempt *models.WebhookAttempt) error
+	Reschedule(ctx context.Context, webhookID string, at time.Time) error
+	List(ctx context.Context, appID string, filter WebhookFilter, offset, limit int) ([]models.Webhook, int64, error)
+	FindAppWebhook(ctx context.Context, appID, id string) (*models.Webhook, error)
+	ListAttempts(ctx context.Context, webhookID string) ([]models.WebhookAttempt, error)
+	Replay(ctx context.Context, appID, id, targetURL string) error
+	ReplayFailed(ctx context.Context, appID string, from, to time.Time, targetURL string) (int64, error)
+}
+
+// WebhookFilter narrows down the webhooks listed. Empty fields match any webhook.
+type WebhookFilter struct {
+	EventType string
+	Status    models.WebhookStatus
+	Reference string
+	From      *time.Time
+	To        *time.Time
+}
+
+// WebhookRepository is the outbox of webhooks waiting to be delivered.
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// createOutbox stores webhooks as part of the transaction tx, so they are
+// only sent if the change they announce is committed.
+func createOutbox(tx *gorm.DB, outbox []models.Webhook) error {
+	if len(outbox) == 0 {
+		return nil
+	}
+	return tx.Create(&outbox).Error
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhooks []models.Webhook) error {
+	return createOutbox(r.db.WithContext(ctx), webhooks)
+}
+
+// ClaimDue claims up to limit pending webhooks whose next attempt is due by
+// pushing that attempt lease into the future. A webhook is claimed by one
+// caller only, even across instances; if its delivery is never recorded, it
+// becomes due again once the lease runs out.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.Webhook, error) {
+	var due []models.Webhook
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookStatusPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]models.Webhook, 0, len(due))
+	leasedUntil := now.Add(lease)
+	for _, webhook := range due {
+		result := r.db.WithContext(ctx).Model(&models.Webhook{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", webhook.ID, models.WebhookStatusPending, webhook.NextAttemptAt).
+			Update("next_attempt_at", leasedUntil)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			webhook.NextAttemptAt = leasedUntil
+			claimed = append(claimed, webhook)
+		}
+	}
+	return claimed, nil
+}
+
+// RecordAttempt saves the outcome of a delivery attempt and adds the attempt
+// to the webhook's delivery log.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, webhook *models.Webhook, attempt *models.WebhookAttempt) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Webhook{}).
+			Where("id = ?", webhook.ID).
+			Updates(map[string]interface{}{
+				"status":          webhook.Status,
+				"attempts":        webhook.Attempts,
+				"next_attempt_at": webhook.NextAttemptAt,
+				"last_attempt_at": webhook.LastAttemptAt,
+				"response_status": webhook.ResponseStatus,
+				"error_message":   webhook.ErrorMessage,
+			}).Error
+		if err != nil {
+			return err
+		}
+		attempt.WebhookID = webhook.ID
+		return tx.Create(attempt).Error
+	})
+}
+
+// Reschedule moves the next attempt of a pending webhook without counting an attempt.
+func (r *WebhookRepository) Reschedule(ctx context.Context, webhookID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Webhook{}).
+		Where("id = ? AND status = ?", webhookID, models.WebhookStatusPending).
+		Update("next_attempt_at", at).Error
+}
+
+// List returns a page of the app's webhooks matching the filter, newest
+// first, and how many match in total.
+func (r *WebhookRepository) List(ctx context.Context, appID string, filter WebhookFilter, offset, limit int) ([]models.Webhook, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Webhook{}).Where("app_id = ?", appID)
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Reference != "" {
+		query = query.Where("reference = ?", filter.Reference)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var webhooks []models.Webhook
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&webhooks).Error
+	return webhooks, total, err
+}
+
+// FindAppWebhook finds a webhook by ID among the webhooks of an app.
+func (r *WebhookRepository) FindAppWebhook(ctx context.Context, appID, id string) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := r.db.WithContext(ctx).First(&webhook, "id = ? AND app_id = ?", id, appID).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListAttempts returns the delivery attempts of a webhook, oldest first.
+func (r *WebhookRepository) ListAttempts(ctx context.Context, webhookID string) ([]models.WebhookAttempt, error) {
+	var attempts []models.WebhookAttempt
+	err := r.db.WithContext(ctx).
+		Where("webhook_id = ?", webhookID).
+		Order("created_at ASC").
+		Find(&attempts).Error
+	return attempts, err
+}
+
+// ErrWebhookPending is returned by Replay for a webhook that is still pending:
+// a worker may have claimed it, so it cannot be reset.
+var ErrWebhookPending = errors.New("webhook is still pending")
+
+// replayUpdates puts a webhook back in the outbox with a fresh set of attempts.
+func replayUpdates() map[string]interface{} {
+	return map[string]interface{}{
+		"status":          models.WebhookStatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"error_message":   nil,
+	}
+}
+
+// Replay delivers one of the app's delivered or failed webhooks again, sent to
+// targetURL if it is not empty. Pending webhooks are left to the dispatcher
+// and return ErrWebhookPending.
+func (r *WebhookRepository) Replay(ctx context.Context, appID, id, targetURL string) error {
+	updates := replayUpdates()
+	if targetURL != "" {
+		updates["target_url"] = targetURL
+	}
+	result := r.db.WithContext(ctx).Model(&models.Webhook{}).
+		Where("id = ? AND app_id = ? AND status IN ?", id, appID, []models.WebhookStatus{models.WebhookStatusSuccess, models.WebhookStatusFailed}).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Webhook{}).Where("id = ? AND app_id = ?", id, appID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrWebhookPending
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// ReplayFailed delivers again every failed webhook of the app created in
+// [from, to) and returns how many there were. Webhooks sent to one of the
+// app's endpoints go to the endpoint's current URL and those sent to the
+// app's webhook URL go to appURL, unless it is empty. Webhooks of endpoints
+// since deleted are left alone.
+func (r *WebhookRepository) ReplayFailed(ctx context.Context, appID string, from, to time.Time, appURL string) (int64, error) {
+	legacyURL := gorm.Expr("target_url")
+	if appURL != "" {
+		legacyURL = gorm.Expr("?", appURL)
+	}
+	targetURL := gorm.Expr(
+		"CASE WHEN endpoint_id IS NULL THEN ? ELSE (SELECT url FROM webhook_endpoints WHERE webhook_endpoints.id = webhooks.endpoint_id) END",
+		legacyURL,
+	)
+	updates := replayUpdates()
+	updates["target_url"] = targetURL
+	result := r.db.WithContext(ctx).Model(&models.Webhook{}).
+		Where("app_id = ? AND status = ? AND created_at >= ? AND created_at < ?", appID, models.WebhookStatusFailed, from, to).
+		Where("(endpoint_id IS NULL OR endpoint_id IN (SELECT id FROM webhook_endpoints WHERE deleted_at IS NULL))").
+		Updates(updates)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newTestWebhookRepository(t *testing.T) (*WebhookRepository, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWebhookRepository(db), mock
+}
+
+func TestWebhookRepositoryReplayOnlyFinishedWebhooks(t *testing.T) {
+	repo, mock := newTestWebhookRepository(t)
+	update := regexp.QuoteMeta("UPDATE `webhooks` SET") + ".*" + regexp.QuoteMeta("WHERE id = ? AND app_id = ? AND status IN (?,?)")
+	count := regexp.QuoteMeta("SELECT count(*) FROM `webhooks` WHERE id = ? AND app_id = ?")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.Replay(context.Background(), "app-1", "webhook-1", "https://example.com/hooks"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery(count).WithArgs("webhook-2", "app-1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	assert.ErrorIs(t, repo.Replay(context.Background(), "app-1", "webhook-2", ""), ErrWebhookPending)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectQuery(count).WithArgs("webhook-3", "app-1").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	assert.ErrorIs(t, repo.Replay(context.Background(), "app-1", "webhook-3", ""), gorm.ErrRecordNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"confam-api/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IWebhookSecretRepository interface {
+	ListActive(ctx context.Context, appID string, endpointID *string, now time.Time) ([]models.WebhookSecret, error)
+	Rotate(ctx context.Context, secret *models.WebhookSecret, previousExpiresAt time.Time) error
+}
+
+type WebhookSecretRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookSecretRepository(db *gorm.DB) *WebhookSecretRepository {
+	return &WebhookSecretRepository{db: db}
+}
+
+// secretsOf narrows query down to the secrets of one of the app's webhook
+// endpoints, or of its webhook URL when endpointID is nil.
+func secretsOf(query *gorm.DB, appID string, endpointID *string) *gorm.DB {
+	if endpointID == nil {
+		return query.Where("app_id = ? AND endpoint_id IS NULL", appID)
+	}
+	return query.Where("app_id = ? AND endpoint_id = ?", appID, *endpointID)
+}
+
+// ListActive returns the secrets of the app's webhook endpoint, or of its
+// webhook URL when endpointID is nil, that have not expired by now, newest first.
+func (r *WebhookSecretRepository) ListActive(ctx context.Context, appID string, endpointID *string, now time.Time) ([]models.WebhookSecret, error) {
+	var secrets []models.WebhookSecret
+	err := secretsOf(r.db.WithContext(ctx), appID, endpointID).
+		Where("(expires_at IS NULL OR expires_at > ?)", now).
+		Order("created_at DESC").
+		Find(&secrets).Error
+	return secrets, err
+}
+
+// Rotate stores the new secret of secret.AppID and secret.EndpointID and
+// schedules the current one to expire at previousExpiresAt, in one
+// transaction. A secret still being rotated out from an earlier rotation
+// expires straight away, so no more than two secrets sign at any time.
+func (r *WebhookSecretRepository) Rotate(ctx context.Context, secret *models.WebhookSecret, previousExpiresAt time.Time) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := secretsOf(tx.Model(&models.WebhookSecret{}), secret.AppID, secret.EndpointID).
+			Where("expires_at > ?", now).
+			Update("expires_at", now).Error
+		if err != nil {
+			return err
+		}
+		err = secretsOf(tx.Model(&models.WebhookSecret{}), secret.AppID, secret.EndpointID).
+			Where("expires_at IS NULL").
+			Update("expires_at", previousExpiresAt).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(secret).Error
+	})
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	controllers "confam-api/internal/controllers"
+	database "confam-api/internal/database"
+	"confam-api/internal/middlewares"
+	models "confam-api/internal/models"
+	client "confam-api/internal/redis"
+	repositories "confam-api/internal/repositories"
+	services "confam-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAppRoutes(router *gin.Engine, rdb *client.Client) {
+	db := database.DB
+
+	appRepo := repositories.NewAppRepository(db, rdb)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db, rdb)
+	sessionRepo := repositories.NewSessionRepository(rdb)
+
+	webhookSecretRepo := repositories.NewWebhookSecretRepository(db)
+
+	appService := services.NewAppService(appRepo, apiKeyRepo, webhookSecretRepo)
+
+	appController := controllers.NewAppController(appService)
+
+	api := router.Group("/api/v1")
+	{
+		apps := api.Group("/apps", middlewares.AuthenticateCompany(db, sessionRepo))
+		{
+			apps.GET("", appController.ListApps)
+			apps.GET("/:id", appController.GetApp)
+
+			manage := apps.Group("", middlewares.RequirePermission(models.PermissionManageApps))
+			{
+				manage.POST("", appController.CreateApp)
+				manage.PATCH("/:id", appController.UpdateApp)
+				manage.DELETE("/:id", appController.DeactivateApp)
+				manage.GET("/:id/keys", appController.ListKeys)
+				manage.DELETE("/:id/keys/:key_id", appController.RevokeKey)
+				manage.POST("/:id/keys/rotate", appController.RotateKeys)
+				manage.GET("/:id/keys/rotations", appController.ListKeyRotations)
+			}
+
+			webhooks := apps.Group("", middlewares.RequirePermission(models.PermissionManageWebhooks))
+			{
+				webhooks.GET("/:id/webhook-secrets", appController.ListWebhookSecrets)
+				webhooks.POST("/:id/webhook-secrets/rotate", appController.RotateWebhookSecret)
+			}
+		}
+	}
+}
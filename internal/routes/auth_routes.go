@@ -3,35 +3,59 @@ package routes
 import (
 	controllers "confam-api/internal/controllers"
 	database "confam-api/internal/database"
+	"confam-api/internal/middlewares"
+	client "confam-api/internal/redis"
 	repositories "confam-api/internal/repositories"
 	services "confam-api/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterAuthRoutes(router *gin.Engine) {
+func RegisterAuthRoutes(router *gin.Engine, rdb *client.Client) {
 	// This should be your GORM database instance
 	db := database.DB
 
 	// 2. Create Repository instances
 	companyRepo := repositories.NewCompanyRepository(db)
+	userRepo := repositories.NewCompanyUserRepository(db)
+	resetRepo := repositories.NewPasswordResetRepository(rdb)
+	mfaRepo := repositories.NewMFAChallengeRepository(rdb)
+	sessionRepo := repositories.NewSessionRepository(rdb)
+	recoveryRepo := repositories.NewRecoveryCodeRepository(db)
+	attemptRepo := repositories.NewAttemptRepository(rdb)
 
 	// 3. Create Service instances, injecting repositories
-	authService := services.NewAuthService(companyRepo)
+	mailService := services.NewLogMailService()
+	twoFactorService := services.NewTwoFactorService(userRepo, recoveryRepo, attemptRepo)
+	authService := services.NewAuthService(companyRepo, userRepo, resetRepo, sessionRepo, mfaRepo, mailService, twoFactorService)
 
 	// 4. Create Controller instances, injecting services
 	authController := controllers.NewAuthController(authService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
 
 	api := router.Group("/api/v1")
 	{
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", authController.Login)
-			auth.POST("/register", controllers.Register)
-			auth.POST("/password/forgot", controllers.ForgotPassword)
-			auth.POST("/password/reset", controllers.PasswordReset)
-			auth.PUT("/password/change", controllers.ChangePassword)
-			//auth.POST("/token/refresh", controllers.RefreshToken)
+			auth.POST("/login/2fa", authController.LoginTwoFactor)
+			auth.POST("/register", authController.Register)
+			auth.GET("/email/verify", authController.VerifyEmail)
+			auth.POST("/password/forgot", authController.ForgotPassword)
+			auth.POST("/password/reset", authController.PasswordReset)
+			auth.POST("/token/refresh", authController.RefreshToken)
+		}
+
+		// Routes for the company dashboard require a valid access token.
+		dashboard := api.Group("/auth", middlewares.AuthenticateCompany(db, sessionRepo))
+		{
+			dashboard.PUT("/password/change", authController.ChangePassword)
+			dashboard.POST("/logout", authController.Logout)
+			dashboard.GET("/sessions", authController.ListSessions)
+			dashboard.DELETE("/sessions/:id", authController.RevokeSession)
+			dashboard.POST("/2fa/setup", twoFactorController.Setup)
+			dashboard.POST("/2fa/enable", twoFactorController.Enable)
+			dashboard.POST("/2fa/disable", twoFactorController.Disable)
 		}
 	}
 }
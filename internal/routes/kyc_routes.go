@@ -7,6 +7,7 @@ import (
 	client "confam-api/internal/redis"
 	repositories "confam-api/internal/repositories"
 	services "confam-api/internal/services"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,14 +18,39 @@ func RegisterKycRoutes(router *gin.Engine, rdb *client.Client) {
 	//companyRepo := repositories.NewCompanyRepository(db)
 	customerRepo := repositories.NewCustomerRepository(db)
 	requestRepo := repositories.NewRequestRepository(db)
-	//appRepo := repositories.NewAppRepository(db, rdb)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db, rdb)
+	permissionRepo := repositories.NewCustomerPermissionRepository(db)
+	appRepo := repositories.NewAppRepository(db, rdb)
+	webhookSecretRepo := repositories.NewWebhookSecretRepository(db)
 
 	// 3. Create Service instances, injecting repositories
-	kycService := services.NewKYCService(customerRepo, requestRepo)
-	webhookService := services.NewWebhookService()
+	webhookService := services.NewWebhookService(appRepo, repositories.NewWebhookEndpointRepository(db), repositories.NewWebhookRepository(db))
+	lifecycle := services.NewRequestLifecycleService(requestRepo, webhookService)
+	requirements := services.NewKYCRequirementsEngineFromEnv()
+	kycService := services.NewKYCService(
+		customerRepo,
+		requestRepo,
+		permissionRepo,
+		lifecycle,
+		webhookService,
+		services.NewSandboxIdentityProvider(),
+		services.NewIdentityProviderRegistryFromEnv(),
+		requirements,
+	)
+
+	otpService := services.NewOTPService(
+		kycService,
+		customerRepo,
+		repositories.NewOTPRepository(rdb),
+		repositories.NewAttemptRepository(rdb),
+		lifecycle,
+		services.NewLogOTPSender(os.Getenv("OTP_LOG_FILE")),
+	)
+	completionService := services.NewCompletionService(kycService, customerRepo, permissionRepo, webhookSecretRepo, lifecycle, requirements)
 
 	// 4. Create Controller instances, injecting services
-	kycController := controllers.NewKycController(kycService, webhookService)
+	kycController := controllers.NewKycController(kycService)
+	allowController := controllers.NewAllowController(otpService, completionService)
 
 	api := router.Group("/api/v1")
 	{
@@ -32,10 +58,21 @@ func RegisterKycRoutes(router *gin.Engine, rdb *client.Client) {
 		{
 			kyc.POST(
 				"/",
-				middlewares.AuthenticateAppBySecretKey(database.DB, rdb),
+				middlewares.AuthenticateAppBySecretKey(apiKeyRepo),
 				kycController.InitiateKyc,
 			)
 			kyc.GET("/:kyc_token", kycController.FetchKycRequest)
+			kyc.POST("/:kyc_token/otp", allowController.SendOTP)
+			kyc.POST("/:kyc_token/otp/verify", allowController.VerifyOTP)
+			kyc.POST("/:kyc_token/consent", allowController.GrantConsent)
+			kyc.POST("/:kyc_token/complete", allowController.CompleteKyc)
+			kyc.POST("/:kyc_token/fail", allowController.FailKyc)
+		}
+
+		requests := api.Group("/requests", middlewares.AuthenticateAppBySecretKey(apiKeyRepo))
+		{
+			requests.GET("/:reference", kycController.GetRequest)
+			requests.POST("/:reference/token", kycController.RenewKycToken)
 		}
 	}
 }
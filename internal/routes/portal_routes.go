@@ -0,0 +1,49 @@
+package routes
+
+import (
+	controllers "confam-api/internal/controllers"
+	database "confam-api/internal/database"
+	"confam-api/internal/middlewares"
+	client "confam-api/internal/redis"
+	repositories "confam-api/internal/repositories"
+	services "confam-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterPortalRoutes(router *gin.Engine, rdb *client.Client) {
+	db := database.DB
+
+	customerRepo := repositories.NewCustomerRepository(db)
+	permissionRepo := repositories.NewCustomerPermissionRepository(db)
+
+	webhookService := services.NewWebhookService(repositories.NewAppRepository(db, rdb), repositories.NewWebhookEndpointRepository(db), repositories.NewWebhookRepository(db))
+	permissionService := services.NewPermissionService(permissionRepo, webhookService)
+	portalService := services.NewCustomerPortalService(
+		customerRepo,
+		repositories.NewRequestRepository(db),
+		permissionRepo,
+		repositories.NewPortalLoginRepository(rdb),
+		repositories.NewAttemptRepository(rdb),
+		permissionService,
+		services.NewLogMailService(),
+	)
+
+	portalController := controllers.NewPortalController(portalService)
+
+	api := router.Group("/api/v1")
+	{
+		portal := api.Group("/portal")
+		{
+			portal.POST("/login", portalController.RequestLogin)
+			portal.POST("/login/verify", portalController.VerifyLogin)
+		}
+
+		// Routes for logged in customers require a portal access token.
+		customer := api.Group("/portal", middlewares.AuthenticateCustomer(customerRepo))
+		{
+			customer.GET("/companies", portalController.ListCompanies)
+			customer.DELETE("/companies/:company_id/permission", portalController.RevokeAccess)
+		}
+	}
+}
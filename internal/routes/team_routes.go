@@ -0,0 +1,45 @@
+package routes
+
+import (
+	controllers "confam-api/internal/controllers"
+	database "confam-api/internal/database"
+	"confam-api/internal/middlewares"
+	models "confam-api/internal/models"
+	client "confam-api/internal/redis"
+	repositories "confam-api/internal/repositories"
+	services "confam-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterTeamRoutes(router *gin.Engine, rdb *client.Client) {
+	db := database.DB
+
+	userRepo := repositories.NewCompanyUserRepository(db)
+	invitationRepo := repositories.NewInvitationRepository(db)
+	sessionRepo := repositories.NewSessionRepository(rdb)
+
+	mailService := services.NewLogMailService()
+	teamService := services.NewTeamService(userRepo, invitationRepo, sessionRepo, mailService)
+
+	teamController := controllers.NewTeamController(teamService)
+
+	api := router.Group("/api/v1")
+	{
+		api.POST("/team/invitations/accept", teamController.AcceptInvitation)
+
+		team := api.Group("/team", middlewares.AuthenticateCompany(db, sessionRepo))
+		{
+			team.GET("/members", teamController.ListMembers)
+
+			manage := team.Group("", middlewares.RequirePermission(models.PermissionManageTeam))
+			{
+				manage.PUT("/members/:id/role", teamController.UpdateMemberRole)
+				manage.DELETE("/members/:id", teamController.RemoveMember)
+				manage.GET("/invitations", teamController.ListInvitations)
+				manage.POST("/invitations", teamController.Invite)
+				manage.DELETE("/invitations/:id", teamController.RevokeInvitation)
+			}
+		}
+	}
+}
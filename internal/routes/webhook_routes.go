@@ -0,0 +1,57 @@
+package routes
+
+import (
+	controllers "confam-api/internal/controllers"
+	database "confam-api/internal/database"
+	"confam-api/internal/middlewares"
+	models "confam-api/internal/models"
+	client "confam-api/internal/redis"
+	repositories "confam-api/internal/repositories"
+	services "confam-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterWebhookRoutes(router *gin.Engine, rdb *client.Client) {
+	db := database.DB
+
+	appRepo := repositories.NewAppRepository(db, rdb)
+	endpointRepo := repositories.NewWebhookEndpointRepository(db)
+	webhookRepo := repositories.NewWebhookRepository(db)
+	webhookSecretRepo := repositories.NewWebhookSecretRepository(db)
+	sessionRepo := repositories.NewSessionRepository(rdb)
+
+	deliveryService := services.NewWebhookDeliveryService(appRepo, endpointRepo, webhookRepo)
+	endpointService := services.NewWebhookEndpointService(appRepo, endpointRepo, webhookRepo, webhookSecretRepo)
+
+	webhookController := controllers.NewWebhookController(deliveryService, endpointService)
+
+	api := router.Group("/api/v1")
+	{
+		webhooks := api.Group("/apps/:id/webhooks",
+			middlewares.AuthenticateCompany(db, sessionRepo),
+			middlewares.RequirePermission(models.PermissionManageWebhooks),
+		)
+		{
+			webhooks.GET("", webhookController.ListDeliveries)
+			webhooks.POST("/replay", webhookController.ReplayFailed)
+			webhooks.GET("/:webhook_id", webhookController.GetDelivery)
+			webhooks.POST("/:webhook_id/replay", webhookController.ReplayDelivery)
+		}
+
+		endpoints := api.Group("/apps/:id/webhook-endpoints",
+			middlewares.AuthenticateCompany(db, sessionRepo),
+			middlewares.RequirePermission(models.PermissionManageWebhooks),
+		)
+		{
+			endpoints.GET("", webhookController.ListEndpoints)
+			endpoints.POST("", webhookController.CreateEndpoint)
+			endpoints.GET("/:endpoint_id", webhookController.GetEndpoint)
+			endpoints.PATCH("/:endpoint_id", webhookController.UpdateEndpoint)
+			endpoints.DELETE("/:endpoint_id", webhookController.DeleteEndpoint)
+			endpoints.POST("/:endpoint_id/test", webhookController.SendTestEvent)
+			endpoints.GET("/:endpoint_id/secrets", webhookController.ListEndpointSecrets)
+			endpoints.POST("/:endpoint_id/secrets/rotate", webhookController.RotateEndpointSecret)
+		}
+	}
+}
@@ -1,12 +1,7 @@
 package seeders
 
 import (
-	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +9,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	crypto "confam-api/internal/crypto"
 	"confam-api/internal/models"
 )
 
@@ -44,6 +40,20 @@ func Seed(db *gorm.DB, redisClient *redis.Client) error {
 		return err
 	}
 
+	owner := models.CompanyUser{
+		ID:              uuid.New().String(),
+		CompanyID:       company.ID,
+		FirstName:       "John",
+		LastName:        "Doe",
+		Email:           company.Email,
+		Password:        ptr(string(hashedPassword)),
+		Role:            models.RoleOwner,
+		EmailVerifiedAt: ptrTime(time.Now()),
+	}
+	if err := db.Create(&owner).Error; err != nil {
+		return err
+	}
+
 	// 3. Create app
 	testPub := "pk_test_" + uuid.New().String()
 	livePub := "pk_live_" + uuid.New().String()
@@ -65,34 +75,44 @@ func Seed(db *gorm.DB, redisClient *redis.Client) error {
 		return err
 	}
 
-	// 4. Store secret keys in Redis
-	secretSalt := os.Getenv("SECRET_SALT")
-	if secretSalt == "" {
-		secretSalt = "default_secret_salt"
+	// 4. Issue secret keys; only their hashes are stored
+	testSecret, err := crypto.GenerateAPIKey("sk_test")
+	if err != nil {
+		return err
+	}
+	liveSecret, err := crypto.GenerateAPIKey("sk_live")
+	if err != nil {
+		return err
+	}
+	for secret, keyType := range map[string]string{testSecret: models.APIKeyTypeTest, liveSecret: models.APIKeyTypeLive} {
+		key := models.NewAPIKey(secret, keyType)
+		key.AppID = app.ID
+		if err := db.Create(key).Error; err != nil {
+			return err
+		}
 	}
-	testSecret := "sk_test_" + hmacHex(secretSalt, testPub)
-	liveSecret := "sk_live_" + hmacHex(secretSalt, livePub)
-
-	fmt.Println("Test Secret:", testSecret)
-	fmt.Println("Live Secret:", liveSecret)
-	fmt.Println("App ID:", app.ID)
 
-	ctx := context.Background()
-	if err := redisClient.Set(ctx, "secret:"+testSecret, app.ID, 0).Err(); err != nil {
+	webhookSecret, err := crypto.GenerateAPIKey("whsec")
+	if err != nil {
+		return err
+	}
+	secret, err := models.NewWebhookSecret(webhookSecret)
+	if err != nil {
 		return err
 	}
-	if err := redisClient.Set(ctx, "secret:"+liveSecret, app.ID, 0).Err(); err != nil {
+	secret.AppID = app.ID
+	if err := db.Create(secret).Error; err != nil {
 		return err
 	}
 
+	fmt.Println("Test Secret:", testSecret)
+	fmt.Println("Live Secret:", liveSecret)
+	fmt.Println("Webhook Secret:", webhookSecret)
+	fmt.Println("App ID:", app.ID)
+
 	return nil
 }
 
 // Helper functions
 func ptr(s string) *string           { return &s }
 func ptrTime(t time.Time) *time.Time { return &t }
-func hmacHex(secret, msg string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(msg))
-	return hex.EncodeToString(h.Sum(nil))
-}
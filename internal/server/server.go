@@ -21,8 +21,12 @@ func NewRouter(rdb *client.Client) *gin.Engine {
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
-	routes.RegisterAuthRoutes(router)
+	routes.RegisterAuthRoutes(router, rdb)
+	routes.RegisterTeamRoutes(router, rdb)
+	routes.RegisterAppRoutes(router, rdb)
+	routes.RegisterWebhookRoutes(router, rdb)
 	routes.RegisterKycRoutes(router, rdb)
+	routes.RegisterPortalRoutes(router, rdb)
 	router.POST("/api/v1/upload", handleUpload)
 	//router.Use(middlewares.AuthenticateAppBySecretKey(database.DB))
 	//router.SetTrustedProxies([]string{"192.168.1.2"})
@@ -0,0 +1,408 @@
+package services
+
+import (
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultKeyRotationGracePeriod = 24 * time.Hour
+
+var (
+	ErrAppNotFound            = errors.New("App not found")
+	ErrAPIKeyNotFound         = errors.New("API key not found")
+	ErrInsufficientPermission = errors.New("You do not have permission to perform this action")
+)
+
+type IAppService interface {
+	CreateApp(ctx context.Context, user *models.CompanyUser, req structs.CreateAppRequest) (*structs.AppWithKeys, error)
+	ListApps(ctx context.Context, companyID string) ([]models.App, error)
+	GetApp(ctx context.Context, companyID, id string) (*models.App, error)
+	UpdateApp(ctx context.Context, user *models.CompanyUser, id string, req structs.UpdateAppRequest) (*models.App, error)
+	DeactivateApp(ctx context.Context, companyID, id string) (*models.App, error)
+	RotateKeys(ctx context.Context, user *models.CompanyUser, id string, req structs.RotateKeysRequest, ip string) (*structs.RotatedKeys, error)
+	ListKeyRotations(ctx context.Context, companyID, id string) ([]models.KeyRotation, error)
+	ListKeys(ctx context.Context, user *models.CompanyUser, id string) ([]models.APIKey, error)
+	RevokeKey(ctx context.Context, user *models.CompanyUser, id, keyID string) error
+	ListWebhookSecrets(ctx context.Context, companyID, id string) ([]structs.WebhookSecret, error)
+	RotateWebhookSecret(ctx context.Context, companyID, id string, req structs.RotateWebhookSecretRequest) (*structs.RotatedWebhookSecret, error)
+}
+
+// AppService manages the apps a company integrates with.
+type AppService struct {
+	appRepo           repositories.IAppRepository
+	apiKeyRepo        repositories.IAPIKeyRepository
+	webhookSecretRepo repositories.IWebhookSecretRepository
+}
+
+func NewAppService(
+	appRepo repositories.IAppRepository,
+	apiKeyRepo repositories.IAPIKeyRepository,
+	webhookSecretRepo repositories.IWebhookSecretRepository,
+) *AppService {
+	return &AppService{
+		appRepo:           appRepo,
+		apiKeyRepo:        apiKeyRepo,
+		webhookSecretRepo: webhookSecretRepo,
+	}
+}
+
+// keyRotationGracePeriod is how long a rotated-out secret keeps working when
+// the rotation request does not say. It is read from KEY_ROTATION_GRACE_PERIOD,
+// e.g. "48h".
+func keyRotationGracePeriod() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("KEY_ROTATION_GRACE_PERIOD")); err == nil && d >= 0 {
+		return d
+	}
+	return defaultKeyRotationGracePeriod
+}
+
+// CreateApp creates an app for the user's company, with its secret keys and
+// the secret its webhooks are signed with. Setting a webhook URL requires
+// permission to manage webhooks.
+func (s *AppService) CreateApp(ctx context.Context, user *models.CompanyUser, req structs.CreateAppRequest) (*structs.AppWithKeys, error) {
+	if req.WebhookURL != nil && !user.Can(models.PermissionManageWebhooks) {
+		return nil, ErrInsufficientPermission
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = string(models.AppModeSandbox)
+	}
+	testKey, err := crypto.GenerateAPIKey("sk_test")
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	liveKey, err := crypto.GenerateAPIKey("sk_live")
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	webhookSecret, secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	app := &models.App{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Logo:        req.Logo,
+		WebhookURL:  req.WebhookURL,
+		Mode:        mode,
+		Status:      string(models.AppStatusActive),
+		CompanyID:   user.CompanyID,
+	}
+	keys := []*models.APIKey{
+		models.NewAPIKey(testKey, models.APIKeyTypeTest),
+		models.NewAPIKey(liveKey, models.APIKeyTypeLive),
+	}
+	if err := s.appRepo.CreateApp(ctx, app, secret, keys...); err != nil {
+		log.Printf("Failed to create app for company %s: %v", user.CompanyID, err)
+		return nil, errors.New("Could not create app. Please try again later.")
+	}
+
+	result := &structs.AppWithKeys{App: app, TestSecretKey: testKey}
+	if user.Can(models.PermissionViewLiveKeys) {
+		result.LiveSecretKey = liveKey
+	}
+	if user.Can(models.PermissionManageWebhooks) {
+		result.WebhookSecret = webhookSecret
+	}
+	return result, nil
+}
+
+func (s *AppService) ListApps(ctx context.Context, companyID string) ([]models.App, error) {
+	apps, err := s.appRepo.FindAppsByCompany(ctx, companyID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return apps, nil
+}
+
+func (s *AppService) GetApp(ctx context.Context, companyID, id string) (*models.App, error) {
+	app, err := s.appRepo.FindCompanyApp(ctx, companyID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAppNotFound
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return app, nil
+}
+
+// UpdateApp applies the fields present in req. Changing the webhook URL
+// requires permission to manage webhooks.
+func (s *AppService) UpdateApp(ctx context.Context, user *models.CompanyUser, id string, req structs.UpdateAppRequest) (*models.App, error) {
+	if req.WebhookURL != nil && !user.Can(models.PermissionManageWebhooks) {
+		return nil, ErrInsufficientPermission
+	}
+
+	app, err := s.GetApp(ctx, user.CompanyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DisplayName != nil {
+		app.DisplayName = *req.DisplayName
+	}
+	if req.Logo != nil {
+		app.Logo = req.Logo
+	}
+	if req.WebhookURL != nil {
+		app.WebhookURL = req.WebhookURL
+	}
+	if req.Mode != nil {
+		app.Mode = *req.Mode
+	}
+	if req.Status != nil {
+		app.Status = *req.Status
+	}
+
+	if err := s.appRepo.UpdateApp(ctx, app); err != nil {
+		return nil, errors.New("Could not update app. Please try again later.")
+	}
+	return app, nil
+}
+
+// DeactivateApp marks the app INACTIVE so its keys stop authenticating requests.
+func (s *AppService) DeactivateApp(ctx context.Context, companyID, id string) (*models.App, error) {
+	app, err := s.GetApp(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.Status = string(models.AppStatusInactive)
+	if err := s.appRepo.UpdateApp(ctx, app); err != nil {
+		return nil, errors.New("Could not deactivate app. Please try again later.")
+	}
+	return app, nil
+}
+
+// RotateKeys issues a new public and secret key for one environment of the app.
+// The previous secret keeps authenticating for the grace period, then stops.
+// Rotating live keys requires permission to view them.
+func (s *AppService) RotateKeys(ctx context.Context, user *models.CompanyUser, id string, req structs.RotateKeysRequest, ip string) (*structs.RotatedKeys, error) {
+	if req.Environment == models.APIKeyTypeLive && !user.Can(models.PermissionViewLiveKeys) {
+		return nil, ErrInsufficientPermission
+	}
+
+	app, err := s.GetApp(ctx, user.CompanyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	grace := keyRotationGracePeriod()
+	if req.GracePeriodMinutes != nil {
+		grace = time.Duration(*req.GracePeriodMinutes) * time.Minute
+	}
+
+	suffix, err := crypto.GenerateHexToken()
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	secret, err := crypto.GenerateAPIKey("sk_" + req.Environment)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+
+	publicKey := "pk_" + req.Environment + "_" + suffix
+	rotation := &models.KeyRotation{
+		AppID:                app.ID,
+		Environment:          req.Environment,
+		RotatedByID:          user.ID,
+		PreviousKeyExpiresAt: time.Now().Add(grace),
+		IP:                   ip,
+	}
+	if req.Environment == models.APIKeyTypeLive {
+		rotation.OldPublicKey = app.LivePublicKey
+		app.LivePublicKey = publicKey
+	} else {
+		rotation.OldPublicKey = app.TestPublicKey
+		app.TestPublicKey = publicKey
+	}
+	rotation.NewPublicKey = publicKey
+
+	newKey := models.NewAPIKey(secret, req.Environment)
+	newKey.AppID = app.ID
+	if err := s.apiKeyRepo.Rotate(ctx, app, newKey, rotation); err != nil {
+		log.Printf("Failed to rotate %s keys for app %s: %v", req.Environment, app.ID, err)
+		return nil, errors.New("Could not rotate keys. Please try again later.")
+	}
+
+	return &structs.RotatedKeys{
+		Environment:          req.Environment,
+		PublicKey:            publicKey,
+		SecretKey:            secret,
+		PreviousKeyExpiresAt: rotation.PreviousKeyExpiresAt,
+	}, nil
+}
+
+// ListKeyRotations returns the app's key rotations, most recent first.
+func (s *AppService) ListKeyRotations(ctx context.Context, companyID, id string) ([]models.KeyRotation, error) {
+	app, err := s.GetApp(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rotations, err := s.apiKeyRepo.ListRotations(ctx, app.ID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return rotations, nil
+}
+
+// ListKeys returns the app's keys that have not been revoked. Live keys are
+// left out for roles that may not view them.
+func (s *AppService) ListKeys(ctx context.Context, user *models.CompanyUser, id string) ([]models.APIKey, error) {
+	app, err := s.GetApp(ctx, user.CompanyID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.visibleKeys(ctx, user, app.ID)
+}
+
+func (s *AppService) visibleKeys(ctx context.Context, user *models.CompanyUser, appID string) ([]models.APIKey, error) {
+	keys, err := s.apiKeyRepo.ListKeys(ctx, appID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	if user.Can(models.PermissionViewLiveKeys) {
+		return keys, nil
+	}
+
+	visible := make([]models.APIKey, 0, len(keys))
+	for _, key := range keys {
+		if key.Type != models.APIKeyTypeLive {
+			visible = append(visible, key)
+		}
+	}
+	return visible, nil
+}
+
+// RevokeKey stops one of the app's keys from authenticating immediately.
+// Revoking live keys requires permission to view them.
+func (s *AppService) RevokeKey(ctx context.Context, user *models.CompanyUser, id, keyID string) error {
+	app, err := s.GetApp(ctx, user.CompanyID, id)
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.visibleKeys(ctx, user, app.ID)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, key := range keys {
+		if key.ID == keyID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrAPIKeyNotFound
+	}
+
+	if _, err := s.apiKeyRepo.Revoke(ctx, app.ID, keyID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		log.Printf("Failed to revoke key %s of app %s: %v", keyID, app.ID, err)
+		return errors.New("Could not revoke key. Please try again later.")
+	}
+	return nil
+}
+
+// ListWebhookSecrets returns the secrets the webhooks sent to the app's
+// webhook URL are currently signed with, newest first. There are two while a
+// rotation is in progress.
+func (s *AppService) ListWebhookSecrets(ctx context.Context, companyID, id string) ([]structs.WebhookSecret, error) {
+	app, err := s.GetApp(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	return listWebhookSecrets(ctx, s.webhookSecretRepo, app.ID, nil)
+}
+
+// RotateWebhookSecret issues a new secret for the webhooks sent to the app's
+// webhook URL.
+func (s *AppService) RotateWebhookSecret(ctx context.Context, companyID, id string, req structs.RotateWebhookSecretRequest) (*structs.RotatedWebhookSecret, error) {
+	app, err := s.GetApp(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	return rotateWebhookSecret(ctx, s.webhookSecretRepo, app.ID, nil, req)
+}
+
+// generateWebhookSecret returns a new webhook secret with the record storing it.
+func generateWebhookSecret() (string, *models.WebhookSecret, error) {
+	plain, err := crypto.GenerateAPIKey("whsec")
+	if err != nil {
+		return "", nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	secret, err := models.NewWebhookSecret(plain)
+	if err != nil {
+		log.Printf("Failed to encrypt webhook secret: %v", err)
+		return "", nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return plain, secret, nil
+}
+
+// listWebhookSecrets returns the unexpired secrets of the app's webhook
+// endpoint, or of its webhook URL when endpointID is nil, in clear text.
+func listWebhookSecrets(ctx context.Context, repo repositories.IWebhookSecretRepository, appID string, endpointID *string) ([]structs.WebhookSecret, error) {
+	secrets, err := repo.ListActive(ctx, appID, endpointID, time.Now())
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	result := make([]structs.WebhookSecret, 0, len(secrets))
+	for _, secret := range secrets {
+		plain, err := secret.Secret()
+		if err != nil {
+			log.Printf("Failed to decrypt webhook secret %s: %v", secret.ID, err)
+			return nil, errors.New("An internal server error occurred. Please try again later")
+		}
+		result = append(result, structs.WebhookSecret{
+			ID:        secret.ID,
+			Secret:    plain,
+			ExpiresAt: secret.ExpiresAt,
+			CreatedAt: secret.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// rotateWebhookSecret issues a new secret for the app's webhook endpoint, or
+// its webhook URL when endpointID is nil. Webhooks are signed with both the
+// new and the previous secret for the grace period, so the integrator can
+// switch over without rejecting deliveries.
+func rotateWebhookSecret(ctx context.Context, repo repositories.IWebhookSecretRepository, appID string, endpointID *string, req structs.RotateWebhookSecretRequest) (*structs.RotatedWebhookSecret, error) {
+	grace := keyRotationGracePeriod()
+	if req.GracePeriodMinutes != nil {
+		grace = time.Duration(*req.GracePeriodMinutes) * time.Minute
+	}
+
+	plain, secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	secret.AppID = appID
+	secret.EndpointID = endpointID
+
+	previousExpiresAt := time.Now().Add(grace)
+	if err := repo.Rotate(ctx, secret, previousExpiresAt); err != nil {
+		log.Printf("Failed to rotate webhook secret for app %s: %v", appID, err)
+		return nil, errors.New("Could not rotate webhook secret. Please try again later.")
+	}
+
+	return &structs.RotatedWebhookSecret{
+		Secret:                  plain,
+		PreviousSecretExpiresAt: previousExpiresAt,
+	}, nil
+}
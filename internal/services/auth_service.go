@@ -2,91 +2,506 @@ package services
 
 import (
 	auth "confam-api/internal/auth"
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
 	repositories "confam-api/internal/repositories"
 	structs "confam-api/internal/structs"
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
 type IAuthService interface {
-	Register(ctx context.Context, req structs.RegisterRequest) error
-	Login(ctx context.Context, req structs.LoginRequest) (string, error)
+	Register(ctx context.Context, req structs.RegisterRequest) (*models.Company, error)
+	VerifyEmail(ctx context.Context, token string) error
+	Login(ctx context.Context, req structs.LoginRequest, client structs.ClientInfo) (*structs.AuthTokens, error)
+	LoginTwoFactor(ctx context.Context, req structs.LoginTwoFactorRequest, client structs.ClientInfo) (*structs.AuthTokens, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*structs.AuthTokens, error)
+	Logout(ctx context.Context, sessionID string) error
+	ListSessions(ctx context.Context, userID string) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
 	ForgotPassword(ctx context.Context, req structs.ForgotPasswordRequest) error
 	PasswordReset(ctx context.Context, req structs.PasswordResetRequest) error
-	ChangePassword(ctx context.Context, req structs.ChangePasswordRequest) error
+	ChangePassword(ctx context.Context, user *models.CompanyUser, req structs.ChangePasswordRequest) error
 }
 
-// UserService implements the IUserService interface.
+const (
+	accessTokenTTL       = 15 * time.Minute
+	refreshTokenTTL      = 30 * 24 * time.Hour
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+	mfaChallengeTTL      = 5 * time.Minute
+)
+
+var (
+	ErrEmailAlreadyTaken        = errors.New("An account with this email already exists")
+	ErrPasswordMismatch         = errors.New("The password confirmation does not match")
+	ErrEmailNotVerified         = errors.New("Please verify your email address before logging in")
+	ErrInvalidVerificationToken = errors.New("This verification link is invalid or has expired")
+	ErrInvalidResetToken        = errors.New("This password reset link is invalid or has expired")
+	ErrIncorrectPassword        = errors.New("Your current password is incorrect")
+	ErrInvalidRefreshToken      = errors.New("Your session has expired. Please log in again")
+	ErrSessionNotFound          = errors.New("Session not found")
+	ErrInvalidMFAToken          = errors.New("Your login attempt has expired. Please log in again")
+)
+
+// AuthService authenticates the company users who log in to the dashboard.
 type AuthService struct {
 	companyRepo repositories.ICompanyRepository
+	userRepo    repositories.ICompanyUserRepository
+	resetRepo   repositories.IPasswordResetRepository
+	sessionRepo repositories.ISessionRepository
+	mfaRepo     repositories.IMFAChallengeRepository
+	mailService IMailService
+
+	twoFactorService ITwoFactorService
+}
+
+// NewAuthService creates a new instance of AuthService.
+func NewAuthService(
+	companyRepo repositories.ICompanyRepository,
+	userRepo repositories.ICompanyUserRepository,
+	resetRepo repositories.IPasswordResetRepository,
+	sessionRepo repositories.ISessionRepository,
+	mfaRepo repositories.IMFAChallengeRepository,
+	mailService IMailService,
+	twoFactorService ITwoFactorService,
+) *AuthService {
+	return &AuthService{
+		companyRepo:      companyRepo,
+		userRepo:         userRepo,
+		resetRepo:        resetRepo,
+		sessionRepo:      sessionRepo,
+		mfaRepo:          mfaRepo,
+		mailService:      mailService,
+		twoFactorService: twoFactorService,
+	}
+}
+
+// appURL is the public base URL of this API, used to build links sent by email.
+func appURL() string {
+	if u := os.Getenv("APP_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://127.0.0.1:8080"
 }
 
-// NewUserService creates a new instance of UserService.
-func NewAuthService(companyRepo repositories.ICompanyRepository) *AuthService {
-	return &AuthService{companyRepo: companyRepo}
+// dashboardURL is the base URL of the company dashboard frontend.
+func dashboardURL() string {
+	if u := os.Getenv("DASHBOARD_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://127.0.0.1:5173"
 }
 
-// Register contains the business logic for user registration.
-func (s *AuthService) Register(ctx context.Context, req structs.RegisterRequest) error {
-	// Business logic: check for existing company, hash password, create company record
-	// You would call s.companyRepo.Create here
-	// The controller is only responsible for receiving and sending data,
-	// so the business logic should be here.
+// Register contains the business logic for company registration. The company
+// is created together with its owner account, and a verification link is
+// emailed to the owner.
+func (s *AuthService) Register(ctx context.Context, req structs.RegisterRequest) (*models.Company, error) {
+	if req.Password != req.ConfirmPassword {
+		return nil, ErrPasswordMismatch
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if taken, err := s.isEmailTaken(ctx, email); err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	} else if taken {
+		return nil, ErrEmailAlreadyTaken
+	}
+
+	company := &models.Company{
+		Name:  req.CompanyName,
+		Email: email,
+	}
+	owner := &models.CompanyUser{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Email:     email,
+	}
+	if err := owner.SetPassword(req.Password); err != nil {
+		return nil, errors.New("Could not complete registration. Please try again later.")
+	}
+	if err := s.companyRepo.CreateWithOwner(ctx, company, owner); err != nil {
+		return nil, errors.New("Could not complete registration. Please try again later.")
+	}
+
+	if err := s.sendVerificationEmail(ctx, owner, company); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", owner.ID, err)
+	}
+
+	return company, nil
+}
+
+// isEmailTaken reports whether the email already belongs to a company or a team member.
+func (s *AuthService) isEmailTaken(ctx context.Context, email string) (bool, error) {
+	if _, err := s.userRepo.FindByEmail(ctx, email); err == nil {
+		return true, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if _, err := s.companyRepo.FindByEmail(ctx, email); err == nil {
+		return true, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	return false, nil
+}
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.CompanyUser, company *models.Company) error {
+	token, err := auth.SignToken(user.ID, auth.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/api/v1/auth/email/verify?token=%s", appURL(), url.QueryEscape(token))
+	body := fmt.Sprintf(
+		"Welcome to Confam, %s!\n\nPlease verify your email address by visiting the link below. It expires in %s.\n\n%s",
+		company.Name,
+		emailVerificationTTL,
+		link,
+	)
+	return s.mailService.Send(ctx, user.Email, "Verify your email address", body)
+}
+
+// VerifyEmail marks the user that owns the verification token as verified.
+// Verifying the owner's email also verifies the company.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := auth.ParseToken(token, auth.PurposeEmailVerification)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+
+	now := time.Now()
+	if user.EmailVerifiedAt == nil {
+		if err := s.userRepo.UpdateFields(ctx, user.ID, map[string]interface{}{
+			"email_verified_at": now,
+		}); err != nil {
+			return errors.New("Could not verify email. Please try again later.")
+		}
+	}
+
+	if user.Role == models.RoleOwner && user.Company != nil && (!user.Company.Verified || user.Company.EmailVerifiedAt == nil) {
+		if err := s.companyRepo.UpdateFields(ctx, user.CompanyID, map[string]interface{}{
+			"verified":          true,
+			"email_verified_at": now,
+		}); err != nil {
+			return errors.New("Could not verify email. Please try again later.")
+		}
+	}
 	return nil
 }
 
-// Login contains the business logic for user login and JWT generation.
-func (s *AuthService) Login(ctx context.Context, req structs.LoginRequest) (string, error) {
-	company, err := s.companyRepo.FindByEmail(ctx, req.Email)
+// Login contains the business logic for user login. A successful login starts
+// a new session and returns a short-lived access token with its refresh token.
+func (s *AuthService) Login(ctx context.Context, req structs.LoginRequest, client structs.ClientInfo) (*structs.AuthTokens, error) {
+	user, err := s.userRepo.FindByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", errors.New("Oops!, your email or password is invalid")
+			return nil, errors.New("Oops!, your email or password is invalid")
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+
+	if !checkPassword(user, req.Password) {
+		return nil, errors.New("Oops!, your email or password is invalid")
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	// With two-factor enabled the password alone only earns a short-lived,
+	// single-use challenge token, which LoginTwoFactor exchanges for a session.
+	if user.TwoFactorEnabled {
+		mfaToken, err := auth.SignToken(user.ID, auth.PurposeMFAChallenge, mfaChallengeTTL)
+		if err != nil {
+			return nil, errors.New("Could not complete login. Please try again later.")
+		}
+		if err := s.mfaRepo.Save(ctx, user.ID, crypto.HashSHA256(mfaToken), mfaChallengeTTL); err != nil {
+			return nil, errors.New("Could not complete login. Please try again later.")
+		}
+		return &structs.AuthTokens{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	return s.startSession(ctx, user, client)
+}
+
+// LoginTwoFactor completes a login started by Login for a user with two-factor
+// enabled. The challenge token is consumed while the code is checked, so it
+// cannot be replayed; it is handed back only when the code was wrong.
+func (s *AuthService) LoginTwoFactor(ctx context.Context, req structs.LoginTwoFactorRequest, client structs.ClientInfo) (*structs.AuthTokens, error) {
+	claims, err := auth.ParseToken(req.MFAToken, auth.PurposeMFAChallenge)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	tokenHash := crypto.HashSHA256(req.MFAToken)
+	userID, err := s.mfaRepo.Consume(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMFAChallengeNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	if userID != claims.Subject {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	if claims.IssuedAt == nil || user.IsTokenRevoked(claims.IssuedAt.Time) {
+		return nil, ErrInvalidMFAToken
+	}
+
+	if err := s.twoFactorService.Verify(ctx, user, req.Code); err != nil {
+		if errors.Is(err, ErrTwoFactorNotEnabled) {
+			return nil, ErrInvalidMFAToken
 		}
-		return "", errors.New("An internal server error occurred. Please try again later")
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if ttl := time.Until(claims.ExpiresAt.Time); ttl > 0 {
+				if err := s.mfaRepo.Save(ctx, user.ID, tokenHash, ttl); err != nil {
+					log.Printf("Failed to restore mfa challenge for user %s: %v", user.ID, err)
+				}
+			}
+		}
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, client)
+}
+
+// startSession creates a new session family and issues its first token pair.
+func (s *AuthService) startSession(ctx context.Context, user *models.CompanyUser, client structs.ClientInfo) (*structs.AuthTokens, error) {
+	sessionID, err := crypto.GenerateHexToken()
+	if err != nil {
+		return nil, errors.New("Could not complete login. Please try again later.")
+	}
+	secret, err := crypto.GenerateHexToken()
+	if err != nil {
+		return nil, errors.New("Could not complete login. Please try again later.")
 	}
 
-	if err := auth.ComparePasswordAndHash(req.Password, *company.Password); err != nil {
-		return "", errors.New("Oops!, your email or password is invalid")
+	now := time.Now()
+	session := &models.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		CompanyID:  user.CompanyID,
+		TokenHash:  crypto.HashSHA256(secret),
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+	if err := s.sessionRepo.Create(ctx, session, refreshTokenTTL); err != nil {
+		log.Printf("Failed to create session for user %s: %v", user.ID, err)
+		return nil, errors.New("Could not complete login. Please try again later.")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": company.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-	})
+	return s.issueTokens(user.ID, sessionID, secret)
+}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+func (s *AuthService) issueTokens(userID, sessionID, secret string) (*structs.AuthTokens, error) {
+	accessToken, err := auth.SignAccessToken(userID, sessionID, accessTokenTTL)
 	if err != nil {
-		return "", errors.New("Could not complete login. Please try again later.")
+		return nil, errors.New("Could not complete login. Please try again later.")
 	}
+	return &structs.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: sessionID + "." + secret,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
 
-	return tokenString, nil
+// RefreshToken exchanges a refresh token for a new token pair. Refresh tokens are
+// single use: presenting one that was already exchanged revokes the whole session.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*structs.AuthTokens, error) {
+	sessionID, secret, found := strings.Cut(refreshToken, ".")
+	if !found || sessionID == "" || secret == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newSecret, err := crypto.GenerateHexToken()
+	if err != nil {
+		return nil, errors.New("Could not refresh token. Please try again later.")
+	}
+
+	session, err := s.sessionRepo.Rotate(ctx, sessionID, crypto.HashSHA256(secret), crypto.HashSHA256(newSecret), refreshTokenTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrRefreshTokenReused):
+			log.Printf("Refresh token reuse detected, revoking session %s", sessionID)
+			if err := s.sessionRepo.Revoke(ctx, sessionID); err != nil && !errors.Is(err, repositories.ErrSessionNotFound) {
+				log.Printf("Failed to revoke session %s: %v", sessionID, err)
+			}
+			return nil, ErrInvalidRefreshToken
+		case errors.Is(err, repositories.ErrSessionNotFound):
+			return nil, ErrInvalidRefreshToken
+		default:
+			log.Printf("Failed to rotate refresh token for session %s: %v", sessionID, err)
+			return nil, errors.New("Could not refresh token. Please try again later.")
+		}
+	}
+
+	return s.issueTokens(session.UserID, session.ID, newSecret)
+}
+
+// Logout revokes the session the current access token belongs to.
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	if err := s.sessionRepo.Revoke(ctx, sessionID); err != nil && !errors.Is(err, repositories.ErrSessionNotFound) {
+		return errors.New("Could not log out. Please try again later.")
+	}
+	return nil
+}
+
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.New("Could not fetch sessions. Please try again later.")
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessionRepo.Find(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrSessionNotFound) {
+			return ErrSessionNotFound
+		}
+		return errors.New("Could not revoke session. Please try again later.")
+	}
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if err := s.sessionRepo.Revoke(ctx, sessionID); err != nil && !errors.Is(err, repositories.ErrSessionNotFound) {
+		return errors.New("Could not revoke session. Please try again later.")
+	}
+	return nil
 }
 
+// ForgotPassword emails a single-use reset link to the user if the email is registered.
+// It never reports whether the email exists; failures are only logged.
 func (s *AuthService) ForgotPassword(ctx context.Context, req structs.ForgotPasswordRequest) error {
-	// Business logic: check for existing company, hash password, create company record
-	// You would call s.companyRepo.Create here
-	// The controller is only responsible for receiving and sending data,
-	// so the business logic should be here.
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to look up user for password reset: %v", err)
+		}
+		return nil
+	}
+
+	token, err := crypto.GenerateHexToken()
+	if err != nil {
+		log.Printf("Failed to generate password reset token: %v", err)
+		return nil
+	}
+	if err := s.resetRepo.Save(ctx, user.ID, crypto.HashSHA256(token), passwordResetTTL); err != nil {
+		log.Printf("Failed to store password reset token for user %s: %v", user.ID, err)
+		return nil
+	}
+
+	link := fmt.Sprintf(
+		"%s/password/reset?token=%s&email=%s",
+		dashboardURL(),
+		url.QueryEscape(token),
+		url.QueryEscape(user.Email),
+	)
+	body := fmt.Sprintf(
+		"We received a request to reset your Confam password.\n\nUse the link below within %s to choose a new one. If you did not request this, you can ignore this email.\n\n%s",
+		passwordResetTTL,
+		link,
+	)
+	if err := s.mailService.Send(ctx, user.Email, "Reset your password", body); err != nil {
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
 	return nil
 }
 
+// PasswordReset consumes a reset token and sets the user's new password.
+// Every token issued before the reset stops being accepted.
 func (s *AuthService) PasswordReset(ctx context.Context, req structs.PasswordResetRequest) error {
-	// Business logic: check for existing company, hash password, create company record
-	// You would call s.companyRepo.Create here
-	// The controller is only responsible for receiving and sending data,
-	// so the business logic should be here.
+	if req.Password != req.ConfirmPassword {
+		return ErrPasswordMismatch
+	}
+
+	userID, err := s.resetRepo.Consume(ctx, crypto.HashSHA256(req.Token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrResetTokenNotFound) {
+			return ErrInvalidResetToken
+		}
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+	if user.Email != strings.ToLower(strings.TrimSpace(req.Email)) {
+		return ErrInvalidResetToken
+	}
+
+	if err := user.SetPassword(req.Password); err != nil {
+		return errors.New("Could not reset password. Please try again later.")
+	}
+	now := time.Now()
+	user.PasswordChangedAt = &now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return errors.New("Could not reset password. Please try again later.")
+	}
+	s.revokeAllSessions(ctx, user.ID)
 	return nil
 }
 
-func (s *AuthService) ChangePassword(ctx context.Context, req structs.ChangePasswordRequest) error {
-	// Business logic: check for existing company, hash password, create company record
-	// You would call s.companyRepo.Create here
-	// The controller is only responsible for receiving and sending data,
-	// so the business logic should be here.
+// ChangePassword updates the password of an authenticated user after checking its current one.
+// Tokens issued before the change, including the one used for this request, are revoked.
+func (s *AuthService) ChangePassword(ctx context.Context, user *models.CompanyUser, req structs.ChangePasswordRequest) error {
+	if req.NewPassword != req.ConfirmPassword {
+		return ErrPasswordMismatch
+	}
+	if !checkPassword(user, req.OldPassword) {
+		return ErrIncorrectPassword
+	}
+
+	if err := user.SetPassword(req.NewPassword); err != nil {
+		return errors.New("Could not change password. Please try again later.")
+	}
+	now := time.Now()
+	user.PasswordChangedAt = &now
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return errors.New("Could not change password. Please try again later.")
+	}
+	s.revokeAllSessions(ctx, user.ID)
 	return nil
 }
+
+// revokeAllSessions logs the user out everywhere. Access tokens are already
+// rejected through PasswordChangedAt, so a failure here is only logged.
+func (s *AuthService) revokeAllSessions(ctx context.Context, userID string) {
+	if err := s.sessionRepo.RevokeAllForUser(ctx, userID); err != nil {
+		log.Printf("Failed to revoke sessions for user %s: %v", userID, err)
+	}
+}
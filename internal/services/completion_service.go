@@ -0,0 +1,325 @@
+package services
+
+import (
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKYCNotReady           = errors.New("This KYC request is not ready to be completed")
+	ErrKYCRequirementsNotMet = errors.New("The customer has not met every requirement of the KYC level")
+	ErrConsentRequired       = errors.New("The customer must consent to sharing their KYC with this business first")
+	ErrCustomerNotConfirmed  = errors.New("The customer must confirm this request with an OTP first")
+	ErrNoSigningSecret       = errors.New("The app has no webhook secret to sign the redirect with")
+)
+
+type ICompletionService interface {
+	Complete(ctx context.Context, kycToken string) (*models.Request, string, error)
+	GrantConsent(ctx context.Context, kycToken string) (*models.Request, string, error)
+	Fail(ctx context.Context, kycToken, reason string) (*models.Request, string, error)
+}
+
+// CompletionService finishes KYC requests from the allow frontend and builds
+// the signed URL that sends the customer back to the integrator.
+type CompletionService struct {
+	kycService        IKycService
+	customerRepo      repositories.ICustomerRepository
+	permissionRepo    repositories.ICustomerPermissionRepository
+	webhookSecretRepo repositories.IWebhookSecretRepository
+	lifecycle         IRequestLifecycleService
+	requirements      *KYCRequirementsEngine
+}
+
+func NewCompletionService(
+	kycService IKycService,
+	customerRepo repositories.ICustomerRepository,
+	permissionRepo repositories.ICustomerPermissionRepository,
+	webhookSecretRepo repositories.IWebhookSecretRepository,
+	lifecycle IRequestLifecycleService,
+	requirements *KYCRequirementsEngine,
+) *CompletionService {
+	return &CompletionService{
+		kycService:        kycService,
+		customerRepo:      customerRepo,
+		permissionRepo:    permissionRepo,
+		webhookSecretRepo: webhookSecretRepo,
+		lifecycle:         lifecycle,
+		requirements:      requirements,
+	}
+}
+
+// Complete completes a request in kyc_processing once its customer meets
+// every requirement of the KYC level requested, raises the customer's KYC
+// level to the highest tier they now meet and returns the signed redirect URL.
+// Requirements the customer met in earlier requests count, but the request
+// itself must have gone through the OTP step. A customer whose KYC was
+// verified through another company must consent to sharing it first.
+func (s *CompletionService) Complete(ctx context.Context, kycToken string) (*models.Request, string, error) {
+	request, customer, err := s.kycService.FetchKycRequest(ctx, kycToken)
+	if err != nil {
+		return nil, "", err
+	}
+	consentRequired, err := s.kycService.ConsentRequired(ctx, request, customer)
+	if err != nil {
+		return nil, "", err
+	}
+	if consentRequired {
+		return nil, "", ErrConsentRequired
+	}
+	return s.complete(ctx, request, customer)
+}
+
+// GrantConsent records the customer's consent to share their KYC with the
+// request's company. Only the customer can consent: the request must have
+// passed the OTP step, since the integrator holds the KYC token too. When the
+// customer already meets the KYC level requested, the request is completed
+// straight away and the signed redirect URL is returned; otherwise the URL is
+// empty and the customer carries on with the remaining steps.
+func (s *CompletionService) GrantConsent(ctx context.Context, kycToken string) (*models.Request, string, error) {
+	request, customer, err := s.kycService.FetchKycRequest(ctx, kycToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.requireCustomerConfirmation(ctx, request); err != nil {
+		return nil, "", err
+	}
+	if err := s.grantPermission(ctx, request, customer); err != nil {
+		return nil, "", err
+	}
+
+	if !s.requirements.Evaluate(customer, RequestedKYCLevel(request)).IsComplete() {
+		return request, "", nil
+	}
+	return s.complete(ctx, request, customer)
+}
+
+// requireCustomerConfirmation returns nil if the request is in kyc_processing
+// because its customer verified an OTP sent for it. Requests sent straight to
+// kyc_processing for manual review have not been confirmed by the customer.
+func (s *CompletionService) requireCustomerConfirmation(ctx context.Context, request *models.Request) error {
+	if request.Status != models.RequestStatusKYCProcessing {
+		return ErrKYCNotReady
+	}
+	events, err := s.lifecycle.History(ctx, request.ID)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.FromStatus != nil && *event.FromStatus == models.RequestStatusOTPPending && event.ToStatus == models.RequestStatusKYCProcessing {
+			return nil
+		}
+	}
+	return ErrCustomerNotConfirmed
+}
+
+func (s *CompletionService) complete(ctx context.Context, request *models.Request, customer *models.Customer) (*models.Request, string, error) {
+	if err := s.requireCustomerConfirmation(ctx, request); err != nil {
+		return nil, "", err
+	}
+	evaluation := s.requirements.Evaluate(customer, RequestedKYCLevel(request))
+	if !evaluation.IsComplete() {
+		return nil, "", fmt.Errorf("%w: %s remaining", ErrKYCRequirementsNotMet, joinRequirements(evaluation.Remaining))
+	}
+
+	fields := map[string]interface{}{}
+	achieved := s.requirements.AchievedLevel(customer)
+	if achieved.Rank() > models.KYCLevel(customer.KYCLevelAchieved).Rank() {
+		fields["kyc_level_achieved"] = achieved
+	}
+	if customer.Status != models.StatusVerified {
+		now := time.Now()
+		fields["status"] = models.StatusVerified
+		fields["verified_at"] = now
+	}
+	permission, err := s.permissionGrant(ctx, request, customer)
+	if err != nil {
+		return nil, "", err
+	}
+	completion := repositories.RequestCompletion{
+		CustomerID:     customer.ID,
+		CustomerFields: fields,
+		Permission:     permission,
+	}
+	if _, err := s.lifecycle.Complete(ctx, request, completion); err != nil {
+		return nil, "", err
+	}
+	if _, ok := fields["kyc_level_achieved"]; ok {
+		customer.KYCLevelAchieved = string(achieved)
+	}
+
+	redirectURL, err := s.redirectURL(ctx, request)
+	if err != nil {
+		return nil, "", err
+	}
+	return request, redirectURL, nil
+}
+
+// grantPermission lets the request's company use the customer's KYC for the
+// scopes the request asks for.
+func (s *CompletionService) grantPermission(ctx context.Context, request *models.Request, customer *models.Customer) error {
+	permission, err := s.permissionGrant(ctx, request, customer)
+	if err != nil || permission == nil {
+		return err
+	}
+	if permission.ID == "" {
+		return s.permissionRepo.Create(ctx, permission)
+	}
+	return s.permissionRepo.Update(ctx, permission)
+}
+
+// permissionGrant returns the permission to save so the request's company can
+// use the customer's KYC for the scopes the request asks for, or nil if its
+// active permission already does. An active permission is extended with the
+// scopes it lacks and takes on the access type and expiry of the request; a
+// new one has no ID yet.
+func (s *CompletionService) permissionGrant(ctx context.Context, request *models.Request, customer *models.Customer) (*models.CustomerPermission, error) {
+	accessType, expiresAt := permissionAccess(request, time.Now())
+
+	permission, err := s.permissionRepo.FindActive(ctx, customer.ID, request.CompanyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.CustomerPermission{
+			CustomerID: customer.ID,
+			CompanyID:  request.CompanyID,
+			AppID:      request.AppID,
+			RequestID:  &request.ID,
+			Scopes:     request.RequestedScopes(),
+			AccessType: accessType,
+			ExpiresAt:  expiresAt,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if permission.Scopes.Covers(request.RequestedScopes()) && permission.AccessType == accessType && accessType == models.AccessPermanent {
+		return nil, nil
+	}
+	permission.Scopes = permission.Scopes.Merge(request.RequestedScopes())
+	permission.AccessType = accessType
+	permission.ExpiresAt = expiresAt
+	permission.AppID = request.AppID
+	permission.RequestID = &request.ID
+	return permission, nil
+}
+
+// permissionAccess is the access type the request asks for and, for
+// temporary access, when it expires.
+func permissionAccess(request *models.Request, now time.Time) (models.AccessType, *time.Time) {
+	if request.AccessType != models.AccessTemporary || request.AccessDays == nil {
+		return models.AccessPermanent, nil
+	}
+	expiresAt := now.AddDate(0, 0, *request.AccessDays)
+	return models.AccessTemporary, &expiresAt
+}
+
+// Fail fails a request the customer gave up on or could not finish and
+// returns the signed redirect URL.
+func (s *CompletionService) Fail(ctx context.Context, kycToken, reason string) (*models.Request, string, error) {
+	request, _, err := s.kycService.FetchKycRequest(ctx, kycToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if reason == "" {
+		reason = "customer_cancelled"
+	}
+	if _, err := s.lifecycle.Transition(ctx, request, models.RequestStatusFailed, reason); err != nil {
+		return nil, "", err
+	}
+
+	redirectURL, err := s.redirectURL(ctx, request)
+	if err != nil {
+		return nil, "", err
+	}
+	return request, redirectURL, nil
+}
+
+// redirectURL adds the timestamp, environment, reference, status and
+// signature to the request's redirect URL. The signature is the hex
+// HMAC-SHA256 of the URL-encoded
+//
+//	environment=<environment>&reference=<reference>&status=<status>&timestamp=<unix seconds>
+//
+// keyed with the secret the app's webhook URL is signed with. While that
+// secret is being rotated the URL carries a signature for the old and the
+// new secret, so integrators verifying with either keep working. The
+// timestamp lets them refuse old redirects.
+func (s *CompletionService) redirectURL(ctx context.Context, request *models.Request) (string, error) {
+	redirect, err := url.Parse(request.RedirectURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect url: %w", err)
+	}
+	secrets, err := s.signingSecrets(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := time.Now().Unix()
+	query := redirect.Query()
+	query.Set("timestamp", strconv.FormatInt(timestamp, 10))
+	query.Set("environment", string(request.Environment))
+	query.Set("reference", request.Reference)
+	query.Set("status", string(request.Status))
+	query.Del("signature")
+	for _, secret := range secrets {
+		query.Add("signature", SignRedirect(secret, timestamp, string(request.Environment), request.Reference, string(request.Status)))
+	}
+	redirect.RawQuery = query.Encode()
+	return redirect.String(), nil
+}
+
+// signingSecrets returns the unexpired secrets of the webhook URL of the
+// request's app, newest first. Unlike API keys, which are only stored as the
+// hash they are looked up by, they are stored encrypted and known only to
+// the app.
+func (s *CompletionService) signingSecrets(ctx context.Context, request *models.Request) ([]string, error) {
+	if request.AppID == nil {
+		return nil, ErrNoSigningSecret
+	}
+	secrets, err := activeWebhookSecrets(ctx, s.webhookSecretRepo, *request.AppID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, ErrNoSigningSecret
+	}
+	return secrets, nil
+}
+
+// SignRedirect signs the timestamp, environment, reference and status of a
+// finished request with one of the app's webhook secrets.
+func SignRedirect(secret string, timestamp int64, environment, reference, status string) string {
+	message := url.Values{}
+	message.Set("environment", environment)
+	message.Set("reference", reference)
+	message.Set("status", status)
+	message.Set("timestamp", strconv.FormatInt(timestamp, 10))
+	return crypto.SignHMACSHA256(secret, message.Encode())
+}
+
+// RequestedKYCLevel is the KYC level the request asks for, tier_1 if it does
+// not name a known one.
+func RequestedKYCLevel(request *models.Request) models.KYCLevel {
+	level := models.KYCLevel(request.KYCLevel)
+	if level.Rank() == 0 {
+		return models.KYCLevelTier1
+	}
+	return level
+}
+
+func joinRequirements(requirements []KYCRequirement) string {
+	names := make([]string, len(requirements))
+	for i, requirement := range requirements {
+		names[i] = string(requirement)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/internal/repositories/mocks"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakePermissionRepository struct {
+	permissions []models.CustomerPermission
+	outbox      []models.Webhook
+}
+
+func (r *fakePermissionRepository) Create(ctx context.Context, permission *models.CustomerPermission) error {
+	if permission.ID == "" {
+		permission.ID = fmt.Sprintf("permission-%d", len(r.permissions)+1)
+	}
+	r.permissions = append(r.permissions, *permission)
+	return nil
+}
+
+func (r *fakePermissionRepository) Update(ctx context.Context, permission *models.CustomerPermission) error {
+	for i := range r.permissions {
+		if r.permissions[i].ID == permission.ID {
+			r.permissions[i] = *permission
+		}
+	}
+	return nil
+}
+
+func (r *fakePermissionRepository) FindActive(ctx context.Context, customerID, companyID string) (*models.CustomerPermission, error) {
+	for i := range r.permissions {
+		if r.permissions[i].CustomerID == customerID && r.permissions[i].CompanyID == companyID && r.permissions[i].IsActive(time.Now()) {
+			permission := r.permissions[i]
+			return &permission, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakePermissionRepository) FindExpired(ctx context.Context, now time.Time, limit int) ([]models.CustomerPermission, error) {
+	var expired []models.CustomerPermission
+	for _, permission := range r.permissions {
+		if permission.ExpiresAt != nil && !permission.ExpiresAt.After(now) && permission.ExpiredAt == nil && permission.RevokedAt == nil {
+			expired = append(expired, permission)
+		}
+	}
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	return expired, nil
+}
+
+func (r *fakePermissionRepository) ListByCustomer(ctx context.Context, customerID string) ([]models.CustomerPermission, error) {
+	var permissions []models.CustomerPermission
+	for _, permission := range r.permissions {
+		if permission.CustomerID == customerID {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, nil
+}
+
+func (r *fakePermissionRepository) MarkExpired(ctx context.Context, permissionID string, at time.Time, outbox ...models.Webhook) (bool, error) {
+	for i := range r.permissions {
+		if r.permissions[i].ID == permissionID && r.permissions[i].ExpiredAt == nil && r.permissions[i].RevokedAt == nil {
+			r.permissions[i].ExpiredAt = &at
+			r.outbox = append(r.outbox, outbox...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakePermissionRepository) Revoke(ctx context.Context, permissionID string, at time.Time, outbox ...models.Webhook) (bool, error) {
+	for i := range r.permissions {
+		if r.permissions[i].ID == permissionID && r.permissions[i].RevokedAt == nil {
+			r.permissions[i].RevokedAt = &at
+			r.outbox = append(r.outbox, outbox...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newTestRedirectSecrets returns the webhook secrets app-1 signs its redirects
+// with, newest first.
+func newTestRedirectSecrets(t *testing.T, plain ...string) []models.WebhookSecret {
+	os.Setenv("ENCRYPTION_KEY", "7c633361cb709e1cf6ef0d68c914b5a5b1b540034331ab64702d2fd980dc7585")
+	t.Cleanup(func() { os.Unsetenv("ENCRYPTION_KEY") })
+
+	secrets := make([]models.WebhookSecret, 0, len(plain))
+	for _, secret := range plain {
+		stored, err := models.NewWebhookSecret(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets = append(secrets, *stored)
+	}
+	return secrets
+}
+
+func newTestCompletionService(request *models.Request, customer *models.Customer, secrets []models.WebhookSecret) (*CompletionService, *fakeCustomerRepository) {
+	service, customers, _ := newTestCompletionServiceWithPermissions(request, customer, secrets)
+	return service, customers
+}
+
+// otpConfirmedHistory is the history of a request whose customer verified an OTP.
+func otpConfirmedHistory(requestID string) []models.RequestEvent {
+	initiated, otpPending := models.RequestStatusInitiated, models.RequestStatusOTPPending
+	return []models.RequestEvent{
+		{RequestID: requestID, ToStatus: models.RequestStatusInitiated},
+		{RequestID: requestID, FromStatus: &initiated, ToStatus: models.RequestStatusOTPPending},
+		{RequestID: requestID, FromStatus: &otpPending, ToStatus: models.RequestStatusKYCProcessing},
+	}
+}
+
+func newTestCompletionServiceWithPermissions(request *models.Request, customer *models.Customer, secrets []models.WebhookSecret) (*CompletionService, *fakeCustomerRepository, *fakePermissionRepository) {
+	return newTestCompletionServiceWithHistory(request, customer, secrets, otpConfirmedHistory(request.ID))
+}
+
+func newTestCompletionServiceWithHistory(request *models.Request, customer *models.Customer, secrets []models.WebhookSecret, history []models.RequestEvent) (*CompletionService, *fakeCustomerRepository, *fakePermissionRepository) {
+	customers := &fakeCustomerRepository{}
+	permissions := &fakePermissionRepository{}
+	requestRepo := &mocks.MockRequestRepository{
+		ListEventsFunc: func(requestID string) ([]models.RequestEvent, error) {
+			return history, nil
+		},
+		CompleteFunc: func(request *models.Request, completion repositories.RequestCompletion) error {
+			if len(completion.CustomerFields) > 0 {
+				customers.UpdateFields(context.Background(), completion.CustomerID, completion.CustomerFields)
+			}
+			if completion.Permission == nil {
+				return nil
+			}
+			if completion.Permission.ID == "" {
+				return permissions.Create(context.Background(), completion.Permission)
+			}
+			return permissions.Update(context.Background(), completion.Permission)
+		},
+	}
+	kycService := NewKYCService(customers, requestRepo, permissions, nil, nil, nil, nil, nil)
+	return NewCompletionService(
+		&fakeKycService{IKycService: kycService, request: request, customer: customer},
+		customers,
+		permissions,
+		&fakeWebhookSecretRepository{secrets: map[string][]models.WebhookSecret{testAppID: secrets}},
+		NewRequestLifecycleService(requestRepo, newTestWebhookService()),
+		NewKYCRequirementsEngine(DefaultKYCRequirements),
+	), customers, permissions
+}
+
+func TestCompletionServiceComplete(t *testing.T) {
+	appID := "app-1"
+	// whsec_old is being rotated out.
+	secrets := newTestRedirectSecrets(t, "whsec_current", "whsec_old")
+	request := &models.Request{
+		ID:          "request-1",
+		AppID:       &appID,
+		CompanyID:   "company-1",
+		Reference:   "ref-1",
+		RedirectURL: "https://example.com/done?session=abc",
+		KYCLevel:    string(models.KYCLevelTier2),
+		Environment: models.EnvironmentSandbox,
+		Status:      models.RequestStatusKYCProcessing,
+	}
+	verifiedAt := time.Now()
+	customer := &models.Customer{
+		ID:                "customer-1",
+		KYCLevelAchieved:  string(models.KYCLevelTier1),
+		PhoneVerifiedAt:   &verifiedAt,
+		AddressVerifiedAt: &verifiedAt,
+		Identities:        []models.Identity{{Type: models.IdentityTypeBVN, Verified: true}},
+	}
+	service, customers, permissions := newTestCompletionServiceWithPermissions(request, customer, secrets)
+	permissions.permissions = append(permissions.permissions, models.CustomerPermission{
+		CustomerID: "customer-1",
+		CompanyID:  "company-1",
+		Scopes:     request.RequestedScopes(),
+	})
+
+	_, redirectURL, err := service.Complete(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusCompleted, request.Status)
+	assert.Equal(t, models.KYCLevelTier2, customers.updates["kyc_level_achieved"])
+	assert.Equal(t, models.StatusVerified, customers.updates["status"])
+
+	redirect, err := url.Parse(redirectURL)
+	assert.NoError(t, err)
+	query := redirect.Query()
+	assert.Equal(t, "abc", query.Get("session"))
+	assert.Equal(t, "ref-1", query.Get("reference"))
+	assert.Equal(t, "completed", query.Get("status"))
+	assert.Equal(t, "sandbox", query.Get("environment"))
+	timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(timestamp, 0), time.Minute)
+	assert.Equal(t, []string{
+		SignRedirect("whsec_current", timestamp, "sandbox", "ref-1", "completed"),
+		SignRedirect("whsec_old", timestamp, "sandbox", "ref-1", "completed"),
+	}, query["signature"])
+	assert.NotContains(t, query["signature"], SignRedirect("whsec_current", timestamp, "live", "ref-1", "completed"))
+}
+
+func TestCompletionServiceCompleteRequirements(t *testing.T) {
+	appID := "app-1"
+	secrets := newTestRedirectSecrets(t, "whsec_current")
+	request := &models.Request{
+		ID:          "request-1",
+		AppID:       &appID,
+		CompanyID:   "company-1",
+		KYCLevel:    string(models.KYCLevelTier2),
+		Environment: models.EnvironmentSandbox,
+		Status:      models.RequestStatusKYCProcessing,
+	}
+	// An address the integrator sent is not verified, and a KYC level the
+	// customer reached before does not stand in for the requirements.
+	address := "1 Marina, Lagos"
+	customer := &models.Customer{
+		ID:               "customer-1",
+		KYCLevelAchieved: string(models.KYCLevelTier2),
+		Address:          &address,
+		Identities:       []models.Identity{{Verified: true}},
+	}
+	service, customers, permissions := newTestCompletionServiceWithPermissions(request, customer, secrets)
+	permissions.permissions = append(permissions.permissions, models.CustomerPermission{
+		CustomerID: "customer-1",
+		CompanyID:  "company-1",
+		Scopes:     request.RequestedScopes(),
+	})
+
+	_, _, err := service.Complete(context.Background(), "token")
+	assert.ErrorIs(t, err, ErrKYCRequirementsNotMet)
+	assert.Contains(t, err.Error(), "phone, address")
+	assert.Equal(t, models.RequestStatusKYCProcessing, request.Status)
+
+	request.KYCLevel = string(models.KYCLevelTier1)
+	_, _, err = service.Complete(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusCompleted, request.Status)
+	assert.Equal(t, models.StatusVerified, customers.updates["status"])
+}
+
+func TestCompletionServiceCompleteNeedsTheOTPStep(t *testing.T) {
+	appID := "app-1"
+	secrets := newTestRedirectSecrets(t, "whsec_current")
+	for _, status := range []models.RequestStatus{models.RequestStatusInitiated, models.RequestStatusOTPPending} {
+		request := &models.Request{
+			ID:          "request-1",
+			AppID:       &appID,
+			KYCLevel:    string(models.KYCLevelTier1),
+			Environment: models.EnvironmentSandbox,
+			Status:      status,
+		}
+		customer := &models.Customer{Identities: []models.Identity{{Verified: true}}}
+		service, _ := newTestCompletionService(request, customer, secrets)
+
+		_, _, err := service.Complete(context.Background(), "token")
+		assert.ErrorIs(t, err, ErrKYCNotReady)
+		assert.Equal(t, status, request.Status)
+	}
+}
+
+func TestCompletionServiceFail(t *testing.T) {
+	appID := "app-1"
+	request := &models.Request{
+		ID:          "request-1",
+		AppID:       &appID,
+		Reference:   "ref-1",
+		RedirectURL: "https://example.com/done",
+		Environment: models.EnvironmentLive,
+		Status:      models.RequestStatusOTPPending,
+	}
+	secrets := newTestRedirectSecrets(t, "whsec_current")
+	service, _ := newTestCompletionService(request, &models.Customer{}, secrets)
+
+	_, redirectURL, err := service.Fail(context.Background(), "token", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusFailed, request.Status)
+	redirect, err := url.Parse(redirectURL)
+	assert.NoError(t, err)
+	query := redirect.Query()
+	assert.Equal(t, "failed", query.Get("status"))
+	timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{SignRedirect("whsec_current", timestamp, "live", "ref-1", "failed")}, query["signature"])
+}
+
+func TestCompletionServiceReusableKYC(t *testing.T) {
+	appID := "app-1"
+	secrets := newTestRedirectSecrets(t, "whsec_current")
+	request := &models.Request{
+		ID:          "request-1",
+		AppID:       &appID,
+		CompanyID:   "company-2",
+		KYCLevel:    string(models.KYCLevelTier2),
+		Environment: models.EnvironmentSandbox,
+		Status:      models.RequestStatusKYCProcessing,
+	}
+	// Verified to tier_2 through company-1.
+	verifiedAt := time.Now()
+	customer := &models.Customer{
+		ID:                "customer-1",
+		Status:            models.StatusVerified,
+		KYCLevelAchieved:  string(models.KYCLevelTier2),
+		PhoneVerifiedAt:   &verifiedAt,
+		AddressVerifiedAt: &verifiedAt,
+		Identities:        []models.Identity{{Type: models.IdentityTypeBVN, Verified: true}},
+	}
+	service, _, permissions := newTestCompletionServiceWithPermissions(request, customer, secrets)
+	permissions.permissions = append(permissions.permissions, models.CustomerPermission{
+		CustomerID: "customer-1",
+		CompanyID:  "company-1",
+		Scopes:     request.RequestedScopes(),
+	})
+
+	_, _, err := service.Complete(context.Background(), "token")
+	assert.ErrorIs(t, err, ErrConsentRequired)
+	assert.Equal(t, models.RequestStatusKYCProcessing, request.Status)
+
+	_, redirectURL, err := service.GrantConsent(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusCompleted, request.Status)
+	assert.Contains(t, redirectURL, "status=completed")
+	assert.Len(t, permissions.permissions, 2)
+	assert.Equal(t, "company-2", permissions.permissions[1].CompanyID)
+}
+
+func TestCompletionServiceConsentBelowRequestedTier(t *testing.T) {
+	request := &models.Request{ID: "request-1", CompanyID: "company-2", KYCLevel: string(models.KYCLevelTier2), Status: models.RequestStatusKYCProcessing}
+	customer := &models.Customer{ID: "customer-1", KYCLevelAchieved: string(models.KYCLevelTier1)}
+	service, _, permissions := newTestCompletionServiceWithPermissions(request, customer, nil)
+
+	_, redirectURL, err := service.GrantConsent(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.Empty(t, redirectURL)
+	assert.Equal(t, models.RequestStatusKYCProcessing, request.Status)
+	assert.Len(t, permissions.permissions, 1)
+}
+
+func TestCompletionServiceConsentNeedsTheCustomer(t *testing.T) {
+	initiated := models.RequestStatusInitiated
+	tests := []struct {
+		name    string
+		status  models.RequestStatus
+		history []models.RequestEvent
+		wantErr error
+	}{
+		{"before the otp", models.RequestStatusInitiated, nil, ErrKYCNotReady},
+		{"otp not verified", models.RequestStatusOTPPending, nil, ErrKYCNotReady},
+		{"sent to review", models.RequestStatusKYCProcessing, []models.RequestEvent{
+			{RequestID: "request-1", ToStatus: models.RequestStatusInitiated},
+			{RequestID: "request-1", FromStatus: &initiated, ToStatus: models.RequestStatusKYCProcessing},
+		}, ErrCustomerNotConfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &models.Request{ID: "request-1", CompanyID: "company-2", KYCLevel: string(models.KYCLevelTier1), Status: tt.status}
+			customer := &models.Customer{
+				ID:               "customer-1",
+				KYCLevelAchieved: string(models.KYCLevelTier1),
+				Identities:       []models.Identity{{Type: models.IdentityTypeBVN, Verified: true}},
+			}
+			service, _, permissions := newTestCompletionServiceWithHistory(request, customer, nil, tt.history)
+
+			_, _, err := service.GrantConsent(context.Background(), "token")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Empty(t, permissions.permissions)
+
+			_, _, err = service.Complete(context.Background(), "token")
+			assert.Error(t, err)
+			assert.Equal(t, tt.status, request.Status)
+		})
+	}
+}
+
+func TestCompletionServiceGrantsRequestedAccess(t *testing.T) {
+	appID := "app-1"
+	days := 30
+	secrets := newTestRedirectSecrets(t, "whsec_current")
+	request := &models.Request{
+		ID:          "request-2",
+		AppID:       &appID,
+		CompanyID:   "company-1",
+		KYCLevel:    string(models.KYCLevelTier1),
+		Environment: models.EnvironmentSandbox,
+		Status:      models.RequestStatusKYCProcessing,
+		Scopes:      models.PermissionScopes{models.ScopeIdentity, models.ScopeBankAccounts},
+		AccessType:  models.AccessTemporary,
+		AccessDays:  &days,
+	}
+	customer := &models.Customer{
+		ID:               "customer-1",
+		Status:           models.StatusVerified,
+		KYCLevelAchieved: string(models.KYCLevelTier1),
+		Identities:       []models.Identity{{Type: models.IdentityTypeBVN, Verified: true}},
+	}
+	service, _, permissions := newTestCompletionServiceWithPermissions(request, customer, secrets)
+	permissions.permissions = append(permissions.permissions, models.CustomerPermission{
+		ID:         "permission-1",
+		CustomerID: "customer-1",
+		CompanyID:  "company-1",
+		Scopes:     models.PermissionScopes{models.ScopeIdentity},
+		AccessType: models.AccessPermanent,
+	})
+
+	// Bank accounts were not covered by the earlier consent.
+	_, _, err := service.Complete(context.Background(), "token")
+	assert.ErrorIs(t, err, ErrConsentRequired)
+
+	_, _, err = service.GrantConsent(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusCompleted, request.Status)
+	assert.Len(t, permissions.permissions, 1)
+	permission := permissions.permissions[0]
+	assert.True(t, permission.Scopes.Covers(models.PermissionScopes{models.ScopeIdentity, models.ScopeBankAccounts}))
+	assert.Equal(t, models.AccessTemporary, permission.AccessType)
+	if assert.NotNil(t, permission.ExpiresAt) {
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, days), *permission.ExpiresAt, time.Minute)
+	}
+	assert.Equal(t, "request-2", *permission.RequestID)
+}
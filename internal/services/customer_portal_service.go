@@ -0,0 +1,277 @@
+package services
+
+import (
+	auth "confam-api/internal/auth"
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	portalLoginTTL             = 15 * time.Minute
+	portalSessionTTL           = time.Hour
+	maxPortalLoginSends        = 5
+	portalLoginSendWindow      = 15 * time.Minute
+	maxPortalLoginAttempts     = 5
+	portalLoginAttemptsLockout = 30 * time.Minute
+)
+
+var (
+	ErrInvalidPortalLogin    = errors.New("This login link or code is invalid or has expired")
+	ErrTooManyPortalLogins   = errors.New("Too many login requests. Please try again later")
+	ErrTooManyPortalAttempts = errors.New("Too many invalid codes. Please try again later")
+)
+
+// PortalCompany is a company that ran KYC against the customer, with the
+// access the customer last granted it. Permission is nil when the customer
+// never granted the company access.
+type PortalCompany struct {
+	ID            string                     `json:"id"`
+	Name          string                     `json:"name"`
+	Logo          *string                    `json:"logo"`
+	Requests      int                        `json:"requests"`
+	LastRequestAt *time.Time                 `json:"last_request_at"`
+	Permission    *models.CustomerPermission `json:"permission"`
+	HasAccess     bool                       `json:"has_access"`
+}
+
+type ICustomerPortalService interface {
+	RequestLogin(ctx context.Context, email string) error
+	VerifyLogin(ctx context.Context, req structs.PortalVerifyLoginRequest) (*structs.AuthTokens, error)
+	ListCompanies(ctx context.Context, customer *models.Customer) ([]PortalCompany, error)
+	RevokeAccess(ctx context.Context, customer *models.Customer, companyID string) (*models.CustomerPermission, error)
+}
+
+// CustomerPortalService lets customers see which companies ran KYC against
+// them and take back the access they granted. Customers log in with a magic
+// link, or the code sent along with it, emailed to them. Only live customers
+// can log in; sandbox customers are test data.
+type CustomerPortalService struct {
+	customerRepo      repositories.ICustomerRepository
+	requestRepo       repositories.IRequestRepository
+	permissionRepo    repositories.ICustomerPermissionRepository
+	loginRepo         repositories.IPortalLoginRepository
+	attemptRepo       repositories.IAttemptRepository
+	permissionService IPermissionService
+	mailService       IMailService
+}
+
+func NewCustomerPortalService(
+	customerRepo repositories.ICustomerRepository,
+	requestRepo repositories.IRequestRepository,
+	permissionRepo repositories.ICustomerPermissionRepository,
+	loginRepo repositories.IPortalLoginRepository,
+	attemptRepo repositories.IAttemptRepository,
+	permissionService IPermissionService,
+	mailService IMailService,
+) *CustomerPortalService {
+	return &CustomerPortalService{
+		customerRepo:      customerRepo,
+		requestRepo:       requestRepo,
+		permissionRepo:    permissionRepo,
+		loginRepo:         loginRepo,
+		attemptRepo:       attemptRepo,
+		permissionService: permissionService,
+		mailService:       mailService,
+	}
+}
+
+// portalURL is the base URL of the customer portal frontend.
+func portalURL() string {
+	if u := os.Getenv("PORTAL_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return dashboardURL() + "/portal"
+}
+
+func portalLoginSendKey(emailHash string) string {
+	return "portal:login:" + emailHash
+}
+
+func portalLoginVerifyKey(emailHash string) string {
+	return "portal:verify:" + emailHash
+}
+
+// RequestLogin emails a magic link and code to the customer if the email
+// belongs to one. Like ForgotPassword it never reports whether it does; only
+// too many requests for the same email are refused.
+func (s *CustomerPortalService) RequestLogin(ctx context.Context, email string) error {
+	emailHash := crypto.HashSHA256(email)
+	sends, err := s.attemptRepo.Increment(ctx, portalLoginSendKey(emailHash), portalLoginSendWindow)
+	if err != nil {
+		return err
+	}
+	if sends > maxPortalLoginSends {
+		return ErrTooManyPortalLogins
+	}
+
+	customer, err := s.customerRepo.FindByEmailHash(emailHash, models.EnvironmentLive)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to look up customer for portal login: %v", err)
+		}
+		return nil
+	}
+
+	token, err := crypto.GenerateHexToken()
+	if err != nil {
+		log.Printf("Failed to generate portal login token: %v", err)
+		return nil
+	}
+	code, err := generateOTPCode()
+	if err != nil {
+		log.Printf("Failed to generate portal login code: %v", err)
+		return nil
+	}
+	login := &models.PortalLogin{
+		CustomerID: customer.ID,
+		TokenHash:  crypto.HashSHA256(token),
+		CodeHash:   crypto.HashSHA256(code),
+		ExpiresAt:  time.Now().Add(portalLoginTTL),
+	}
+	if err := s.loginRepo.Save(ctx, login, portalLoginTTL); err != nil {
+		log.Printf("Failed to store portal login for customer %s: %v", customer.ID, err)
+		return nil
+	}
+
+	link := fmt.Sprintf("%s/login?token=%s", portalURL(), url.QueryEscape(token))
+	body := fmt.Sprintf(
+		"Use the link below within %s to see and manage the businesses you have shared your KYC with, or enter this code: %s\n\nIf you did not request this, you can ignore this email.\n\n%s",
+		portalLoginTTL,
+		code,
+		link,
+	)
+	if err := s.mailService.Send(ctx, customer.Email, "Your Confam login link", body); err != nil {
+		log.Printf("Failed to send portal login email to customer %s: %v", customer.ID, err)
+	}
+	return nil
+}
+
+// VerifyLogin consumes a magic link token, or the email and code sent with
+// it, and returns a portal access token for the customer.
+func (s *CustomerPortalService) VerifyLogin(ctx context.Context, req structs.PortalVerifyLoginRequest) (*structs.AuthTokens, error) {
+	login, err := s.findLogin(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loginRepo.Delete(ctx, login); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := auth.SignToken(login.CustomerID, auth.PurposeCustomerPortal, portalSessionTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &structs.AuthTokens{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(portalSessionTTL.Seconds()),
+	}, nil
+}
+
+func (s *CustomerPortalService) findLogin(ctx context.Context, req structs.PortalVerifyLoginRequest) (*models.PortalLogin, error) {
+	if req.Token != "" {
+		login, err := s.loginRepo.FindByToken(ctx, crypto.HashSHA256(req.Token))
+		if errors.Is(err, repositories.ErrPortalLoginNotFound) {
+			return nil, ErrInvalidPortalLogin
+		}
+		return login, err
+	}
+
+	emailHash := crypto.HashSHA256(req.Email)
+	attempts, err := s.attemptRepo.Increment(ctx, portalLoginVerifyKey(emailHash), portalLoginAttemptsLockout)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > maxPortalLoginAttempts {
+		return nil, ErrTooManyPortalAttempts
+	}
+
+	customer, err := s.customerRepo.FindByEmailHash(emailHash, models.EnvironmentLive)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPortalLogin
+		}
+		return nil, err
+	}
+	login, err := s.loginRepo.FindByCustomer(ctx, customer.ID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrPortalLoginNotFound) {
+			return nil, ErrInvalidPortalLogin
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(login.CodeHash), []byte(crypto.HashSHA256(req.Code))) != 1 {
+		return nil, ErrInvalidPortalLogin
+	}
+	s.attemptRepo.Reset(ctx, portalLoginVerifyKey(emailHash))
+	return login, nil
+}
+
+// ListCompanies returns every company that ran KYC against the customer or
+// was granted access to their KYC, most recently active first.
+func (s *CustomerPortalService) ListCompanies(ctx context.Context, customer *models.Customer) ([]PortalCompany, error) {
+	requests, err := s.requestRepo.ListByCustomer(customer.ID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := s.permissionRepo.ListByCustomer(ctx, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	companies := []PortalCompany{}
+	index := map[string]int{}
+	companyAt := func(companyID string, company *models.Company) *PortalCompany {
+		i, ok := index[companyID]
+		if !ok {
+			i = len(companies)
+			index[companyID] = i
+			companies = append(companies, PortalCompany{ID: companyID})
+		}
+		if company != nil {
+			companies[i].Name = company.Name
+			companies[i].Logo = company.Logo
+		}
+		return &companies[i]
+	}
+
+	for _, request := range requests {
+		company := companyAt(request.CompanyID, request.Company)
+		company.Requests++
+		if company.LastRequestAt == nil {
+			createdAt := request.CreatedAt
+			company.LastRequestAt = &createdAt
+		}
+	}
+
+	now := time.Now()
+	for i := range permissions {
+		company := companyAt(permissions[i].CompanyID, permissions[i].Company)
+		if company.Permission != nil {
+			continue
+		}
+		permission := permissions[i]
+		permission.Company = nil
+		company.Permission = &permission
+		company.HasAccess = permission.IsActive(now)
+	}
+	return companies, nil
+}
+
+// RevokeAccess takes back the company's access to the customer's KYC. The
+// company's app is notified and can no longer fetch the customer's data.
+func (s *CustomerPortalService) RevokeAccess(ctx context.Context, customer *models.Customer, companyID string) (*models.CustomerPermission, error) {
+	return s.permissionService.Revoke(ctx, customer, companyID)
+}
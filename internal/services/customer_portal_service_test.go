@@ -0,0 +1,256 @@
+package services
+
+import (
+	auth "confam-api/internal/auth"
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/internal/repositories/mocks"
+	structs "confam-api/internal/structs"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakePortalCustomerRepository struct {
+	fakeCustomerRepository
+	customers []*models.Customer
+}
+
+func (r *fakePortalCustomerRepository) FindByEmailHash(emailHash string, environment models.Environment) (*models.Customer, error) {
+	for _, customer := range r.customers {
+		if customer.EmailHash == emailHash && customer.Environment == environment {
+			return customer, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakePortalLoginRepository struct {
+	logins map[string]*models.PortalLogin
+}
+
+func (r *fakePortalLoginRepository) Save(ctx context.Context, login *models.PortalLogin, ttl time.Duration) error {
+	r.logins[login.CustomerID] = login
+	return nil
+}
+
+func (r *fakePortalLoginRepository) FindByCustomer(ctx context.Context, customerID string) (*models.PortalLogin, error) {
+	if login, ok := r.logins[customerID]; ok {
+		return login, nil
+	}
+	return nil, repositories.ErrPortalLoginNotFound
+}
+
+func (r *fakePortalLoginRepository) FindByToken(ctx context.Context, tokenHash string) (*models.PortalLogin, error) {
+	for _, login := range r.logins {
+		if login.TokenHash == tokenHash {
+			return login, nil
+		}
+	}
+	return nil, repositories.ErrPortalLoginNotFound
+}
+
+func (r *fakePortalLoginRepository) Delete(ctx context.Context, login *models.PortalLogin) error {
+	delete(r.logins, login.CustomerID)
+	return nil
+}
+
+type fakeMailService struct {
+	to, body string
+	sent     int
+}
+
+func (s *fakeMailService) Send(ctx context.Context, to, subject, body string) error {
+	s.to, s.body = to, body
+	s.sent++
+	return nil
+}
+
+var (
+	portalLoginCodePattern  = regexp.MustCompile(`code: (\d{6})`)
+	portalLoginTokenPattern = regexp.MustCompile(`token=([0-9a-f]+)`)
+)
+
+func newTestPortalService(customer *models.Customer) (*CustomerPortalService, *fakeMailService, *fakePermissionRepository, *mocks.MockRequestRepository) {
+	mail := &fakeMailService{}
+	permissions := &fakePermissionRepository{}
+	requestRepo := &mocks.MockRequestRepository{}
+	permissionService, _ := newTestPermissionService()
+	permissionService.permissionRepo = permissions
+	service := NewCustomerPortalService(
+		&fakePortalCustomerRepository{customers: []*models.Customer{customer}},
+		requestRepo,
+		permissions,
+		&fakePortalLoginRepository{logins: map[string]*models.PortalLogin{}},
+		&fakeAttemptRepository{counts: map[string]int64{}},
+		permissionService,
+		mail,
+	)
+	return service, mail, permissions, requestRepo
+}
+
+func TestCustomerPortalServiceLogin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	customer := &models.Customer{
+		ID:          "customer-1",
+		Email:       "ada@example.com",
+		EmailHash:   crypto.HashSHA256("ada@example.com"),
+		Environment: models.EnvironmentLive,
+	}
+	service, mail, _, _ := newTestPortalService(customer)
+	ctx := context.Background()
+
+	// Unknown emails are not reported.
+	assert.NoError(t, service.RequestLogin(ctx, "nobody@example.com"))
+	assert.Equal(t, 0, mail.sent)
+
+	assert.NoError(t, service.RequestLogin(ctx, "Ada@Example.com"))
+	assert.Equal(t, "ada@example.com", mail.to)
+	code := portalLoginCodePattern.FindStringSubmatch(mail.body)[1]
+	token := portalLoginTokenPattern.FindStringSubmatch(mail.body)[1]
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	_, err := service.VerifyLogin(ctx, structs.PortalVerifyLoginRequest{Email: "ada@example.com", Code: wrong})
+	assert.ErrorIs(t, err, ErrInvalidPortalLogin)
+
+	tokens, err := service.VerifyLogin(ctx, structs.PortalVerifyLoginRequest{Email: "ada@example.com", Code: code})
+	assert.NoError(t, err)
+	claims, err := auth.ParseToken(tokens.AccessToken, auth.PurposeCustomerPortal)
+	assert.NoError(t, err)
+	assert.Equal(t, "customer-1", claims.Subject)
+
+	// The link is single use, and goes with the code.
+	_, err = service.VerifyLogin(ctx, structs.PortalVerifyLoginRequest{Token: token})
+	assert.ErrorIs(t, err, ErrInvalidPortalLogin)
+
+	assert.NoError(t, service.RequestLogin(ctx, "ada@example.com"))
+	token = portalLoginTokenPattern.FindStringSubmatch(mail.body)[1]
+	_, err = service.VerifyLogin(ctx, structs.PortalVerifyLoginRequest{Token: token})
+	assert.NoError(t, err)
+}
+
+func TestCustomerPortalServiceLoginLimits(t *testing.T) {
+	customer := &models.Customer{ID: "customer-1", EmailHash: crypto.HashSHA256("ada@example.com"), Environment: models.EnvironmentLive}
+	service, _, _, _ := newTestPortalService(customer)
+	ctx := context.Background()
+
+	for i := 0; i < maxPortalLoginSends; i++ {
+		assert.NoError(t, service.RequestLogin(ctx, "ada@example.com"))
+	}
+	assert.ErrorIs(t, service.RequestLogin(ctx, "ada@example.com"), ErrTooManyPortalLogins)
+
+	for i := 0; i < maxPortalLoginAttempts; i++ {
+		service.VerifyLogin(ctx, structs.PortalVerifyLoginRequest{Email: "ada@example.com", Code: "000000"})
+	}
+	_, err := service.VerifyLogin(ctx, structs.PortalVerifyLoginRequest{Email: "ada@example.com", Code: "000000"})
+	assert.ErrorIs(t, err, ErrTooManyPortalAttempts)
+}
+
+func TestCustomerPortalServiceCompanies(t *testing.T) {
+	customer := &models.Customer{ID: "customer-1", Token: "customer-token", Environment: models.EnvironmentLive}
+	service, _, permissions, requestRepo := newTestPortalService(customer)
+	ctx := context.Background()
+
+	now := time.Now()
+	appID := "app-1"
+	acme := &models.Company{ID: "company-1", Name: "Acme"}
+	globex := &models.Company{ID: "company-2", Name: "Globex"}
+	requestRepo.ListByCustomerFunc = func(customerID string) ([]models.Request, error) {
+		return []models.Request{
+			{CompanyID: "company-2", Company: globex, CreatedAt: now},
+			{CompanyID: "company-1", Company: acme, CreatedAt: now.Add(-time.Hour)},
+			{CompanyID: "company-1", Company: acme, CreatedAt: now.Add(-2 * time.Hour)},
+		}, nil
+	}
+	permissions.permissions = []models.CustomerPermission{
+		{ID: "permission-1", CustomerID: "customer-1", CompanyID: "company-1", Company: acme, AppID: &appID, Scopes: models.PermissionScopes{models.ScopeIdentity}},
+	}
+
+	companies, err := service.ListCompanies(ctx, customer)
+	assert.NoError(t, err)
+	assert.Len(t, companies, 2)
+	assert.Equal(t, "Globex", companies[0].Name)
+	assert.Nil(t, companies[0].Permission)
+	assert.False(t, companies[0].HasAccess)
+	assert.Equal(t, "Acme", companies[1].Name)
+	assert.Equal(t, 2, companies[1].Requests)
+	assert.True(t, companies[1].HasAccess)
+
+	_, err = service.RevokeAccess(ctx, customer, "company-1")
+	assert.NoError(t, err)
+	_, err = service.RevokeAccess(ctx, customer, "company-2")
+	assert.ErrorIs(t, err, ErrPermissionNotFound)
+
+	companies, err = service.ListCompanies(ctx, customer)
+	assert.NoError(t, err)
+	assert.False(t, companies[1].HasAccess)
+	assert.NotNil(t, companies[1].Permission.RevokedAt)
+}
+
+func TestCustomerPortalServiceRevokedCompanyCannotRegainAccess(t *testing.T) {
+	customer := &models.Customer{
+		ID:               "customer-1",
+		Status:           models.StatusVerified,
+		KYCLevelAchieved: string(models.KYCLevelTier1),
+		Environment:      models.EnvironmentLive,
+		Identities:       []models.Identity{{Type: models.IdentityTypeBVN, Verified: true}},
+	}
+	portal, _, permissions, _ := newTestPortalService(customer)
+	ctx := context.Background()
+	appID := "app-1"
+	permissions.permissions = []models.CustomerPermission{
+		{ID: "permission-1", CustomerID: "customer-1", CompanyID: "company-1", AppID: &appID, Scopes: models.PermissionScopes{models.ScopeIdentity}},
+	}
+
+	_, err := portal.RevokeAccess(ctx, customer, "company-1")
+	assert.NoError(t, err)
+
+	// The company starts a new request and uses its KYC token itself, both
+	// before the OTP step and once its request was sent to manual review.
+	initiated := models.RequestStatusInitiated
+	attempts := []struct {
+		status  models.RequestStatus
+		history []models.RequestEvent
+	}{
+		{models.RequestStatusInitiated, nil},
+		{models.RequestStatusKYCProcessing, []models.RequestEvent{
+			{RequestID: "request-2", ToStatus: models.RequestStatusInitiated},
+			{RequestID: "request-2", FromStatus: &initiated, ToStatus: models.RequestStatusKYCProcessing},
+		}},
+	}
+	for _, attempt := range attempts {
+		request := &models.Request{
+			ID:          "request-2",
+			AppID:       &appID,
+			CompanyID:   "company-1",
+			KYCLevel:    string(models.KYCLevelTier1),
+			Environment: models.EnvironmentLive,
+			Status:      attempt.status,
+		}
+		secrets := newTestRedirectSecrets(t, "whsec_current")
+		completion, _, consentPermissions := newTestCompletionServiceWithHistory(request, customer, secrets, attempt.history)
+		consentPermissions.permissions = permissions.permissions
+
+		_, _, err = completion.GrantConsent(ctx, "token")
+		assert.Error(t, err)
+		_, _, err = completion.Complete(ctx, "token")
+		assert.Error(t, err)
+
+		assert.Equal(t, attempt.status, request.Status)
+		_, err = consentPermissions.FindActive(ctx, "customer-1", "company-1")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	}
+
+	companies, err := portal.ListCompanies(ctx, customer)
+	assert.NoError(t, err)
+	assert.Len(t, companies, 1)
+	assert.False(t, companies[0].HasAccess)
+}
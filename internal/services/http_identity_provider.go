@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	models "confam-api/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultIdentityProviderTimeout      = 10 * time.Second
+	defaultIdentityProviderRetryBackoff = 500 * time.Millisecond
+)
+
+// HTTPIdentityProviderConfig configures an HTTPIdentityProvider. Timeout
+// applies to each attempt; a lookup that times out or gets a 5xx or 429 is
+// retried up to MaxRetries more times, waiting RetryBackoff, then twice as
+// long, between attempts.
+type HTTPIdentityProviderConfig struct {
+	Name         string
+	BaseURL      string
+	APIKey       string
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// HTTPIdentityProvider looks up identities with a provider's HTTP API:
+//
+//	POST <base URL>/v1/identities/lookup  {"type": "BVN", "number": "..."}
+//
+// The provider answers 200 with an IdentityRecord, 404 when it does not know
+// the number, and 202 when the identity has been queued for manual review.
+type HTTPIdentityProvider struct {
+	config HTTPIdentityProviderConfig
+	client *http.Client
+}
+
+func NewHTTPIdentityProvider(config HTTPIdentityProviderConfig) *HTTPIdentityProvider {
+	if config.Name == "" {
+		config.Name = "http"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultIdentityProviderTimeout
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultIdentityProviderRetryBackoff
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &HTTPIdentityProvider{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (p *HTTPIdentityProvider) Name() string {
+	return p.config.Name
+}
+
+// errRetryable marks a failed attempt that is worth trying again.
+type errRetryable struct {
+	err error
+}
+
+func (e errRetryable) Error() string { return e.err.Error() }
+func (e errRetryable) Unwrap() error { return e.err }
+
+func (p *HTTPIdentityProvider) Lookup(ctx context.Context, identityType models.IdentityType, number string) (*IdentityRecord, error) {
+	body, err := json.Marshal(map[string]string{
+		"type":   strings.ToUpper(string(identityType)),
+		"number": number,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode identity lookup: %w", err)
+	}
+
+	backoff := p.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		record, err := p.lookup(ctx, body)
+		var retryable errRetryable
+		if !errors.As(err, &retryable) {
+			return record, err
+		}
+		if attempt >= p.config.MaxRetries {
+			return nil, retryable.err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrIdentityProviderTimeout
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (p *HTTPIdentityProvider) lookup(ctx context.Context, body []byte) (*IdentityRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+"/v1/identities/lookup", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build identity lookup: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+
+	response, err := p.client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return nil, errRetryable{ErrIdentityProviderTimeout}
+		}
+		return nil, errRetryable{fmt.Errorf("identity provider request failed: %w", err)}
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusOK:
+		var record IdentityRecord
+		if err := json.NewDecoder(response.Body).Decode(&record); err != nil {
+			return nil, fmt.Errorf("failed to decode identity record: %w", err)
+		}
+		return &record, nil
+	case response.StatusCode == http.StatusAccepted:
+		return nil, ErrIdentityReviewRequired
+	case response.StatusCode == http.StatusNotFound:
+		return nil, ErrIdentityNotFound
+	case response.StatusCode == http.StatusGatewayTimeout:
+		return nil, errRetryable{ErrIdentityProviderTimeout}
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return nil, errRetryable{fmt.Errorf("identity provider responded with %s", response.Status)}
+	default:
+		return nil, fmt.Errorf("identity provider responded with %s", response.Status)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"confam-api/internal/identitytest"
+	models "confam-api/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHTTPIdentityProvider(server *identitytest.Server, maxRetries int) *HTTPIdentityProvider {
+	return NewHTTPIdentityProvider(HTTPIdentityProviderConfig{
+		Name:         "test",
+		BaseURL:      server.URL,
+		APIKey:       server.APIKey,
+		Timeout:      100 * time.Millisecond,
+		MaxRetries:   maxRetries,
+		RetryBackoff: time.Millisecond,
+	})
+}
+
+func TestHTTPIdentityProviderLookup(t *testing.T) {
+	server := identitytest.NewServer()
+	defer server.Close()
+	server.APIKey = "provider-key"
+	server.Add("BVN", "22222222222", identitytest.Record{FirstName: "Ada", LastName: "Obi", DateOfBirth: "1990-05-01", Phone: "+2348011111111", Reference: "ref_1"})
+	server.Review("NIN", "33333333333")
+
+	provider := newTestHTTPIdentityProvider(server, 0)
+	ctx := context.Background()
+
+	record, err := provider.Lookup(ctx, models.IdentityTypeBVN, "22222222222")
+	assert.NoError(t, err)
+	assert.Equal(t, "Ada", record.FirstName)
+	assert.Equal(t, "1990-05-01", record.DateOfBirth)
+	assert.Equal(t, "ref_1", record.Reference)
+
+	_, err = provider.Lookup(ctx, models.IdentityTypeNIN, "22222222222")
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+
+	_, err = provider.Lookup(ctx, models.IdentityTypeNIN, "33333333333")
+	assert.ErrorIs(t, err, ErrIdentityReviewRequired)
+}
+
+func TestHTTPIdentityProviderRetries(t *testing.T) {
+	server := identitytest.NewServer()
+	defer server.Close()
+	server.Add("NIN", "12345678901", identitytest.Record{Reference: "ref_2"})
+
+	server.FailNext(2)
+	record, err := newTestHTTPIdentityProvider(server, 2).Lookup(context.Background(), models.IdentityTypeNIN, "12345678901")
+	assert.NoError(t, err)
+	assert.Equal(t, "ref_2", record.Reference)
+	assert.Equal(t, 3, server.Requests())
+
+	server.FailNext(2)
+	_, err = newTestHTTPIdentityProvider(server, 1).Lookup(context.Background(), models.IdentityTypeNIN, "12345678901")
+	assert.Error(t, err)
+	assert.Equal(t, 5, server.Requests())
+}
+
+func TestHTTPIdentityProviderTimeout(t *testing.T) {
+	server := identitytest.NewServer()
+	defer server.Close()
+	server.Add("BVN", "22222222222", identitytest.Record{})
+	server.Delay(time.Second)
+
+	_, err := newTestHTTPIdentityProvider(server, 1).Lookup(context.Background(), models.IdentityTypeBVN, "22222222222")
+	assert.ErrorIs(t, err, ErrIdentityProviderTimeout)
+	assert.Equal(t, 2, server.Requests())
+}
+
+func TestIdentityProviderRegistry(t *testing.T) {
+	registry := NewIdentityProviderRegistry()
+	registry.Register(models.IdentityTypeBVN, NewSandboxIdentityProvider())
+
+	provider, ok := registry.Provider("bvn")
+	assert.True(t, ok)
+	assert.Equal(t, "sandbox", provider.Name())
+
+	_, ok = registry.Provider(models.IdentityTypeNIN)
+	assert.False(t, ok)
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrIdentityNotFound        = errors.New("identity could not be verified")
+	ErrIdentityProviderTimeout = errors.New("identity provider timed out")
+	ErrIdentityReviewRequired  = errors.New("identity requires manual review")
+)
+
+// IdentityRecord is what an identity provider knows about a BVN or NIN.
+type IdentityRecord struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	DateOfBirth string `json:"date_of_birth"`
+	Phone       string `json:"phone"`
+	Address     string `json:"address"`
+	Photo       string `json:"photo"`
+	Reference   string `json:"reference"`
+}
+
+// IdentityProvider looks up identity numbers with a verification provider.
+// Lookup returns ErrIdentityNotFound when the provider does not know the
+// number, and ErrIdentityProviderTimeout when it could not be reached in time.
+type IdentityProvider interface {
+	Name() string
+	Lookup(ctx context.Context, identityType models.IdentityType, number string) (*IdentityRecord, error)
+}
+
+// IdentityProviderRegistry holds the provider that verifies each identity type.
+type IdentityProviderRegistry struct {
+	providers map[models.IdentityType]IdentityProvider
+}
+
+func NewIdentityProviderRegistry() *IdentityProviderRegistry {
+	return &IdentityProviderRegistry{providers: make(map[models.IdentityType]IdentityProvider)}
+}
+
+// Register makes provider the one that verifies identityType.
+func (r *IdentityProviderRegistry) Register(identityType models.IdentityType, provider IdentityProvider) {
+	r.providers[identityType] = provider
+}
+
+// Provider returns the provider registered for identityType, if any.
+func (r *IdentityProviderRegistry) Provider(identityType models.IdentityType) (IdentityProvider, bool) {
+	provider, ok := r.providers[models.IdentityType(strings.ToUpper(string(identityType)))]
+	return provider, ok
+}
+
+// NewIdentityProviderRegistryFromEnv registers an HTTP provider for every
+// identity type that has a base URL configured. Each setting can be given per
+// type, e.g. IDENTITY_PROVIDER_BVN_URL, falling back to the shared one,
+// e.g. IDENTITY_PROVIDER_URL:
+//
+//	IDENTITY_PROVIDER_URL          base URL of the provider
+//	IDENTITY_PROVIDER_API_KEY      bearer token sent to the provider
+//	IDENTITY_PROVIDER_TIMEOUT      timeout of each attempt, e.g. "10s"
+//	IDENTITY_PROVIDER_MAX_RETRIES  retries after a failed attempt
+func NewIdentityProviderRegistryFromEnv() *IdentityProviderRegistry {
+	registry := NewIdentityProviderRegistry()
+	for _, identityType := range []models.IdentityType{models.IdentityTypeBVN, models.IdentityTypeNIN} {
+		setting := func(name string) string {
+			if v := os.Getenv("IDENTITY_PROVIDER_" + string(identityType) + "_" + name); v != "" {
+				return v
+			}
+			return os.Getenv("IDENTITY_PROVIDER_" + name)
+		}
+
+		baseURL := setting("URL")
+		if baseURL == "" {
+			continue
+		}
+		config := HTTPIdentityProviderConfig{
+			Name:    "http:" + strings.ToLower(string(identityType)),
+			BaseURL: baseURL,
+			APIKey:  setting("API_KEY"),
+		}
+		if timeout, err := time.ParseDuration(setting("TIMEOUT")); err == nil {
+			config.Timeout = timeout
+		}
+		if retries, err := strconv.Atoi(setting("MAX_RETRIES")); err == nil {
+			config.MaxRetries = retries
+		}
+		registry.Register(identityType, NewHTTPIdentityProvider(config))
+	}
+	return registry
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// KYCRequirement is a piece of evidence a customer must have for a KYC tier.
+type KYCRequirement string
+
+const (
+	RequirementIdentity KYCRequirement = "identity" // a verified BVN or NIN
+	RequirementPhone    KYCRequirement = "phone"    // a phone number verified by OTP
+	RequirementAddress  KYCRequirement = "address"  // an address returned by the identity provider
+	RequirementSelfie   KYCRequirement = "selfie"   // a passed facial recognition match
+	RequirementDocument KYCRequirement = "document"
+)
+
+var kycTiers = []models.KYCLevel{models.KYCLevelTier1, models.KYCLevelTier2, models.KYCLevelTier3}
+
+// DefaultKYCRequirements are the requirements each tier adds to the tier below
+// it. Nothing checks selfies or documents yet, so no customer reaches tier_3
+// until those checks exist.
+var DefaultKYCRequirements = map[models.KYCLevel][]KYCRequirement{
+	models.KYCLevelTier1: {RequirementIdentity},
+	models.KYCLevelTier2: {RequirementPhone, RequirementAddress},
+	models.KYCLevelTier3: {RequirementSelfie, RequirementDocument},
+}
+
+// KYCEvaluation is how far a customer is from a KYC level.
+type KYCEvaluation struct {
+	Level     models.KYCLevel  `json:"level"`
+	Satisfied []KYCRequirement `json:"satisfied"`
+	Remaining []KYCRequirement `json:"remaining"`
+}
+
+// IsComplete reports whether the customer meets every requirement of the level.
+func (e *KYCEvaluation) IsComplete() bool {
+	return len(e.Remaining) == 0
+}
+
+// KYCRequirementsEngine decides what a customer needs for each KYC tier.
+// Tiers are cumulative: tier_2 needs everything tier_1 needs and more.
+type KYCRequirementsEngine struct {
+	tiers map[models.KYCLevel][]KYCRequirement
+}
+
+// NewKYCRequirementsEngine builds an engine from the requirements each tier
+// adds to the tier below it.
+func NewKYCRequirementsEngine(added map[models.KYCLevel][]KYCRequirement) *KYCRequirementsEngine {
+	tiers := make(map[models.KYCLevel][]KYCRequirement, len(kycTiers))
+	var cumulative []KYCRequirement
+	for _, tier := range kycTiers {
+		for _, requirement := range added[tier] {
+			if !containsRequirement(cumulative, requirement) {
+				cumulative = append(cumulative, requirement)
+			}
+		}
+		tiers[tier] = append([]KYCRequirement(nil), cumulative...)
+	}
+	return &KYCRequirementsEngine{tiers: tiers}
+}
+
+// NewKYCRequirementsEngineFromEnv builds an engine from KYC_TIER_REQUIREMENTS,
+// a JSON object of the requirements each tier adds, e.g.
+//
+//	{"tier_1": ["identity"], "tier_2": ["phone"], "tier_3": ["selfie"]}
+//
+// DefaultKYCRequirements are used when it is not set or invalid.
+func NewKYCRequirementsEngineFromEnv() *KYCRequirementsEngine {
+	value := os.Getenv("KYC_TIER_REQUIREMENTS")
+	if value == "" {
+		return NewKYCRequirementsEngine(DefaultKYCRequirements)
+	}
+	added, err := parseKYCRequirements(value)
+	if err != nil {
+		log.Printf("Ignoring KYC_TIER_REQUIREMENTS: %v", err)
+		return NewKYCRequirementsEngine(DefaultKYCRequirements)
+	}
+	return NewKYCRequirementsEngine(added)
+}
+
+func parseKYCRequirements(value string) (map[models.KYCLevel][]KYCRequirement, error) {
+	var added map[models.KYCLevel][]KYCRequirement
+	if err := json.Unmarshal([]byte(value), &added); err != nil {
+		return nil, err
+	}
+	for tier, requirements := range added {
+		if tier.Rank() == 0 {
+			return nil, fmt.Errorf("unknown KYC level %q", tier)
+		}
+		for _, requirement := range requirements {
+			switch requirement {
+			case RequirementIdentity, RequirementPhone, RequirementAddress, RequirementSelfie, RequirementDocument:
+			default:
+				return nil, fmt.Errorf("unknown KYC requirement %q", requirement)
+			}
+		}
+	}
+	return added, nil
+}
+
+// Requirements returns everything a customer needs for the level.
+func (e *KYCRequirementsEngine) Requirements(level models.KYCLevel) []KYCRequirement {
+	return e.tiers[level]
+}
+
+// Evaluate checks the customer's evidence against the requirements of the
+// level. Requirements the customer met during earlier requests count too, so
+// a returning customer only has the remaining ones left to do.
+func (e *KYCRequirementsEngine) Evaluate(customer *models.Customer, level models.KYCLevel) *KYCEvaluation {
+	evaluation := &KYCEvaluation{
+		Level:     level,
+		Satisfied: []KYCRequirement{},
+		Remaining: []KYCRequirement{},
+	}
+	for _, requirement := range e.tiers[level] {
+		if customer != nil && meetsRequirement(customer, requirement) {
+			evaluation.Satisfied = append(evaluation.Satisfied, requirement)
+		} else {
+			evaluation.Remaining = append(evaluation.Remaining, requirement)
+		}
+	}
+	return evaluation
+}
+
+// AchievedLevel returns the highest tier whose requirements the customer meets.
+func (e *KYCRequirementsEngine) AchievedLevel(customer *models.Customer) models.KYCLevel {
+	achieved := models.KYCNone
+	for _, tier := range kycTiers {
+		if !e.Evaluate(customer, tier).IsComplete() {
+			break
+		}
+		achieved = tier
+	}
+	return achieved
+}
+
+func meetsRequirement(customer *models.Customer, requirement KYCRequirement) bool {
+	switch requirement {
+	case RequirementIdentity:
+		for _, identity := range customer.Identities {
+			if identity.Verified {
+				return true
+			}
+		}
+		return false
+	case RequirementPhone:
+		return customer.PhoneVerifiedAt != nil
+	case RequirementAddress:
+		return customer.AddressVerifiedAt != nil
+	case RequirementSelfie:
+		return customer.FacialRecognitionPassed
+	case RequirementDocument:
+		return customer.DocumentVerifiedAt != nil
+	}
+	return false
+}
+
+func containsRequirement(requirements []KYCRequirement, requirement KYCRequirement) bool {
+	for _, r := range requirements {
+		if r == requirement {
+			return true
+		}
+	}
+	return false
+}
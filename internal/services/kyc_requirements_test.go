@@ -0,0 +1,51 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKYCRequirementsEngineEvaluate(t *testing.T) {
+	engine := NewKYCRequirementsEngine(DefaultKYCRequirements)
+	assert.Equal(t, []KYCRequirement{RequirementIdentity, RequirementPhone, RequirementAddress}, engine.Requirements(models.KYCLevelTier2))
+
+	now := time.Now()
+	customer := &models.Customer{
+		PhoneVerifiedAt: &now,
+		Identities:      []models.Identity{{Type: models.IdentityTypeNIN, Verified: true}},
+	}
+
+	evaluation := engine.Evaluate(customer, models.KYCLevelTier2)
+	assert.False(t, evaluation.IsComplete())
+	assert.Equal(t, []KYCRequirement{RequirementIdentity, RequirementPhone}, evaluation.Satisfied)
+	assert.Equal(t, []KYCRequirement{RequirementAddress}, evaluation.Remaining)
+	assert.Equal(t, models.KYCLevelTier1, engine.AchievedLevel(customer))
+
+	address := "1 Marina, Lagos"
+	customer.Address = &address
+	assert.False(t, engine.Evaluate(customer, models.KYCLevelTier2).IsComplete())
+
+	customer.AddressVerifiedAt = &now
+	assert.True(t, engine.Evaluate(customer, models.KYCLevelTier2).IsComplete())
+	assert.Equal(t, models.KYCLevelTier2, engine.AchievedLevel(customer))
+	assert.Equal(t, []KYCRequirement{RequirementSelfie, RequirementDocument}, engine.Evaluate(customer, models.KYCLevelTier3).Remaining)
+
+	assert.Equal(t, models.KYCNone, engine.AchievedLevel(&models.Customer{}))
+}
+
+func TestParseKYCRequirements(t *testing.T) {
+	added, err := parseKYCRequirements(`{"tier_1": ["identity", "phone"], "tier_3": ["selfie"]}`)
+	assert.NoError(t, err)
+
+	engine := NewKYCRequirementsEngine(added)
+	assert.Equal(t, []KYCRequirement{RequirementIdentity, RequirementPhone}, engine.Requirements(models.KYCLevelTier2))
+	assert.Equal(t, []KYCRequirement{RequirementIdentity, RequirementPhone, RequirementSelfie}, engine.Requirements(models.KYCLevelTier3))
+
+	_, err = parseKYCRequirements(`{"tier_4": ["identity"]}`)
+	assert.Error(t, err)
+	_, err = parseKYCRequirements(`{"tier_1": ["fingerprint"]}`)
+	assert.Error(t, err)
+}
@@ -7,11 +7,34 @@ import (
 	structs "confam-api/internal/structs"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKYCRequestCompleted = errors.New("KYC request already completed")
+	ErrKYCRequestFailed    = errors.New("KYC request failed")
+	ErrKYCTokenExpired     = errors.New("This KYC link has expired")
+	ErrRequestNotFound     = errors.New("KYC request not found")
+	ErrRequestFinished     = errors.New("This KYC request has already been completed or failed")
+)
+
+const (
+	// RequestInitiatedEvent is the webhook event sent when a request is created.
+	RequestInitiatedEvent = "kyc.initiation.requested"
+	IdentityVerifiedEvent = "kyc.identity.verified"
+	ReviewRequiredEvent   = "kyc.review.required"
 )
 
+// kycTokenTTL is how long a KYC token, and the allow URL built from it, is valid.
+const kycTokenTTL = time.Hour
+
 type Identity struct {
 	Type   string `json:"type"`
 	Number string `json:"number"`
@@ -32,24 +55,80 @@ type KycRequestInput struct {
 
 type IKycService interface {
 	ValidateIdentityType(ctx context.Context, identityType string) bool
-	FindOrCreateCustomer(ctx context.Context, req structs.CustomerInput) (*models.Customer, error)
-	CreateKYCRequest(ctx context.Context, app models.App, req structs.KycRequestInput) (*models.Request, error)
+	FindOrCreateCustomer(ctx context.Context, environment models.Environment, req structs.CustomerInput) (*models.Customer, error)
+	CreateKYCRequest(ctx context.Context, app models.App, environment models.Environment, customer *models.Customer, req structs.KycRequestInput) (*models.Request, error)
 	FetchKycRequest(ctx context.Context, kycToken string) (*models.Request, *models.Customer, error)
-	IsReferenceUnique(ctx context.Context, reference string) (bool, error)
+	AllowPageCustomer(request *models.Request, customer *models.Customer) *AllowPageCustomer
+	RenewKYCToken(ctx context.Context, app models.App, environment models.Environment, reference string) (*models.Request, error)
+	GetRequest(ctx context.Context, app models.App, environment models.Environment, reference string) (*RequestDetails, error)
+	EvaluateRequirements(request *models.Request, customer *models.Customer) *KYCEvaluation
+	ConsentRequired(ctx context.Context, request *models.Request, customer *models.Customer) (bool, error)
+	IsReferenceUnique(ctx context.Context, app models.App, environment models.Environment, reference string) (bool, error)
+	VerifyIdentity(ctx context.Context, request *models.Request, customer *models.Customer, input structs.Identity) (*IdentityCheck, error)
+}
+
+// IdentityCheck is the outcome of verifying the identity of a request. Event
+// is the webhook event the app should be notified with, if any. Failed checks
+// have none: failing the request already sends RequestFailedEvent.
+type IdentityCheck struct {
+	Identity *models.Identity
+	Event    string
+	Reason   string
+}
+
+// AllowPageCustomer is all the allow page shows of a request's customer: the
+// name the integrator sent and where an OTP can go, masked. Anyone holding the
+// KYC token can load the page, so nothing else is returned before consent.
+type AllowPageCustomer struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone,omitempty"`
 }
 
+// RequestDetails is what an app can see of one of its requests. Customer only
+// holds the data the company's active permission covers, and BankAccounts is
+// only filled in when the request asked for bank accounts and the permission
+// covers them. Permission is nil when the company has no access.
+type RequestDetails struct {
+	Request      *models.Request
+	History      []models.RequestEvent
+	Customer     *models.Customer
+	Permission   *models.CustomerPermission
+	BankAccounts []models.BankAccount
+}
+
+// KYCService runs KYC requests. Sandbox and live data never mix: customers and
+// requests belong to the environment of the API key that created them.
 type KYCService struct {
-	customerRepo repositories.ICustomerRepository
-	requestRepo  repositories.IRequestRepository
+	customerRepo    repositories.ICustomerRepository
+	requestRepo     repositories.IRequestRepository
+	permissionRepo  repositories.ICustomerPermissionRepository
+	lifecycle       IRequestLifecycleService
+	webhookService  IWebhookService
+	sandboxProvider IdentityProvider
+	providers       *IdentityProviderRegistry
+	requirements    *KYCRequirementsEngine
 }
 
 func NewKYCService(
 	customerRepo repositories.ICustomerRepository,
 	requestRepo repositories.IRequestRepository,
+	permissionRepo repositories.ICustomerPermissionRepository,
+	lifecycle IRequestLifecycleService,
+	webhookService IWebhookService,
+	sandboxProvider IdentityProvider,
+	providers *IdentityProviderRegistry,
+	requirements *KYCRequirementsEngine,
 ) *KYCService {
 	return &KYCService{
-		customerRepo: customerRepo,
-		requestRepo:  requestRepo,
+		customerRepo:    customerRepo,
+		requestRepo:     requestRepo,
+		permissionRepo:  permissionRepo,
+		lifecycle:       lifecycle,
+		webhookService:  webhookService,
+		sandboxProvider: sandboxProvider,
+		providers:       providers,
+		requirements:    requirements,
 	}
 }
 
@@ -63,31 +142,43 @@ func (s *KYCService) ValidateIdentityType(ctx context.Context, identityType stri
 	return false
 }
 
-func (s *KYCService) FindOrCreateCustomer(ctx context.Context, req structs.CustomerInput) (*models.Customer, error) {
+// FindOrCreateCustomer finds the customer with the email in the given
+// environment, creating it along with its identity if there is none.
+func (s *KYCService) FindOrCreateCustomer(ctx context.Context, environment models.Environment, req structs.CustomerInput) (*models.Customer, error) {
 	hash := crypto.HashSHA256(req.Email)
-	customer, err := s.customerRepo.FindByEmailHash(hash)
-	if err != nil {
-		return nil, err
-	}
-	if customer != nil {
+	customer, err := s.customerRepo.FindByEmailHash(hash, environment)
+	if err == nil {
 		return customer, nil
 	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
 
 	token, err := crypto.GenerateHexToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate customer token: %w", err)
 	}
+	// Emails are stored encrypted; Customer.AfterFind decrypts them.
+	encryptedEmail, err := crypto.Encrypt(req.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt customer email: %w", err)
+	}
 	customer = &models.Customer{
 		Token:            token,
-		Email:            req.Email,
+		Email:            encryptedEmail,
 		EmailHash:        hash,
+		Environment:      environment,
 		Status:           "pending",
 		KYCLevelAchieved: "none",
 		IsBlacklisted:    false,
 	}
+	if address := strings.TrimSpace(req.Address); address != "" {
+		customer.Address = &address
+	}
 	if err := s.customerRepo.Create(customer); err != nil {
 		return nil, err
 	}
+	customer.Email = req.Email
 
 	encryptedValue, err := crypto.Encrypt(req.Identity.Number)
 	if err != nil {
@@ -96,12 +187,13 @@ func (s *KYCService) FindOrCreateCustomer(ctx context.Context, req structs.Custo
 
 	identity := &models.Identity{
 		CustomerID: customer.ID,
-		Type:       models.IdentityType(req.Identity.Type),
+		Type:       models.IdentityType(strings.ToUpper(req.Identity.Type)),
 		Value:      encryptedValue,
 	}
 	if err := s.customerRepo.CreateIdentity(identity); err != nil {
 		return nil, err
 	}
+	customer.Identities = append(customer.Identities, *identity)
 
 	return customer, nil
 }
@@ -109,6 +201,8 @@ func (s *KYCService) FindOrCreateCustomer(ctx context.Context, req structs.Custo
 func (s *KYCService) CreateKYCRequest(
 	ctx context.Context,
 	app models.App,
+	environment models.Environment,
+	customer *models.Customer,
 	req structs.KycRequestInput,
 ) (*models.Request, error) {
 	// Ensure unique reference
@@ -128,18 +222,70 @@ func (s *KYCService) CreateKYCRequest(
 		return nil, fmt.Errorf("failed to generate KYC token: %w", err)
 	}
 
+	scopes := models.PermissionScopes{}
+	for _, scope := range req.Scopes {
+		scopes = scopes.Merge(models.PermissionScopes{models.PermissionScope(scope)})
+	}
+	bankAccountsRequested := req.BankAccounts || scopes.Has(models.ScopeBankAccounts)
+	if len(scopes) > 0 && bankAccountsRequested {
+		scopes = scopes.Merge(models.PermissionScopes{models.ScopeBankAccounts})
+	}
+
 	request := &models.Request{
 		CompanyID:             app.CompanyID,
+		AppID:                 &app.ID,
+		Environment:           environment,
+		Status:                models.RequestStatusInitiated,
 		Reference:             req.Reference,
 		RedirectURL:           req.RedirectURL,
 		KYCLevel:              req.KYCLevel,
-		BankAccountsRequested: req.BankAccounts,
+		BankAccountsRequested: bankAccountsRequested,
+		Scopes:                scopes,
+		AccessType:            models.AccessPermanent,
 		KYCToken:              kycToken,
-		TokenExpiresAt:        time.Now().Add(1 * time.Hour),
+		TokenExpiresAt:        time.Now().Add(kycTokenTTL),
 		EncryptedData:         &encryptedData,
 	}
+	if models.AccessType(req.AccessType) == models.AccessTemporary {
+		request.AccessType = models.AccessTemporary
+		request.AccessDays = &req.AccessDays
+	}
+	if customer != nil {
+		request.CustomerID = &customer.ID
+	}
 
-	if err := s.requestRepo.Create(request); err != nil {
+	// The request's ID and creation time are set up front so the webhook
+	// announcing it can be stored along with it.
+	request.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	request.CreatedAt = time.Now()
+	var outbox []models.Webhook
+	if s.webhookService != nil {
+		outbox, err = s.webhookService.Build(ctx, WebhookEvent{
+			CompanyID:   app.CompanyID,
+			AppID:       &app.ID,
+			Environment: environment,
+			Type:        RequestInitiatedEvent,
+			Reference:   request.Reference,
+			Data: map[string]interface{}{
+				"app":            app.ID,
+				"business":       app.CompanyID,
+				"environment":    request.Environment,
+				"id":             request.ID,
+				"status":         request.Status,
+				"reference":      request.Reference,
+				"created_at":     request.CreatedAt,
+				"kyc_level":      request.KYCLevel,
+				"bank_accounts":  request.BankAccountsRequested,
+				"is_blacklisted": false,
+				"meta":           map[string]interface{}{},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.requestRepo.Create(request, outbox...); err != nil {
 		return nil, err
 	}
 	return request, nil
@@ -153,14 +299,26 @@ func (s *KYCService) FetchKycRequest(ctx context.Context, kycToken string) (*mod
 	}
 
 	// Business logic for status checks.
-	if request.Status == "completed" {
-		return nil, nil, fmt.Errorf("KYC request already completed")
+	if request.Status == models.RequestStatusCompleted {
+		return nil, nil, ErrKYCRequestCompleted
+	}
+	if request.Status == models.RequestStatusFailed {
+		return nil, nil, ErrKYCRequestFailed
+	}
+	if request.IsTokenExpired(time.Now()) {
+		return nil, nil, ErrKYCTokenExpired
 	}
-	if request.Status == "failed" {
-		return nil, nil, fmt.Errorf("KYC request failed")
+
+	// Requests made before customers were linked are matched by email
+	// within the request's environment.
+	if request.CustomerID != nil {
+		customer, err := s.customerRepo.FindByID(ctx, *request.CustomerID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return request, customer, nil
 	}
 
-	// Use the repository to find the associated customer.
 	var decrypted map[string]interface{}
 	if err := json.Unmarshal([]byte(*request.EncryptedData), &decrypted); err != nil {
 		return nil, nil, fmt.Errorf("failed to decrypt customer data")
@@ -171,7 +329,7 @@ func (s *KYCService) FetchKycRequest(ctx context.Context, kycToken string) (*mod
 		return nil, nil, fmt.Errorf("customer email not found in decrypted data")
 	}
 
-	customer, err := s.customerRepo.FindByEmailHash(crypto.HashSHA256(email))
+	customer, err := s.customerRepo.FindByEmailHash(crypto.HashSHA256(email), request.Environment)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -179,11 +337,388 @@ func (s *KYCService) FetchKycRequest(ctx context.Context, kycToken string) (*mod
 	return request, customer, nil
 }
 
-func (s *KYCService) IsReferenceUnique(ctx context.Context, reference string) (bool, error) {
-	// Use the repository method here.
-	count, err := s.requestRepo.CountByReference(reference)
+// AllowPageCustomer returns the request's customer as the allow page shows it.
+// The request's customer data is expected decrypted, as Request.AfterFind
+// leaves it.
+func (s *KYCService) AllowPageCustomer(request *models.Request, customer *models.Customer) *AllowPageCustomer {
+	allow := &AllowPageCustomer{}
+	if request.EncryptedData != nil {
+		var input structs.CustomerInput
+		if json.Unmarshal([]byte(*request.EncryptedData), &input) == nil {
+			allow.Name = input.Name
+		}
+	}
+	if customer == nil {
+		return allow
+	}
+	if customer.Email != "" {
+		allow.Email = maskOTPDestination(models.OTPChannelEmail, customer.Email)
+	}
+	if customer.Phone != nil && *customer.Phone != "" {
+		allow.Phone = maskOTPDestination(models.OTPChannelSMS, *customer.Phone)
+	}
+	return allow
+}
+
+// RenewKYCToken issues a fresh KYC token and allow URL for the unfinished
+// request with the reference, so an expired link can be replaced. The
+// previous token stops working.
+func (s *KYCService) RenewKYCToken(ctx context.Context, app models.App, environment models.Environment, reference string) (*models.Request, error) {
+	request, err := s.requestRepo.FindByReference(app.CompanyID, environment, reference)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRequestNotFound
+		}
+		return nil, err
+	}
+	if request.Status.IsFinal() {
+		return nil, ErrRequestFinished
+	}
+
+	kycToken, err := crypto.GenerateHexToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate KYC token: %w", err)
+	}
+	request.IssueToken(kycToken, time.Now().Add(kycTokenTTL))
+	if err := s.requestRepo.UpdateToken(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// GetRequest returns the request with the reference that the app's company
+// made in the environment, whatever its status, with its status history and
+// its customer.
+func (s *KYCService) GetRequest(ctx context.Context, app models.App, environment models.Environment, reference string) (*RequestDetails, error) {
+	request, err := s.requestRepo.FindByReference(app.CompanyID, environment, reference)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRequestNotFound
+		}
+		return nil, err
+	}
+
+	history, err := s.requestRepo.ListEvents(request.ID)
+	if err != nil {
+		return nil, err
+	}
+	details := &RequestDetails{Request: request, History: history}
+	if request.CustomerID == nil {
+		return details, nil
+	}
+
+	customer, err := s.customerRepo.FindByID(ctx, *request.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	permission, err := s.permissionRepo.FindActive(ctx, customer.ID, request.CompanyID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var scopes models.PermissionScopes
+	if permission != nil {
+		scopes = permission.Scopes
+		details.Permission = permission
+	}
+	details.Customer = restrictCustomer(customer, scopes)
+	if request.BankAccountsRequested && scopes.Has(models.ScopeBankAccounts) {
+		details.BankAccounts, err = s.customerRepo.ListBankAccounts(ctx, *request.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return details, nil
+}
+
+// restrictCustomer returns a copy of the customer without the data outside
+// the scopes. The customer's token, status and KYC level are always kept.
+func restrictCustomer(customer *models.Customer, scopes models.PermissionScopes) *models.Customer {
+	restricted := *customer
+	if !scopes.Has(models.ScopeIdentity) {
+		restricted.Identities = nil
+	}
+	if !scopes.Has(models.ScopePhone) {
+		restricted.Phone = nil
+		restricted.PhoneVerifiedAt = nil
+	}
+	if !scopes.Has(models.ScopeAddress) {
+		restricted.Address = nil
+	}
+	return &restricted
+}
+
+// EvaluateRequirements tells how far the request's customer is from the KYC
+// level the request asks for, counting evidence from earlier requests.
+func (s *KYCService) EvaluateRequirements(request *models.Request, customer *models.Customer) *KYCEvaluation {
+	return s.requirements.Evaluate(customer, RequestedKYCLevel(request))
+}
+
+// ConsentRequired reports whether the request's customer already has verified
+// KYC, obtained through any company, that they have not yet allowed the
+// request's company to use for every scope the request asks for. The customer
+// has to consent before it is reused.
+func (s *KYCService) ConsentRequired(ctx context.Context, request *models.Request, customer *models.Customer) (bool, error) {
+	if customer == nil || models.KYCLevel(customer.KYCLevelAchieved).Rank() == 0 {
+		return false, nil
+	}
+	permission, err := s.permissionRepo.FindActive(ctx, customer.ID, request.CompanyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !permission.Scopes.Covers(request.RequestedScopes()), nil
+}
+
+// IsReferenceUnique reports whether the app's company has not used the
+// reference in the environment yet.
+func (s *KYCService) IsReferenceUnique(ctx context.Context, app models.App, environment models.Environment, reference string) (bool, error) {
+	count, err := s.requestRepo.CountByReference(app.CompanyID, environment, reference)
 	if err != nil {
 		return false, err
 	}
 	return count == 0, nil
 }
+
+// identityProvider returns the provider that verifies identityType for the
+// environment. Sandbox requests always use the sandbox provider.
+func (s *KYCService) identityProvider(environment models.Environment, identityType models.IdentityType) (IdentityProvider, bool) {
+	if environment == models.EnvironmentSandbox {
+		return s.sandboxProvider, s.sandboxProvider != nil
+	}
+	if s.providers == nil {
+		return nil, false
+	}
+	return s.providers.Provider(identityType)
+}
+
+// VerifyIdentity looks up the identity of a request with the provider for its
+// environment and identity type, records the result on the Identity row and
+// moves the request on: a rejected identity or a provider timeout fails the
+// request, and an identity that needs manual review puts it in kyc_processing,
+// as does any other provider error. Requests whose identity type has no
+// provider are left pending.
+//
+// A customer has one identity per type. A number that differs from a verified
+// or pending identity of the same type fails the request without touching the
+// stored identity; a rejected identity is replaced by the new number.
+//
+// A customer who already has a verified identity is shared by every company
+// that reuses their KYC, so until they consent to the request's company no
+// identity is attached, replaced or looked up for them, and their phone
+// number and address are left as they are.
+func (s *KYCService) VerifyIdentity(ctx context.Context, request *models.Request, customer *models.Customer, input structs.Identity) (*IdentityCheck, error) {
+	identityType := models.IdentityType(strings.ToUpper(input.Type))
+	identityProvider, ok := s.identityProvider(request.Environment, identityType)
+	if !ok {
+		return nil, nil
+	}
+
+	var identity *models.Identity
+	verifiedCustomer := false
+	for i := range customer.Identities {
+		if customer.Identities[i].Type == identityType {
+			identity = &customer.Identities[i]
+		}
+		verifiedCustomer = verifiedCustomer || customer.Identities[i].Verified
+	}
+	replaced := false
+	if identity != nil {
+		number, err := crypto.Decrypt(identity.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt identity number: %w", err)
+		}
+		if number != input.Number && identity.Status != models.IdentityStatusRejected {
+			return s.failIdentityCheck(ctx, request, identity, "identity_mismatch")
+		}
+		if identity.Verified {
+			return &IdentityCheck{Identity: identity}, nil
+		}
+		replaced = number != input.Number
+	}
+	if verifiedCustomer {
+		consented, err := s.hasConsented(ctx, request, customer)
+		if err != nil {
+			return nil, err
+		}
+		if !consented {
+			return nil, nil
+		}
+	}
+
+	if identity == nil {
+		encryptedValue, err := crypto.Encrypt(input.Number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt identity number: %w", err)
+		}
+		identity = &models.Identity{
+			CustomerID: customer.ID,
+			Type:       identityType,
+			Value:      encryptedValue,
+		}
+		if err := s.customerRepo.CreateIdentity(identity); err != nil {
+			return nil, err
+		}
+	} else if replaced {
+		encryptedValue, err := crypto.Encrypt(input.Number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt identity number: %w", err)
+		}
+		identity.Value = encryptedValue
+		identity.Status = models.IdentityStatusPending
+		identity.Verified = false
+		identity.VerifiedAt = nil
+		identity.VerificationProvider = nil
+		identity.ProviderReference = nil
+	}
+
+	check := &IdentityCheck{Identity: identity}
+	status := request.Status
+	record, err := identityProvider.Lookup(ctx, identity.Type, input.Number)
+	switch {
+	case err == nil:
+		now := time.Now()
+		provider := identityProvider.Name()
+		identity.Status = models.IdentityStatusVerified
+		identity.Verified = true
+		identity.VerifiedAt = &now
+		identity.VerificationProvider = &provider
+		identity.ProviderReference = &record.Reference
+		check.Event = IdentityVerifiedEvent
+		if err := s.updatePhone(ctx, customer, record.Phone); err != nil {
+			return nil, err
+		}
+		if err := s.updateAddress(ctx, customer, record.Address); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, ErrIdentityNotFound):
+		identity.Status = models.IdentityStatusRejected
+		status = models.RequestStatusFailed
+		check.Reason = "identity_rejected"
+	case errors.Is(err, ErrIdentityProviderTimeout):
+		status = models.RequestStatusFailed
+		check.Reason = "provider_timeout"
+	case errors.Is(err, ErrIdentityReviewRequired):
+		identity.Status = models.IdentityStatusPending
+		status = models.RequestStatusKYCProcessing
+		check.Event = ReviewRequiredEvent
+		check.Reason = "manual_review"
+	default:
+		// The request is already stored, so an unexpected provider error
+		// leaves the identity to be reviewed instead of failing the call.
+		log.Printf("Error looking up %s identity for request %s: %v", identity.Type, request.ID, err)
+		identity.Status = models.IdentityStatusPending
+		status = models.RequestStatusKYCProcessing
+		check.Event = ReviewRequiredEvent
+		check.Reason = "provider_error"
+	}
+
+	outbox, err := s.identityWebhooks(ctx, request, status, check)
+	if err != nil {
+		return nil, err
+	}
+	if status == request.Status {
+		if err := s.customerRepo.UpdateIdentity(identity, outbox...); err != nil {
+			return nil, err
+		}
+		return check, nil
+	}
+	if err := s.customerRepo.UpdateIdentity(identity); err != nil {
+		return nil, err
+	}
+	if _, err := s.lifecycle.Transition(ctx, request, status, check.Reason, outbox...); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// identityWebhooks builds the webhooks telling the request's app about the
+// identity check, stored together with the identity or the status change.
+func (s *KYCService) identityWebhooks(ctx context.Context, request *models.Request, status models.RequestStatus, check *IdentityCheck) ([]models.Webhook, error) {
+	if check.Event == "" || s.webhookService == nil {
+		return nil, nil
+	}
+	return s.webhookService.Build(ctx, WebhookEvent{
+		CompanyID:   request.CompanyID,
+		AppID:       request.AppID,
+		Environment: request.Environment,
+		Type:        check.Event,
+		Reference:   request.Reference,
+		Data: map[string]interface{}{
+			"app":         request.AppID,
+			"business":    request.CompanyID,
+			"environment": request.Environment,
+			"id":          request.ID,
+			"reference":   request.Reference,
+			"status":      status,
+			"reason":      check.Reason,
+			"identity": map[string]interface{}{
+				"type":   check.Identity.Type,
+				"status": check.Identity.Status,
+			},
+		},
+	})
+}
+
+// hasConsented reports whether the customer has allowed the request's company
+// to use their KYC and not revoked it since.
+func (s *KYCService) hasConsented(ctx context.Context, request *models.Request, customer *models.Customer) (bool, error) {
+	_, err := s.permissionRepo.FindActive(ctx, customer.ID, request.CompanyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// failIdentityCheck fails the request without looking the identity up.
+func (s *KYCService) failIdentityCheck(ctx context.Context, request *models.Request, identity *models.Identity, reason string) (*IdentityCheck, error) {
+	if _, err := s.lifecycle.Transition(ctx, request, models.RequestStatusFailed, reason); err != nil {
+		return nil, err
+	}
+	return &IdentityCheck{Identity: identity, Reason: reason}, nil
+}
+
+// updateAddress stores the address the identity provider returned for the
+// customer as their verified address.
+func (s *KYCService) updateAddress(ctx context.Context, customer *models.Customer, address string) error {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil
+	}
+	now := time.Now()
+	if err := s.customerRepo.UpdateFields(ctx, customer.ID, map[string]interface{}{
+		"address":             address,
+		"address_verified_at": now,
+	}); err != nil {
+		return err
+	}
+	customer.Address = &address
+	customer.AddressVerifiedAt = &now
+	return nil
+}
+
+// updatePhone stores the phone number the identity provider returned for the
+// customer. A new number has to be verified again.
+func (s *KYCService) updatePhone(ctx context.Context, customer *models.Customer, phone string) error {
+	if phone == "" || (customer.Phone != nil && *customer.Phone == phone) {
+		return nil
+	}
+	encrypted, err := crypto.Encrypt(phone)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt phone number: %w", err)
+	}
+	if err := s.customerRepo.UpdateFields(ctx, customer.ID, map[string]interface{}{
+		"phone":             encrypted,
+		"phone_verified_at": nil,
+	}); err != nil {
+		return err
+	}
+	customer.Phone = &phone
+	customer.PhoneVerifiedAt = nil
+	return nil
+}
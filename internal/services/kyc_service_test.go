@@ -0,0 +1,282 @@
+package services
+
+import (
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	"confam-api/internal/repositories/mocks"
+	"confam-api/internal/structs"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakeIdentityRepository struct {
+	fakeCustomerRepository
+	created []models.Identity
+	updated []models.Identity
+	outbox  []models.Webhook
+}
+
+func (r *fakeIdentityRepository) FindByEmailHash(hash string, environment models.Environment) (*models.Customer, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeIdentityRepository) Create(customer *models.Customer) error {
+	customer.ID = "customer-new"
+	return nil
+}
+
+func (r *fakeIdentityRepository) CreateIdentity(identity *models.Identity) error {
+	identity.ID = "identity-new"
+	r.created = append(r.created, *identity)
+	return nil
+}
+
+func (r *fakeIdentityRepository) UpdateIdentity(identity *models.Identity, outbox ...models.Webhook) error {
+	r.updated = append(r.updated, *identity)
+	r.outbox = append(r.outbox, outbox...)
+	return nil
+}
+
+type failingIdentityProvider struct {
+	err error
+}
+
+func (p *failingIdentityProvider) Name() string {
+	return "failing"
+}
+
+func (p *failingIdentityProvider) Lookup(ctx context.Context, identityType models.IdentityType, number string) (*IdentityRecord, error) {
+	return nil, p.err
+}
+
+func newTestIdentityService(t *testing.T) (*KYCService, *fakeIdentityRepository) {
+	service, customers, _ := newTestIdentityServiceWithOutbox(t)
+	return service, customers
+}
+
+// newTestIdentityServiceWithOutbox returns a service whose app-1 of company-1
+// has a webhook URL, and the request repository collecting the webhooks
+// stored with status changes.
+func newTestIdentityServiceWithOutbox(t *testing.T) (*KYCService, *fakeIdentityRepository, *mocks.MockRequestRepository) {
+	os.Setenv("ENCRYPTION_KEY", "7c633361cb709e1cf6ef0d68c914b5a5b1b540034331ab64702d2fd980dc7585")
+	t.Cleanup(func() { os.Unsetenv("ENCRYPTION_KEY") })
+
+	webhookURL := "https://example.com/hooks"
+	webhookService := newTestWebhookService(&models.App{ID: "app-1", CompanyID: "company-1", WebhookURL: &webhookURL})
+	customers := &fakeIdentityRepository{}
+	requestRepo := &mocks.MockRequestRepository{}
+	lifecycle := NewRequestLifecycleService(requestRepo, webhookService)
+	service := NewKYCService(customers, requestRepo, nil, lifecycle, webhookService, NewSandboxIdentityProvider(), nil, nil)
+	return service, customers, requestRepo
+}
+
+func newTestIdentity(t *testing.T, number string, status models.IdentityStatus) models.Identity {
+	value, err := crypto.Encrypt(number)
+	assert.NoError(t, err)
+	return models.Identity{
+		ID:       "identity-1",
+		Type:     models.IdentityTypeBVN,
+		Value:    value,
+		Status:   status,
+		Verified: status == models.IdentityStatusVerified,
+	}
+}
+
+func TestKYCServiceVerifyIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		number     string
+		wantStatus models.RequestStatus
+		wantEvent  string
+		wantReason string
+		wantResult models.IdentityStatus
+	}{
+		{"verified", "2200000000", models.RequestStatusInitiated, IdentityVerifiedEvent, "", models.IdentityStatusVerified},
+		{"not found", "2200001111", models.RequestStatusFailed, "", "identity_rejected", models.IdentityStatusRejected},
+		{"review", "2200003333", models.RequestStatusKYCProcessing, ReviewRequiredEvent, "manual_review", models.IdentityStatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, customers := newTestIdentityService(t)
+			request := &models.Request{ID: "request-1", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+			customer := &models.Customer{ID: "customer-1"}
+
+			check, err := service.VerifyIdentity(context.Background(), request, customer, structs.Identity{Type: "bvn", Number: tt.number})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, request.Status)
+			assert.Equal(t, tt.wantEvent, check.Event)
+			assert.Equal(t, tt.wantReason, check.Reason)
+			assert.Len(t, customers.created, 1)
+			assert.Len(t, customers.updated, 1)
+			assert.Equal(t, tt.wantResult, customers.updated[0].Status)
+			assert.Equal(t, tt.wantResult == models.IdentityStatusVerified, customer.AddressVerifiedAt != nil)
+		})
+	}
+}
+
+func TestKYCServiceVerifyIdentityFindsTheIdentityOfANewCustomer(t *testing.T) {
+	service, customers := newTestIdentityService(t)
+	identity := structs.Identity{Type: "bvn", Number: "2200000000"}
+	customer, err := service.FindOrCreateCustomer(context.Background(), models.EnvironmentSandbox, structs.CustomerInput{Email: "ada@example.com", Identity: identity})
+	assert.NoError(t, err)
+	request := &models.Request{ID: "request-1", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+
+	check, err := service.VerifyIdentity(context.Background(), request, customer, identity)
+	assert.NoError(t, err)
+	assert.Equal(t, IdentityVerifiedEvent, check.Event)
+	assert.Len(t, customers.created, 1)
+	assert.Equal(t, models.IdentityTypeBVN, customers.created[0].Type)
+	if assert.Len(t, customers.updated, 1) {
+		assert.Equal(t, "identity-new", customers.updated[0].ID)
+		assert.Equal(t, models.IdentityStatusVerified, customers.updated[0].Status)
+	}
+}
+
+func TestKYCServiceVerifyIdentitySkipsTheLookupForAVerifiedNumber(t *testing.T) {
+	service, customers := newTestIdentityService(t)
+	request := &models.Request{ID: "request-1", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+	customer := &models.Customer{ID: "customer-1", Identities: []models.Identity{newTestIdentity(t, "2200000000", models.IdentityStatusVerified)}}
+
+	check, err := service.VerifyIdentity(context.Background(), request, customer, structs.Identity{Type: "BVN", Number: "2200000000"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusInitiated, request.Status)
+	assert.Empty(t, check.Event)
+	assert.Empty(t, customers.created)
+	assert.Empty(t, customers.updated)
+}
+
+func TestKYCServiceVerifyIdentityFailsAMismatchedNumber(t *testing.T) {
+	for _, status := range []models.IdentityStatus{models.IdentityStatusVerified, models.IdentityStatusPending} {
+		t.Run(string(status), func(t *testing.T) {
+			service, customers := newTestIdentityService(t)
+			request := &models.Request{ID: "request-1", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+			stored := newTestIdentity(t, "2200000000", status)
+			customer := &models.Customer{ID: "customer-1", Identities: []models.Identity{stored}}
+
+			check, err := service.VerifyIdentity(context.Background(), request, customer, structs.Identity{Type: "BVN", Number: "2299990000"})
+			assert.NoError(t, err)
+			assert.Equal(t, models.RequestStatusFailed, request.Status)
+			assert.Equal(t, "identity_mismatch", check.Reason)
+			assert.Empty(t, customers.created)
+			assert.Empty(t, customers.updated)
+			assert.Equal(t, stored, customer.Identities[0])
+		})
+	}
+}
+
+func TestKYCServiceVerifyIdentityReplacesARejectedNumber(t *testing.T) {
+	service, customers := newTestIdentityService(t)
+	request := &models.Request{ID: "request-1", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+	customer := &models.Customer{ID: "customer-1", Identities: []models.Identity{newTestIdentity(t, "2200001111", models.IdentityStatusRejected)}}
+
+	check, err := service.VerifyIdentity(context.Background(), request, customer, structs.Identity{Type: "BVN", Number: "2299990000"})
+	assert.NoError(t, err)
+	assert.Equal(t, IdentityVerifiedEvent, check.Event)
+	assert.Equal(t, models.RequestStatusInitiated, request.Status)
+	assert.Len(t, customers.updated, 1)
+	assert.Equal(t, models.IdentityStatusVerified, customers.updated[0].Status)
+	number, err := crypto.Decrypt(customers.updated[0].Value)
+	assert.NoError(t, err)
+	assert.Equal(t, "2299990000", number)
+}
+
+func TestKYCServiceVerifyIdentityLeavesAVerifiedCustomerUntilConsent(t *testing.T) {
+	service, customers := newTestIdentityService(t)
+	permissions := &fakePermissionRepository{}
+	service.permissionRepo = permissions
+	phone := "+2348012345678"
+	verifiedAt := time.Now().Add(-time.Hour)
+	bvn := newTestIdentity(t, "2200000000", models.IdentityStatusVerified)
+	newCustomer := func() *models.Customer {
+		return &models.Customer{
+			ID:              "customer-1",
+			Phone:           &phone,
+			PhoneVerifiedAt: &verifiedAt,
+			Identities:      []models.Identity{bvn},
+		}
+	}
+	request := &models.Request{ID: "request-1", CompanyID: "company-2", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+	nin := structs.Identity{Type: "NIN", Number: "3300000000"}
+
+	customer := newCustomer()
+	check, err := service.VerifyIdentity(context.Background(), request, customer, nin)
+	assert.NoError(t, err)
+	assert.Nil(t, check)
+	assert.Equal(t, models.RequestStatusInitiated, request.Status)
+	assert.Empty(t, customers.created)
+	assert.Empty(t, customers.updated)
+	assert.Nil(t, customers.updates)
+	assert.Equal(t, newCustomer(), customer)
+
+	// Once the customer has consented to the company, the identity is theirs
+	// to add.
+	permissions.permissions = append(permissions.permissions, models.CustomerPermission{CustomerID: "customer-1", CompanyID: "company-2"})
+	check, err = service.VerifyIdentity(context.Background(), request, customer, nin)
+	assert.NoError(t, err)
+	assert.Equal(t, IdentityVerifiedEvent, check.Event)
+	if assert.Len(t, customers.created, 1) {
+		assert.Equal(t, models.IdentityTypeNIN, customers.created[0].Type)
+	}
+}
+
+func TestKYCServiceVerifyIdentitySendsProviderErrorsToReview(t *testing.T) {
+	service, customers := newTestIdentityService(t)
+	service.sandboxProvider = &failingIdentityProvider{err: errors.New("identity provider responded with 500 Internal Server Error")}
+	request := &models.Request{ID: "request-1", Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+	customer := &models.Customer{ID: "customer-1"}
+
+	check, err := service.VerifyIdentity(context.Background(), request, customer, structs.Identity{Type: "BVN", Number: "2200000000"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusKYCProcessing, request.Status)
+	assert.Equal(t, ReviewRequiredEvent, check.Event)
+	assert.Equal(t, "provider_error", check.Reason)
+	assert.Equal(t, models.IdentityStatusPending, customers.updated[0].Status)
+}
+
+func TestKYCServiceAllowPageCustomerIsMasked(t *testing.T) {
+	service, _ := newTestIdentityService(t)
+	// Requests come back from the repository with their data decrypted.
+	data := `{"name":"Ada Lovelace","email":"ada@example.com","address":"1 Marina, Lagos"}`
+	phone := "+2348012345678"
+	request := &models.Request{EncryptedData: &data}
+	customer := &models.Customer{Email: "ada@example.com", Phone: &phone}
+
+	allow := service.AllowPageCustomer(request, customer)
+	assert.Equal(t, &AllowPageCustomer{Name: "Ada Lovelace", Email: "a***@example.com", Phone: "**********5678"}, allow)
+}
+
+func TestKYCServiceVerifyIdentityStoresItsWebhooksWithTheChange(t *testing.T) {
+	appID := "app-1"
+	newRequest := func() *models.Request {
+		return &models.Request{ID: "request-1", CompanyID: "company-1", AppID: &appID, Environment: models.EnvironmentSandbox, Status: models.RequestStatusInitiated}
+	}
+
+	// A verified identity leaves the request as it is, so the webhook is
+	// stored with the identity.
+	service, customers, requestRepo := newTestIdentityServiceWithOutbox(t)
+	_, err := service.VerifyIdentity(context.Background(), newRequest(), &models.Customer{ID: "customer-1"}, structs.Identity{Type: "BVN", Number: "2200000000"})
+	assert.NoError(t, err)
+	if assert.Len(t, customers.outbox, 1) {
+		assert.Equal(t, IdentityVerifiedEvent, customers.outbox[0].EventType)
+	}
+	assert.Empty(t, requestRepo.Outbox)
+
+	// An identity sent to review moves the request, so the webhook is stored
+	// with the status change.
+	service, customers, requestRepo = newTestIdentityServiceWithOutbox(t)
+	_, err = service.VerifyIdentity(context.Background(), newRequest(), &models.Customer{ID: "customer-1"}, structs.Identity{Type: "BVN", Number: "2200003333"})
+	assert.NoError(t, err)
+	assert.Empty(t, customers.outbox)
+	var events []string
+	for _, webhook := range requestRepo.Outbox {
+		events = append(events, webhook.EventType)
+	}
+	assert.Equal(t, []string{ReviewRequiredEvent, RequestStatusChangedEvent}, events)
+	assert.Contains(t, requestRepo.Outbox[0].Payload, `"status":"kyc_processing"`)
+}
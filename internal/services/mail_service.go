@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"log"
+)
+
+type IMailService interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogMailService writes outgoing emails to the application log.
+// It is used until a real mail provider is configured.
+type LogMailService struct{}
+
+func NewLogMailService() *LogMailService {
+	return &LogMailService{}
+}
+
+func (s *LogMailService) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("--- OUTGOING EMAIL ---\nTo: %s\nSubject: %s\n\n%s\n", to, subject, body)
+	return nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// IOTPSender delivers one-time passwords to customers.
+type IOTPSender interface {
+	Send(ctx context.Context, channel models.OTPChannel, to, code string) error
+}
+
+// LogOTPSender writes OTPs to the application log, or appends them to a file
+// when it has a path. It is meant for local testing until an SMS or email
+// provider is configured.
+type LogOTPSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLogOTPSender returns a sender that logs OTPs, appending them to the file
+// at path if it is not empty.
+func NewLogOTPSender(path string) *LogOTPSender {
+	return &LogOTPSender{path: path}
+}
+
+func (s *LogOTPSender) Send(ctx context.Context, channel models.OTPChannel, to, code string) error {
+	line := fmt.Sprintf("%s %s to=%s code=%s\n", time.Now().Format(time.RFC3339), channel, to, code)
+	if s.path == "" {
+		log.Printf("--- OUTGOING OTP ---\n%s", line)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open otp log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write otp log: %w", err)
+	}
+	return nil
+}
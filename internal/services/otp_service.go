@@ -0,0 +1,226 @@
+package services
+
+import (
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	otpTTL             = 10 * time.Minute
+	maxOTPSends        = 5
+	otpSendWindow      = 15 * time.Minute
+	maxOTPAttempts     = 5
+	otpAttemptsLockout = 30 * time.Minute
+)
+
+var (
+	ErrOTPNotExpected     = errors.New("This KYC request is not waiting for an OTP")
+	ErrOTPChannelMissing  = errors.New("There is no phone number or email to send the OTP to")
+	ErrTooManyOTPRequests = errors.New("Too many OTP requests. Please try again later")
+	ErrTooManyOTPAttempts = errors.New("Too many invalid OTPs. Please try again later")
+	ErrInvalidOTP         = errors.New("The OTP is invalid")
+	ErrOTPExpired         = errors.New("The OTP has expired. Please request a new one")
+	errOTPInternal        = errors.New("An internal server error occurred. Please try again later")
+)
+
+// OTPDelivery tells the customer where their OTP was sent.
+type OTPDelivery struct {
+	Channel   models.OTPChannel `json:"channel"`
+	SentTo    string            `json:"sent_to"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type IOTPService interface {
+	Send(ctx context.Context, kycToken string, channel models.OTPChannel) (*OTPDelivery, error)
+	Verify(ctx context.Context, kycToken, code string) (*models.Request, error)
+}
+
+// OTPService runs the OTP step of a KYC request. Sending an OTP moves the
+// request to otp_pending; verifying it moves the request to kyc_processing.
+// Both sends and failed verifications are limited per request.
+type OTPService struct {
+	kycService   IKycService
+	customerRepo repositories.ICustomerRepository
+	otpRepo      repositories.IOTPRepository
+	attemptRepo  repositories.IAttemptRepository
+	lifecycle    IRequestLifecycleService
+	sender       IOTPSender
+}
+
+func NewOTPService(
+	kycService IKycService,
+	customerRepo repositories.ICustomerRepository,
+	otpRepo repositories.IOTPRepository,
+	attemptRepo repositories.IAttemptRepository,
+	lifecycle IRequestLifecycleService,
+	sender IOTPSender,
+) *OTPService {
+	return &OTPService{
+		kycService:   kycService,
+		customerRepo: customerRepo,
+		otpRepo:      otpRepo,
+		attemptRepo:  attemptRepo,
+		lifecycle:    lifecycle,
+		sender:       sender,
+	}
+}
+
+func otpSendKey(requestID string) string {
+	return "otp:send:" + requestID
+}
+
+func otpVerifyKey(requestID string) string {
+	return "otp:verify:" + requestID
+}
+
+// Send sends a new OTP for the request to the customer's phone number, as
+// returned by the identity lookup, or to their email. Without a channel the
+// phone number is preferred. An OTP that lets the customer consent to reusing
+// their KYC is only sent to a phone number verified before the request, or
+// to the email the customer was found by.
+func (s *OTPService) Send(ctx context.Context, kycToken string, channel models.OTPChannel) (*OTPDelivery, error) {
+	request, customer, err := s.kycService.FetchKycRequest(ctx, kycToken)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.RequestStatusInitiated && request.Status != models.RequestStatusOTPPending {
+		return nil, ErrOTPNotExpected
+	}
+
+	contacts := customer
+	consentRequired, err := s.kycService.ConsentRequired(ctx, request, customer)
+	if err != nil {
+		return nil, errOTPInternal
+	}
+	if consentRequired && (customer.PhoneVerifiedAt == nil || !customer.PhoneVerifiedAt.Before(request.CreatedAt)) {
+		contacts = &models.Customer{Email: customer.Email}
+	}
+	to, channel, err := otpDestination(contacts, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	sends, err := s.attemptRepo.Increment(ctx, otpSendKey(request.ID), otpSendWindow)
+	if err != nil {
+		return nil, errOTPInternal
+	}
+	if sends > maxOTPSends {
+		return nil, ErrTooManyOTPRequests
+	}
+
+	code, err := generateOTPCode()
+	if err != nil {
+		return nil, errOTPInternal
+	}
+	otp := &models.OTP{
+		CodeHash:  crypto.HashSHA256(code),
+		Channel:   channel,
+		SentTo:    maskOTPDestination(channel, to),
+		ExpiresAt: time.Now().Add(otpTTL),
+	}
+	if err := s.otpRepo.Save(ctx, request.ID, otp, otpTTL); err != nil {
+		return nil, errOTPInternal
+	}
+	if err := s.sender.Send(ctx, channel, to, code); err != nil {
+		return nil, fmt.Errorf("failed to send otp: %w", err)
+	}
+
+	if request.Status == models.RequestStatusInitiated {
+		if _, err := s.lifecycle.Transition(ctx, request, models.RequestStatusOTPPending, ""); err != nil {
+			return nil, err
+		}
+	}
+	return &OTPDelivery{Channel: otp.Channel, SentTo: otp.SentTo, ExpiresAt: otp.ExpiresAt}, nil
+}
+
+// Verify checks the OTP last sent for the request. A correct OTP sent by SMS
+// also verifies the customer's phone number.
+func (s *OTPService) Verify(ctx context.Context, kycToken, code string) (*models.Request, error) {
+	request, customer, err := s.kycService.FetchKycRequest(ctx, kycToken)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.RequestStatusOTPPending {
+		return nil, ErrOTPNotExpected
+	}
+
+	attempts, err := s.attemptRepo.Increment(ctx, otpVerifyKey(request.ID), otpAttemptsLockout)
+	if err != nil {
+		return nil, errOTPInternal
+	}
+	if attempts > maxOTPAttempts {
+		return nil, ErrTooManyOTPAttempts
+	}
+
+	otp, err := s.otpRepo.Find(ctx, request.ID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrOTPNotFound) {
+			return nil, ErrOTPExpired
+		}
+		return nil, errOTPInternal
+	}
+	if subtle.ConstantTimeCompare([]byte(otp.CodeHash), []byte(crypto.HashSHA256(code))) != 1 {
+		return nil, ErrInvalidOTP
+	}
+
+	s.otpRepo.Delete(ctx, request.ID)
+	s.attemptRepo.Reset(ctx, otpVerifyKey(request.ID))
+	s.attemptRepo.Reset(ctx, otpSendKey(request.ID))
+
+	if otp.Channel == models.OTPChannelSMS {
+		now := time.Now()
+		if err := s.customerRepo.UpdateFields(ctx, customer.ID, map[string]interface{}{"phone_verified_at": now}); err != nil {
+			return nil, err
+		}
+		customer.PhoneVerifiedAt = &now
+	}
+
+	if _, err := s.lifecycle.Transition(ctx, request, models.RequestStatusKYCProcessing, ""); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func otpDestination(customer *models.Customer, channel models.OTPChannel) (string, models.OTPChannel, error) {
+	hasPhone := customer.Phone != nil && *customer.Phone != ""
+	switch {
+	case channel == models.OTPChannelSMS && hasPhone, channel == "" && hasPhone:
+		return *customer.Phone, models.OTPChannelSMS, nil
+	case channel == models.OTPChannelEmail && customer.Email != "", channel == "" && customer.Email != "":
+		return customer.Email, models.OTPChannelEmail, nil
+	}
+	return "", "", ErrOTPChannelMissing
+}
+
+func generateOTPCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// maskOTPDestination hides most of a phone number or email address, keeping
+// enough for the customer to recognise it.
+func maskOTPDestination(channel models.OTPChannel, to string) string {
+	if channel == models.OTPChannelEmail {
+		name, domain, found := strings.Cut(to, "@")
+		if !found || len(name) == 0 {
+			return to
+		}
+		return name[:1] + strings.Repeat("*", max(len(name)-1, 3)) + "@" + domain
+	}
+	if len(to) <= 4 {
+		return to
+	}
+	return strings.Repeat("*", len(to)-4) + to[len(to)-4:]
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/internal/repositories/mocks"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeKycService struct {
+	IKycService
+	request  *models.Request
+	customer *models.Customer
+}
+
+func (s *fakeKycService) FetchKycRequest(ctx context.Context, kycToken string) (*models.Request, *models.Customer, error) {
+	return s.request, s.customer, nil
+}
+
+type fakeCustomerRepository struct {
+	repositories.ICustomerRepository
+	updates map[string]interface{}
+}
+
+func (r *fakeCustomerRepository) UpdateFields(ctx context.Context, id string, fields map[string]interface{}) error {
+	r.updates = fields
+	return nil
+}
+
+type fakeOTPRepository struct {
+	otps map[string]*models.OTP
+}
+
+func (r *fakeOTPRepository) Save(ctx context.Context, requestID string, otp *models.OTP, ttl time.Duration) error {
+	r.otps[requestID] = otp
+	return nil
+}
+
+func (r *fakeOTPRepository) Find(ctx context.Context, requestID string) (*models.OTP, error) {
+	otp, ok := r.otps[requestID]
+	if !ok {
+		return nil, repositories.ErrOTPNotFound
+	}
+	return otp, nil
+}
+
+func (r *fakeOTPRepository) Delete(ctx context.Context, requestID string) error {
+	delete(r.otps, requestID)
+	return nil
+}
+
+type fakeAttemptRepository struct {
+	counts map[string]int64
+}
+
+func (r *fakeAttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	r.counts[key]++
+	return r.counts[key], nil
+}
+
+func (r *fakeAttemptRepository) Reset(ctx context.Context, key string) error {
+	delete(r.counts, key)
+	return nil
+}
+
+type fakeOTPSender struct {
+	channel  models.OTPChannel
+	to, code string
+}
+
+func (s *fakeOTPSender) Send(ctx context.Context, channel models.OTPChannel, to, code string) error {
+	s.channel, s.to, s.code = channel, to, code
+	return nil
+}
+
+func newTestOTPService(request *models.Request, customer *models.Customer) (*OTPService, *fakeCustomerRepository, *fakeOTPSender) {
+	customers := &fakeCustomerRepository{}
+	sender := &fakeOTPSender{}
+	requestRepo := &mocks.MockRequestRepository{}
+	kycService := NewKYCService(customers, requestRepo, &fakePermissionRepository{}, nil, nil, nil, nil, nil)
+	service := NewOTPService(
+		&fakeKycService{IKycService: kycService, request: request, customer: customer},
+		customers,
+		&fakeOTPRepository{otps: map[string]*models.OTP{}},
+		&fakeAttemptRepository{counts: map[string]int64{}},
+		NewRequestLifecycleService(requestRepo, newTestWebhookService()),
+		sender,
+	)
+	return service, customers, sender
+}
+
+func TestOTPServiceSendAndVerify(t *testing.T) {
+	phone := "+2348012345678"
+	request := &models.Request{ID: "request-1", Status: models.RequestStatusInitiated}
+	customer := &models.Customer{ID: "customer-1", Phone: &phone, Email: "ada@example.com"}
+	service, customers, sender := newTestOTPService(request, customer)
+	ctx := context.Background()
+
+	delivery, err := service.Send(ctx, "token", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OTPChannelSMS, delivery.Channel)
+	assert.Equal(t, "**********5678", delivery.SentTo)
+	assert.Equal(t, phone, sender.to)
+	assert.Len(t, sender.code, 6)
+	assert.Equal(t, models.RequestStatusOTPPending, request.Status)
+
+	wrong := "000000"
+	if sender.code == wrong {
+		wrong = "111111"
+	}
+	_, err = service.Verify(ctx, "token", wrong)
+	assert.ErrorIs(t, err, ErrInvalidOTP)
+	assert.Equal(t, models.RequestStatusOTPPending, request.Status)
+
+	_, err = service.Verify(ctx, "token", sender.code)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusKYCProcessing, request.Status)
+	assert.NotNil(t, customer.PhoneVerifiedAt)
+	assert.Contains(t, customers.updates, "phone_verified_at")
+
+	_, err = service.Send(ctx, "token", "")
+	assert.ErrorIs(t, err, ErrOTPNotExpected)
+}
+
+func TestOTPServiceSendsTheConsentOTPToContactsVerifiedBefore(t *testing.T) {
+	phone := "+2348012345678"
+	createdAt := time.Now()
+	request := &models.Request{ID: "request-1", CompanyID: "company-2", Status: models.RequestStatusInitiated, CreatedAt: createdAt}
+	customer := &models.Customer{ID: "customer-1", Phone: &phone, Email: "ada@example.com", KYCLevelAchieved: string(models.KYCLevelTier1)}
+	service, _, sender := newTestOTPService(request, customer)
+	ctx := context.Background()
+
+	// A phone number verified during this request may belong to whoever
+	// started it, so the consent OTP goes to the email.
+	verifiedAt := createdAt.Add(time.Minute)
+	customer.PhoneVerifiedAt = &verifiedAt
+	delivery, err := service.Send(ctx, "token", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OTPChannelEmail, delivery.Channel)
+	assert.Equal(t, "ada@example.com", sender.to)
+	_, err = service.Send(ctx, "token", models.OTPChannelSMS)
+	assert.ErrorIs(t, err, ErrOTPChannelMissing)
+
+	verifiedAt = createdAt.Add(-time.Hour)
+	delivery, err = service.Send(ctx, "token", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.OTPChannelSMS, delivery.Channel)
+	assert.Equal(t, phone, sender.to)
+}
+
+func TestOTPServiceVerifyLockout(t *testing.T) {
+	request := &models.Request{ID: "request-1", Status: models.RequestStatusInitiated}
+	customer := &models.Customer{ID: "customer-1", Email: "ada@example.com"}
+	service, customers, sender := newTestOTPService(request, customer)
+	ctx := context.Background()
+
+	delivery, err := service.Send(ctx, "token", models.OTPChannelEmail)
+	assert.NoError(t, err)
+	assert.Equal(t, "a***@example.com", delivery.SentTo)
+
+	_, err = service.Send(ctx, "token", models.OTPChannelSMS)
+	assert.ErrorIs(t, err, ErrOTPChannelMissing)
+
+	wrong := "000000"
+	if sender.code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < maxOTPAttempts; i++ {
+		_, err = service.Verify(ctx, "token", wrong)
+		assert.ErrorIs(t, err, ErrInvalidOTP)
+	}
+	_, err = service.Verify(ctx, "token", sender.code)
+	assert.ErrorIs(t, err, ErrTooManyOTPAttempts)
+	assert.Equal(t, models.RequestStatusOTPPending, request.Status)
+	assert.Nil(t, customers.updates)
+}
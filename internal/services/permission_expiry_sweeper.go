@@ -0,0 +1,74 @@
+package services
+
+import (
+	repositories "confam-api/internal/repositories"
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	permissionExpirySweepInterval  = time.Minute
+	permissionExpirySweepBatchSize = 100
+)
+
+// PermissionExpirySweeper expires temporary permissions once their expiry
+// passes. They stop granting access at that moment regardless; sweeping is
+// what tells the company's app.
+type PermissionExpirySweeper struct {
+	permissionRepo repositories.ICustomerPermissionRepository
+	permissions    IPermissionService
+}
+
+func NewPermissionExpirySweeper(permissionRepo repositories.ICustomerPermissionRepository, permissions IPermissionService) *PermissionExpirySweeper {
+	return &PermissionExpirySweeper{
+		permissionRepo: permissionRepo,
+		permissions:    permissions,
+	}
+}
+
+// Run sweeps every minute until ctx is cancelled.
+func (s *PermissionExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(permissionExpirySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx, time.Now()); err != nil {
+			log.Printf("Failed to sweep expired permissions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep expires the permissions whose expiry passed by now and returns how
+// many it expired.
+func (s *PermissionExpirySweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	expired := 0
+	for {
+		permissions, err := s.permissionRepo.FindExpired(ctx, now, permissionExpirySweepBatchSize)
+		if err != nil {
+			return expired, err
+		}
+
+		swept := 0
+		for i := range permissions {
+			ok, err := s.permissions.Expire(ctx, &permissions[i], now)
+			if err != nil {
+				return expired, err
+			}
+			if ok {
+				swept++
+			}
+		}
+		expired += swept
+
+		if len(permissions) < permissionExpirySweepBatchSize || swept == 0 {
+			return expired, nil
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook events sent to a company's app when it loses access to a customer.
+const (
+	PermissionExpiredEvent = "permission.expired"
+	PermissionRevokedEvent = "permission.revoked"
+)
+
+var ErrPermissionNotFound = errors.New("This business has no access to your KYC")
+
+type IPermissionService interface {
+	Revoke(ctx context.Context, customer *models.Customer, companyID string) (*models.CustomerPermission, error)
+	Expire(ctx context.Context, permission *models.CustomerPermission, now time.Time) (bool, error)
+}
+
+// PermissionService takes away a company's access to a customer's KYC and
+// tells the app the permission was granted through.
+type PermissionService struct {
+	permissionRepo repositories.ICustomerPermissionRepository
+	webhookService IWebhookService
+}
+
+func NewPermissionService(
+	permissionRepo repositories.ICustomerPermissionRepository,
+	webhookService IWebhookService,
+) *PermissionService {
+	return &PermissionService{
+		permissionRepo: permissionRepo,
+		webhookService: webhookService,
+	}
+}
+
+// Revoke revokes the customer's active permission for the company, returning
+// ErrPermissionNotFound if there is none.
+func (s *PermissionService) Revoke(ctx context.Context, customer *models.Customer, companyID string) (*models.CustomerPermission, error) {
+	permission, err := s.permissionRepo.FindActive(ctx, customer.ID, companyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPermissionNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	outbox, err := s.webhooks(ctx, PermissionRevokedEvent, customer, permission, now)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.permissionRepo.Revoke(ctx, permission.ID, now, outbox...)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrPermissionNotFound
+	}
+	permission.RevokedAt = &now
+	return permission, nil
+}
+
+// Expire marks a permission whose expiry has passed as expired and tells the
+// company's app, reporting false if it was already expired or revoked.
+func (s *PermissionService) Expire(ctx context.Context, permission *models.CustomerPermission, now time.Time) (bool, error) {
+	outbox, err := s.webhooks(ctx, PermissionExpiredEvent, permission.Customer, permission, now)
+	if err != nil {
+		return false, err
+	}
+	expired, err := s.permissionRepo.MarkExpired(ctx, permission.ID, now, outbox...)
+	if err != nil || !expired {
+		return false, err
+	}
+	permission.ExpiredAt = &now
+	return true, nil
+}
+
+// webhooks builds the webhooks telling the app the permission was granted
+// through that it ended.
+func (s *PermissionService) webhooks(ctx context.Context, event string, customer *models.Customer, permission *models.CustomerPermission, occurredAt time.Time) ([]models.Webhook, error) {
+	if s.webhookService == nil {
+		return nil, nil
+	}
+
+	data := map[string]interface{}{
+		"app":         permission.AppID,
+		"business":    permission.CompanyID,
+		"id":          permission.ID,
+		"request":     permission.RequestID,
+		"scopes":      permission.Scopes,
+		"access_type": permission.AccessType,
+		"granted_at":  permission.GrantedAt,
+		"expires_at":  permission.ExpiresAt,
+		"occurred_at": occurredAt,
+	}
+	environment := models.EnvironmentLive
+	if customer != nil {
+		environment = customer.Environment
+		data["customer"] = customer.Token
+		data["environment"] = customer.Environment
+	}
+	return s.webhookService.Build(ctx, WebhookEvent{
+		CompanyID:   permission.CompanyID,
+		AppID:       permission.AppID,
+		Environment: environment,
+		Type:        event,
+		Data:        data,
+	})
+}
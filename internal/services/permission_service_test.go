@@ -0,0 +1,132 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"confam-api/internal/repositories/mocks"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCustomerDataRepository struct {
+	fakeCustomerRepository
+	customer     *models.Customer
+	bankAccounts []models.BankAccount
+}
+
+func (r *fakeCustomerDataRepository) FindByID(ctx context.Context, id string) (*models.Customer, error) {
+	return r.customer, nil
+}
+
+func (r *fakeCustomerDataRepository) ListBankAccounts(ctx context.Context, customerID string) ([]models.BankAccount, error) {
+	return r.bankAccounts, nil
+}
+
+func newTestPermissionService() (*PermissionService, *fakePermissionRepository) {
+	webhookURL := "https://example.com/hooks"
+	permissions := &fakePermissionRepository{}
+	webhooks := newTestWebhookService(&models.App{ID: "app-1", CompanyID: "company-1", WebhookURL: &webhookURL})
+	return NewPermissionService(permissions, webhooks), permissions
+}
+
+func TestPermissionServiceRevoke(t *testing.T) {
+	service, permissions := newTestPermissionService()
+	appID := "app-1"
+	permissions.permissions = []models.CustomerPermission{
+		{ID: "permission-1", CustomerID: "customer-1", CompanyID: "company-1", AppID: &appID, Scopes: models.PermissionScopes{models.ScopeIdentity}},
+	}
+	customer := &models.Customer{ID: "customer-1", Token: "customer-token"}
+
+	permission, err := service.Revoke(context.Background(), customer, "company-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, permission.RevokedAt)
+	assert.NotNil(t, permissions.permissions[0].RevokedAt)
+	assert.Len(t, permissions.outbox, 1)
+	assert.Equal(t, PermissionRevokedEvent, permissions.outbox[0].EventType)
+	assert.Contains(t, permissions.outbox[0].Payload, `"customer":"customer-token"`)
+
+	_, err = service.Revoke(context.Background(), customer, "company-1")
+	assert.ErrorIs(t, err, ErrPermissionNotFound)
+	assert.Len(t, permissions.outbox, 1)
+}
+
+func TestPermissionExpirySweeperSweep(t *testing.T) {
+	service, permissions := newTestPermissionService()
+	appID := "app-1"
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	permissions.permissions = []models.CustomerPermission{
+		{ID: "permission-1", CustomerID: "customer-1", CompanyID: "company-1", AppID: &appID, AccessType: models.AccessTemporary, ExpiresAt: &past},
+		{ID: "permission-2", CustomerID: "customer-2", CompanyID: "company-1", AppID: &appID, AccessType: models.AccessTemporary, ExpiresAt: &future},
+		{ID: "permission-3", CustomerID: "customer-3", CompanyID: "company-1", AppID: &appID},
+	}
+	sweeper := NewPermissionExpirySweeper(permissions, service)
+
+	expired, err := sweeper.Sweep(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.NotNil(t, permissions.permissions[0].ExpiredAt)
+	assert.Nil(t, permissions.permissions[1].ExpiredAt)
+	assert.Len(t, permissions.outbox, 1)
+	assert.Equal(t, PermissionExpiredEvent, permissions.outbox[0].EventType)
+
+	expired, err = sweeper.Sweep(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	assert.Len(t, permissions.outbox, 1)
+}
+
+func TestKYCServiceGetRequestEnforcesPermission(t *testing.T) {
+	customerID := "customer-1"
+	phone, address := "08012345678", "1 Marina, Lagos"
+	request := &models.Request{ID: "request-1", CompanyID: "company-1", CustomerID: &customerID, BankAccountsRequested: true}
+	requestRepo := &mocks.MockRequestRepository{
+		FindByReferenceFunc: func(reference string) (*models.Request, error) { return request, nil },
+	}
+	customers := &fakeCustomerDataRepository{
+		customer: &models.Customer{
+			ID:         customerID,
+			Phone:      &phone,
+			Address:    &address,
+			Identities: []models.Identity{{Type: models.IdentityTypeBVN, Verified: true}},
+		},
+		bankAccounts: []models.BankAccount{{ID: "account-1"}},
+	}
+	permissions := &fakePermissionRepository{}
+	service := NewKYCService(customers, requestRepo, permissions, nil, nil, nil, nil, nil)
+	app := models.App{ID: "app-1", CompanyID: "company-1"}
+
+	// Without a permission the company only sees the customer's KYC status.
+	details, err := service.GetRequest(context.Background(), app, models.EnvironmentLive, "ref-1")
+	assert.NoError(t, err)
+	assert.Nil(t, details.Permission)
+	assert.Empty(t, details.Customer.Identities)
+	assert.Nil(t, details.Customer.Phone)
+	assert.Nil(t, details.Customer.Address)
+	assert.Nil(t, details.BankAccounts)
+
+	permissions.permissions = []models.CustomerPermission{{
+		ID:         "permission-1",
+		CustomerID: customerID,
+		CompanyID:  "company-1",
+		Scopes:     models.PermissionScopes{models.ScopeIdentity, models.ScopeAddress},
+	}}
+	details, err = service.GetRequest(context.Background(), app, models.EnvironmentLive, "ref-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, details.Permission)
+	assert.Len(t, details.Customer.Identities, 1)
+	assert.Nil(t, details.Customer.Phone)
+	assert.Equal(t, &address, details.Customer.Address)
+	assert.Nil(t, details.BankAccounts)
+	assert.NotNil(t, customers.customer.Phone)
+
+	// An expired permission grants nothing, even before it is swept.
+	expired := time.Now().Add(-time.Minute)
+	permissions.permissions[0].ExpiresAt = &expired
+	details, err = service.GetRequest(context.Background(), app, models.EnvironmentLive, "ref-1")
+	assert.NoError(t, err)
+	assert.Nil(t, details.Permission)
+	assert.Empty(t, details.Customer.Identities)
+}
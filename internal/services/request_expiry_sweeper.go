@@ -0,0 +1,91 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	defaultKYCTokenRenewalWindow = 24 * time.Hour
+	requestExpirySweepInterval   = time.Minute
+	requestExpirySweepBatchSize  = 100
+)
+
+// kycTokenRenewalWindow is how long after its token expires a request can
+// still be renewed before the sweeper fails it. It is read from
+// KYC_TOKEN_RENEWAL_WINDOW, e.g. "24h".
+func kycTokenRenewalWindow() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("KYC_TOKEN_RENEWAL_WINDOW")); err == nil && d >= 0 {
+		return d
+	}
+	return defaultKYCTokenRenewalWindow
+}
+
+// RequestExpirySweeper fails requests still waiting on the customer whose KYC
+// token expired longer ago than the renewal window. Requests in
+// kyc_processing are left alone: they no longer depend on the token.
+type RequestExpirySweeper struct {
+	requestRepo repositories.IRequestRepository
+	lifecycle   IRequestLifecycleService
+	window      time.Duration
+}
+
+func NewRequestExpirySweeper(requestRepo repositories.IRequestRepository, lifecycle IRequestLifecycleService) *RequestExpirySweeper {
+	return &RequestExpirySweeper{
+		requestRepo: requestRepo,
+		lifecycle:   lifecycle,
+		window:      kycTokenRenewalWindow(),
+	}
+}
+
+// Run sweeps every minute until ctx is cancelled.
+func (s *RequestExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(requestExpirySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx, time.Now()); err != nil {
+			log.Printf("Failed to sweep expired KYC requests: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep fails the requests that expired before now minus the renewal window
+// and returns how many it failed.
+func (s *RequestExpirySweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	failed := 0
+	for {
+		requests, err := s.requestRepo.FindExpired(now.Add(-s.window), requestExpirySweepBatchSize)
+		if err != nil {
+			return failed, err
+		}
+
+		swept := 0
+		for i := range requests {
+			_, err := s.lifecycle.Transition(ctx, &requests[i], models.RequestStatusFailed, "token_expired")
+			if errors.Is(err, repositories.ErrRequestStatusChanged) {
+				continue
+			}
+			if err != nil {
+				return failed, err
+			}
+			swept++
+		}
+		failed += swept
+
+		if len(requests) < requestExpirySweepBatchSize || swept == 0 {
+			return failed, nil
+		}
+	}
+}
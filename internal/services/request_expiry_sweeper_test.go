@@ -0,0 +1,46 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/internal/repositories/mocks"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestExpirySweeperSweep(t *testing.T) {
+	now := time.Now()
+	var cutoff time.Time
+	requestRepo := &mocks.MockRequestRepository{
+		FindExpiredFunc: func(before time.Time, limit int) ([]models.Request, error) {
+			cutoff = before
+			return []models.Request{
+				{ID: "request-1", Status: models.RequestStatusInitiated},
+				{ID: "request-2", Status: models.RequestStatusOTPPending},
+				{ID: "request-3", Status: models.RequestStatusOTPPending},
+			}, nil
+		},
+	}
+	var failed []string
+	requestRepo.TransitionFunc = func(request *models.Request, to models.RequestStatus, reason string) (*models.RequestEvent, error) {
+		assert.Equal(t, models.RequestStatusFailed, to)
+		assert.Equal(t, "token_expired", reason)
+		if request.ID == "request-2" {
+			return nil, repositories.ErrRequestStatusChanged
+		}
+		failed = append(failed, request.ID)
+		return &models.RequestEvent{RequestID: request.ID, ToStatus: to}, nil
+	}
+
+	sweeper := NewRequestExpirySweeper(requestRepo, NewRequestLifecycleService(requestRepo, newTestWebhookService()))
+	sweeper.window = time.Hour
+
+	count, err := sweeper.Sweep(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"request-1", "request-3"}, failed)
+	assert.Equal(t, now.Add(-time.Hour), cutoff)
+}
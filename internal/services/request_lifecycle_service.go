@@ -0,0 +1,135 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrIllegalTransition = errors.New("illegal request status transition")
+
+const (
+	// RequestStatusChangedEvent is the webhook event sent for every status change.
+	RequestStatusChangedEvent = "kyc.status.changed"
+	// RequestCompletedEvent and RequestFailedEvent are also sent when a
+	// request reaches those statuses.
+	RequestCompletedEvent = "kyc.completed"
+	RequestFailedEvent    = "kyc.failed"
+)
+
+type IRequestLifecycleService interface {
+	Transition(ctx context.Context, request *models.Request, to models.RequestStatus, reason string, outbox ...models.Webhook) (*models.RequestEvent, error)
+	Complete(ctx context.Context, request *models.Request, completion repositories.RequestCompletion) (*models.RequestEvent, error)
+	History(ctx context.Context, requestID string) ([]models.RequestEvent, error)
+}
+
+// RequestLifecycleService is the only place a request changes status. It
+// enforces the transitions allowed by models.RequestStatus, keeps the history
+// in request_events and tells the request's app about every change:
+//
+//	initiated → otp_pending → kyc_processing → completed
+//	initiated → kyc_processing (identity sent for manual review)
+//	any status that is not final → failed
+type RequestLifecycleService struct {
+	requestRepo    repositories.IRequestRepository
+	webhookService IWebhookService
+}
+
+func NewRequestLifecycleService(
+	requestRepo repositories.IRequestRepository,
+	webhookService IWebhookService,
+) *RequestLifecycleService {
+	return &RequestLifecycleService{
+		requestRepo:    requestRepo,
+		webhookService: webhookService,
+	}
+}
+
+// Transition moves the request to the given status, returning
+// ErrIllegalTransition if its current status does not allow it. Webhooks in
+// outbox are stored along with the ones announcing the change.
+func (s *RequestLifecycleService) Transition(ctx context.Context, request *models.Request, to models.RequestStatus, reason string, outbox ...models.Webhook) (*models.RequestEvent, error) {
+	from := request.Status
+	if !from.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrIllegalTransition, from, to)
+	}
+
+	webhooks, err := s.webhooks(ctx, request, from, to, reason)
+	if err != nil {
+		return nil, err
+	}
+	return s.requestRepo.Transition(request, to, reason, append(outbox, webhooks...)...)
+}
+
+// Complete moves the request to completed together with the changes completing
+// it makes to the customer and their permissions, so either all of them are
+// stored or none is.
+func (s *RequestLifecycleService) Complete(ctx context.Context, request *models.Request, completion repositories.RequestCompletion) (*models.RequestEvent, error) {
+	from := request.Status
+	to := models.RequestStatusCompleted
+	if !from.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrIllegalTransition, from, to)
+	}
+
+	outbox, err := s.webhooks(ctx, request, from, to, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.requestRepo.Complete(request, completion, outbox...)
+}
+
+// History returns the status changes of the request, oldest first.
+func (s *RequestLifecycleService) History(ctx context.Context, requestID string) ([]models.RequestEvent, error) {
+	return s.requestRepo.ListEvents(requestID)
+}
+
+// webhooks builds the webhooks telling the request's app about the status
+// change. Requests created before they were linked to an app are not notified.
+func (s *RequestLifecycleService) webhooks(ctx context.Context, request *models.Request, from, to models.RequestStatus, reason string) ([]models.Webhook, error) {
+	if s.webhookService == nil {
+		return nil, nil
+	}
+	var reasonValue *string
+	if reason != "" {
+		reasonValue = &reason
+	}
+	data := map[string]interface{}{
+		"app":         request.AppID,
+		"business":    request.CompanyID,
+		"environment": request.Environment,
+		"id":          request.ID,
+		"reference":   request.Reference,
+		"from":        from,
+		"status":      to,
+		"reason":      reasonValue,
+		"occurred_at": time.Now(),
+	}
+
+	events := []string{RequestStatusChangedEvent}
+	switch to {
+	case models.RequestStatusCompleted:
+		events = append(events, RequestCompletedEvent)
+	case models.RequestStatusFailed:
+		events = append(events, RequestFailedEvent)
+	}
+
+	var outbox []models.Webhook
+	for _, event := range events {
+		webhooks, err := s.webhookService.Build(ctx, WebhookEvent{
+			CompanyID:   request.CompanyID,
+			AppID:       request.AppID,
+			Environment: request.Environment,
+			Type:        event,
+			Reference:   request.Reference,
+			Data:        data,
+		})
+		if err != nil {
+			return nil, err
+		}
+		outbox = append(outbox, webhooks...)
+	}
+	return outbox, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/internal/repositories/mocks"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakeAppRepository struct {
+	repositories.IAppRepository
+	apps map[string]*models.App
+}
+
+func (r *fakeAppRepository) FindCompanyApp(ctx context.Context, companyID, id string) (*models.App, error) {
+	app, ok := r.apps[id]
+	if !ok || app.CompanyID != companyID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return app, nil
+}
+
+type fakeWebhookRepository struct {
+	repositories.IWebhookRepository
+	webhooks []models.Webhook
+}
+
+func (r *fakeWebhookRepository) Create(ctx context.Context, webhooks []models.Webhook) error {
+	r.webhooks = append(r.webhooks, webhooks...)
+	return nil
+}
+
+// newTestWebhookService returns a webhook service for the apps, all of company-1.
+func newTestWebhookService(apps ...*models.App) *WebhookService {
+	appRepo := &fakeAppRepository{apps: map[string]*models.App{}}
+	for _, app := range apps {
+		appRepo.apps[app.ID] = app
+	}
+	return NewWebhookService(appRepo, &fakeWebhookEndpointRepository{}, &fakeWebhookRepository{})
+}
+
+func TestRequestLifecycleServiceTransition(t *testing.T) {
+	webhookURL := "https://example.com/hooks"
+	appID := "app-1"
+	requestRepo := &mocks.MockRequestRepository{}
+	service := NewRequestLifecycleService(requestRepo, newTestWebhookService(&models.App{ID: appID, CompanyID: "company-1", WebhookURL: &webhookURL}))
+
+	request := &models.Request{ID: "request-1", CompanyID: "company-1", AppID: &appID, Status: models.RequestStatusInitiated}
+
+	event, err := service.Transition(context.Background(), request, models.RequestStatusOTPPending, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RequestStatusOTPPending, request.Status)
+	assert.Equal(t, models.RequestStatusInitiated, *event.FromStatus)
+	assert.Len(t, requestRepo.Outbox, 1)
+	assert.Equal(t, webhookURL, requestRepo.Outbox[0].TargetURL)
+	assert.Equal(t, RequestStatusChangedEvent, requestRepo.Outbox[0].EventType)
+	assert.Equal(t, models.WebhookStatusPending, requestRepo.Outbox[0].Status)
+	assert.Contains(t, requestRepo.Outbox[0].Payload, `"status":"otp_pending"`)
+
+	_, err = service.Transition(context.Background(), request, models.RequestStatusCompleted, "")
+	assert.ErrorIs(t, err, ErrIllegalTransition)
+	assert.Equal(t, models.RequestStatusOTPPending, request.Status)
+	assert.Len(t, requestRepo.Outbox, 1)
+
+	_, err = service.Transition(context.Background(), request, models.RequestStatusFailed, "otp_expired")
+	assert.NoError(t, err)
+	assert.Len(t, requestRepo.Outbox, 3)
+	assert.Equal(t, RequestStatusChangedEvent, requestRepo.Outbox[1].EventType)
+	assert.Equal(t, RequestFailedEvent, requestRepo.Outbox[2].EventType)
+}
+
+func TestRequestLifecycleServiceTransitionFinal(t *testing.T) {
+	requestRepo := &mocks.MockRequestRepository{}
+	service := NewRequestLifecycleService(requestRepo, newTestWebhookService())
+
+	request := &models.Request{ID: "request-1", Status: models.RequestStatusFailed}
+	_, err := service.Transition(context.Background(), request, models.RequestStatusKYCProcessing, "")
+	assert.ErrorIs(t, err, ErrIllegalTransition)
+	assert.Empty(t, requestRepo.Outbox)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"context"
+	"strings"
+)
+
+// Sandbox identity numbers. The outcome of a sandbox lookup depends only on
+// how the BVN or NIN ends:
+//
+//	...0000   verified
+//	...1111   not found, the identity is rejected
+//	...2222   the provider times out
+//	...3333   the identity needs manual review
+//
+// Any other number is verified, like ...0000.
+const (
+	SandboxSuffixVerified = "0000"
+	SandboxSuffixRejected = "1111"
+	SandboxSuffixTimeout  = "2222"
+	SandboxSuffixReview   = "3333"
+)
+
+// SandboxIdentityProvider answers identity lookups for sandbox requests
+// without contacting a real provider.
+type SandboxIdentityProvider struct{}
+
+func NewSandboxIdentityProvider() *SandboxIdentityProvider {
+	return &SandboxIdentityProvider{}
+}
+
+func (p *SandboxIdentityProvider) Name() string {
+	return "sandbox"
+}
+
+func (p *SandboxIdentityProvider) Lookup(ctx context.Context, identityType models.IdentityType, number string) (*IdentityRecord, error) {
+	switch {
+	case strings.HasSuffix(number, SandboxSuffixRejected):
+		return nil, ErrIdentityNotFound
+	case strings.HasSuffix(number, SandboxSuffixTimeout):
+		return nil, ErrIdentityProviderTimeout
+	case strings.HasSuffix(number, SandboxSuffixReview):
+		return nil, ErrIdentityReviewRequired
+	}
+
+	return &IdentityRecord{
+		FirstName:   "Sandbox",
+		LastName:    "Customer",
+		DateOfBirth: "1990-01-01",
+		Phone:       "+2348000000000",
+		Address:     "1 Sandbox Street, Lagos",
+		Reference:   "sandbox_" + strings.ToLower(string(identityType)) + "_" + number,
+	}, nil
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxIdentityProviderLookup(t *testing.T) {
+	provider := NewSandboxIdentityProvider()
+	ctx := context.Background()
+
+	record, err := provider.Lookup(ctx, models.IdentityTypeBVN, "22222220000")
+	assert.NoError(t, err)
+	assert.Equal(t, "sandbox_bvn_22222220000", record.Reference)
+
+	_, err = provider.Lookup(ctx, models.IdentityTypeNIN, "12345671111")
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+
+	_, err = provider.Lookup(ctx, models.IdentityTypeBVN, "12345672222")
+	assert.ErrorIs(t, err, ErrIdentityProviderTimeout)
+
+	_, err = provider.Lookup(ctx, models.IdentityTypeNIN, "12345673333")
+	assert.ErrorIs(t, err, ErrIdentityReviewRequired)
+
+	record, err = provider.Lookup(ctx, models.IdentityTypeNIN, "12345678901")
+	assert.NoError(t, err)
+	assert.NotNil(t, record)
+}
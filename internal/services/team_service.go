@@ -0,0 +1,230 @@
+package services
+
+import (
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidInvitation  = errors.New("This invitation is invalid or has expired")
+	ErrInvitationNotFound = errors.New("Invitation not found")
+	ErrMemberNotFound     = errors.New("Team member not found")
+	ErrCannotModifyOwner  = errors.New("The company owner cannot be changed or removed")
+	ErrCannotModifySelf   = errors.New("You cannot change or remove your own membership")
+)
+
+type ITeamService interface {
+	ListMembers(ctx context.Context, companyID string) ([]models.CompanyUser, error)
+	Invite(ctx context.Context, inviter *models.CompanyUser, req structs.InviteMemberRequest) (*models.Invitation, error)
+	ListInvitations(ctx context.Context, companyID string) ([]models.Invitation, error)
+	RevokeInvitation(ctx context.Context, companyID, id string) error
+	AcceptInvitation(ctx context.Context, req structs.AcceptInvitationRequest) (*models.CompanyUser, error)
+	UpdateMemberRole(ctx context.Context, actor *models.CompanyUser, memberID string, req structs.UpdateMemberRoleRequest) (*models.CompanyUser, error)
+	RemoveMember(ctx context.Context, actor *models.CompanyUser, memberID string) error
+}
+
+// TeamService manages the members of a company's team and their invitations.
+type TeamService struct {
+	userRepo       repositories.ICompanyUserRepository
+	invitationRepo repositories.IInvitationRepository
+	sessionRepo    repositories.ISessionRepository
+	mailService    IMailService
+}
+
+func NewTeamService(
+	userRepo repositories.ICompanyUserRepository,
+	invitationRepo repositories.IInvitationRepository,
+	sessionRepo repositories.ISessionRepository,
+	mailService IMailService,
+) *TeamService {
+	return &TeamService{
+		userRepo:       userRepo,
+		invitationRepo: invitationRepo,
+		sessionRepo:    sessionRepo,
+		mailService:    mailService,
+	}
+}
+
+func (s *TeamService) ListMembers(ctx context.Context, companyID string) ([]models.CompanyUser, error) {
+	members, err := s.userRepo.ListByCompany(ctx, companyID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return members, nil
+}
+
+// Invite emails a single-use invite link to join the inviter's company with the requested role.
+func (s *TeamService) Invite(ctx context.Context, inviter *models.CompanyUser, req structs.InviteMemberRequest) (*models.Invitation, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if _, err := s.userRepo.FindByEmail(ctx, email); err == nil {
+		return nil, ErrEmailAlreadyTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+
+	token, err := crypto.GenerateHexToken()
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+
+	invitation := &models.Invitation{
+		CompanyID:   inviter.CompanyID,
+		Email:       email,
+		Role:        models.Role(req.Role),
+		TokenHash:   crypto.HashSHA256(token),
+		InvitedByID: inviter.ID,
+		ExpiresAt:   time.Now().Add(invitationTTL),
+	}
+	if err := s.invitationRepo.Create(ctx, invitation); err != nil {
+		return nil, errors.New("Could not send invitation. Please try again later.")
+	}
+
+	companyName := ""
+	if inviter.Company != nil {
+		companyName = inviter.Company.Name
+	}
+	link := fmt.Sprintf("%s/invitations/accept?token=%s", dashboardURL(), url.QueryEscape(token))
+	body := fmt.Sprintf(
+		"%s %s invited you to join %s on Confam as %s.\n\nAccept the invitation within %s using the link below.\n\n%s",
+		inviter.FirstName,
+		inviter.LastName,
+		companyName,
+		invitation.Role,
+		invitationTTL,
+		link,
+	)
+	if err := s.mailService.Send(ctx, email, "You have been invited to Confam", body); err != nil {
+		log.Printf("Failed to send invitation %s: %v", invitation.ID, err)
+	}
+	return invitation, nil
+}
+
+func (s *TeamService) ListInvitations(ctx context.Context, companyID string) ([]models.Invitation, error) {
+	invitations, err := s.invitationRepo.ListPendingByCompany(ctx, companyID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return invitations, nil
+}
+
+func (s *TeamService) RevokeInvitation(ctx context.Context, companyID, id string) error {
+	invitation, err := s.invitationRepo.FindByCompany(ctx, companyID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvitationNotFound
+		}
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+	if err := s.invitationRepo.Delete(ctx, invitation.ID); err != nil {
+		return errors.New("Could not revoke invitation. Please try again later.")
+	}
+	return nil
+}
+
+// AcceptInvitation creates the invited user's account. Following the link
+// proves ownership of the email, so the account starts out verified.
+func (s *TeamService) AcceptInvitation(ctx context.Context, req structs.AcceptInvitationRequest) (*models.CompanyUser, error) {
+	if req.Password != req.ConfirmPassword {
+		return nil, ErrPasswordMismatch
+	}
+
+	invitation, err := s.invitationRepo.FindByTokenHash(ctx, crypto.HashSHA256(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	if !invitation.IsPending() {
+		return nil, ErrInvalidInvitation
+	}
+
+	if _, err := s.userRepo.FindByEmail(ctx, invitation.Email); err == nil {
+		return nil, ErrEmailAlreadyTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+
+	now := time.Now()
+	user := &models.CompanyUser{
+		CompanyID:       invitation.CompanyID,
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		Email:           invitation.Email,
+		Role:            invitation.Role,
+		EmailVerifiedAt: &now,
+	}
+	if err := user.SetPassword(req.Password); err != nil {
+		return nil, errors.New("Could not accept invitation. Please try again later.")
+	}
+	if err := s.invitationRepo.Accept(ctx, invitation, user); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, errors.New("Could not accept invitation. Please try again later.")
+	}
+	return user, nil
+}
+
+// UpdateMemberRole changes the role of another member of the actor's company.
+func (s *TeamService) UpdateMemberRole(ctx context.Context, actor *models.CompanyUser, memberID string, req structs.UpdateMemberRoleRequest) (*models.CompanyUser, error) {
+	member, err := s.findModifiableMember(ctx, actor, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role(req.Role)
+	if err := s.userRepo.UpdateFields(ctx, member.ID, map[string]interface{}{"role": role}); err != nil {
+		return nil, errors.New("Could not update team member. Please try again later.")
+	}
+	member.Role = role
+	return member, nil
+}
+
+// RemoveMember deletes another member of the actor's company and ends their sessions.
+func (s *TeamService) RemoveMember(ctx context.Context, actor *models.CompanyUser, memberID string) error {
+	member, err := s.findModifiableMember(ctx, actor, memberID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Delete(ctx, member.ID); err != nil {
+		return errors.New("Could not remove team member. Please try again later.")
+	}
+	if err := s.sessionRepo.RevokeAllForUser(ctx, member.ID); err != nil {
+		log.Printf("Failed to revoke sessions for user %s: %v", member.ID, err)
+	}
+	return nil
+}
+
+// findModifiableMember loads a member of the actor's company, refusing the
+// owner and the actor themselves.
+func (s *TeamService) findModifiableMember(ctx context.Context, actor *models.CompanyUser, memberID string) (*models.CompanyUser, error) {
+	member, err := s.userRepo.FindByCompany(ctx, actor.CompanyID, memberID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	if member.ID == actor.ID {
+		return nil, ErrCannotModifySelf
+	}
+	if member.Role == models.RoleOwner {
+		return nil, ErrCannotModifyOwner
+	}
+	return member, nil
+}
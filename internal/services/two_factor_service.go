@@ -0,0 +1,221 @@
+package services
+
+import (
+	auth "confam-api/internal/auth"
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer           = "Confam"
+	recoveryCodeCount    = 10
+	maxTwoFactorAttempts = 5
+	twoFactorLockout     = 15 * time.Minute
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled  = errors.New("Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled      = errors.New("Two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp        = errors.New("Start two-factor setup before enabling it")
+	ErrInvalidTwoFactorCode     = errors.New("The authentication code is invalid")
+	ErrTooManyTwoFactorAttempts = errors.New("Too many invalid codes. Please try again later")
+)
+
+type ITwoFactorService interface {
+	Setup(ctx context.Context, user *models.CompanyUser, req structs.TwoFactorSetupRequest) (*structs.TwoFactorSetup, error)
+	Enable(ctx context.Context, user *models.CompanyUser, req structs.EnableTwoFactorRequest) ([]string, error)
+	Disable(ctx context.Context, user *models.CompanyUser, req structs.DisableTwoFactorRequest) error
+	Verify(ctx context.Context, user *models.CompanyUser, code string) error
+}
+
+// TwoFactorService manages TOTP enrollment and verification for company users.
+type TwoFactorService struct {
+	userRepo     repositories.ICompanyUserRepository
+	recoveryRepo repositories.IRecoveryCodeRepository
+	attemptRepo  repositories.IAttemptRepository
+}
+
+func NewTwoFactorService(
+	userRepo repositories.ICompanyUserRepository,
+	recoveryRepo repositories.IRecoveryCodeRepository,
+	attemptRepo repositories.IAttemptRepository,
+) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:     userRepo,
+		recoveryRepo: recoveryRepo,
+		attemptRepo:  attemptRepo,
+	}
+}
+
+// Setup generates a new TOTP secret for the user. The secret is stored
+// encrypted but two-factor stays disabled until Enable confirms a code from it.
+func (s *TwoFactorService) Setup(ctx context.Context, user *models.CompanyUser, req structs.TwoFactorSetupRequest) (*structs.TwoFactorSetup, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if !checkPassword(user, req.Password) {
+		return nil, ErrIncorrectPassword
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.New("Could not start two-factor setup. Please try again later.")
+	}
+	encrypted, err := crypto.Encrypt(secret)
+	if err != nil {
+		return nil, errors.New("Could not start two-factor setup. Please try again later.")
+	}
+	if err := s.userRepo.UpdateFields(ctx, user.ID, map[string]interface{}{
+		"two_factor_secret": encrypted,
+	}); err != nil {
+		return nil, errors.New("Could not start two-factor setup. Please try again later.")
+	}
+
+	return &structs.TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// Enable turns on two-factor authentication once the user proves its
+// authenticator works, and returns a fresh set of recovery codes.
+func (s *TwoFactorService) Enable(ctx context.Context, user *models.CompanyUser, req structs.EnableTwoFactorRequest) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if !checkPassword(user, req.Password) {
+		return nil, ErrIncorrectPassword
+	}
+	if user.TwoFactorSecret == nil || *user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	secret, err := crypto.Decrypt(*user.TwoFactorSecret)
+	if err != nil {
+		return nil, errors.New("Could not enable two-factor authentication. Please try again later.")
+	}
+	step, ok := auth.MatchTOTP(secret, req.Code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	if accepted, err := s.userRepo.AcceptTOTPStep(ctx, user.ID, step); err != nil {
+		return nil, errors.New("Could not enable two-factor authentication. Please try again later.")
+	} else if !accepted {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, errors.New("Could not enable two-factor authentication. Please try again later.")
+	}
+	if err := s.recoveryRepo.Replace(ctx, user.ID, hashes); err != nil {
+		return nil, errors.New("Could not enable two-factor authentication. Please try again later.")
+	}
+	if err := s.userRepo.UpdateFields(ctx, user.ID, map[string]interface{}{
+		"two_factor_enabled": true,
+	}); err != nil {
+		return nil, errors.New("Could not enable two-factor authentication. Please try again later.")
+	}
+	return codes, nil
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, user *models.CompanyUser, req structs.DisableTwoFactorRequest) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if !checkPassword(user, req.Password) {
+		return ErrIncorrectPassword
+	}
+
+	if err := s.userRepo.UpdateFields(ctx, user.ID, map[string]interface{}{
+		"two_factor_enabled":   false,
+		"two_factor_secret":    nil,
+		"two_factor_last_step": nil,
+	}); err != nil {
+		return errors.New("Could not disable two-factor authentication. Please try again later.")
+	}
+	if err := s.recoveryRepo.DeleteAll(ctx, user.ID); err != nil {
+		log.Printf("Failed to delete recovery codes for user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Verify checks a TOTP code or, failing that, an unused recovery code. A TOTP
+// code is accepted once: codes from a time step no later than the last one
+// accepted are refused. Repeated failures lock the user out of two-factor
+// verification for a while.
+func (s *TwoFactorService) Verify(ctx context.Context, user *models.CompanyUser, code string) error {
+	if !user.TwoFactorEnabled || user.TwoFactorSecret == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	attemptKey := "2fa:" + user.ID
+	attempts, err := s.attemptRepo.Increment(ctx, attemptKey, twoFactorLockout)
+	if err != nil {
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+	if attempts > maxTwoFactorAttempts {
+		return ErrTooManyTwoFactorAttempts
+	}
+
+	secret, err := crypto.Decrypt(*user.TwoFactorSecret)
+	if err != nil {
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+	if step, ok := auth.MatchTOTP(secret, code, time.Now()); ok {
+		accepted, err := s.userRepo.AcceptTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return errors.New("An internal server error occurred. Please try again later")
+		}
+		if !accepted {
+			return ErrInvalidTwoFactorCode
+		}
+		s.attemptRepo.Reset(ctx, attemptKey)
+		return nil
+	}
+
+	used, err := s.recoveryRepo.Consume(ctx, user.ID, crypto.HashSHA256(normalizeRecoveryCode(code)))
+	if err != nil {
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+	if used {
+		s.attemptRepo.Reset(ctx, attemptKey)
+		return nil
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+func checkPassword(user *models.CompanyUser, password string) bool {
+	return user.Password != nil && auth.ComparePasswordAndHash(password, *user.Password) == nil
+}
+
+// generateRecoveryCodes returns recovery codes formatted as xxxxx-xxxxx along with their hashes.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, nil, fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		raw := hex.EncodeToString(b)
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, crypto.HashSHA256(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ReplaceAll(strings.TrimSpace(code), "-", "")
+}
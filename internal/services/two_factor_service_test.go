@@ -0,0 +1,117 @@
+package services
+
+import (
+	auth "confam-api/internal/auth"
+	crypto "confam-api/internal/crypto"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakeCompanyUserRepository struct {
+	repositories.ICompanyUserRepository
+	users map[string]*models.CompanyUser
+}
+
+func (r *fakeCompanyUserRepository) FindByID(ctx context.Context, id string) (*models.CompanyUser, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeCompanyUserRepository) AcceptTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	user := r.users[id]
+	if user.TwoFactorLastStep != nil && *user.TwoFactorLastStep >= step {
+		return false, nil
+	}
+	user.TwoFactorLastStep = &step
+	return true, nil
+}
+
+type fakeRecoveryCodeRepository struct {
+	repositories.IRecoveryCodeRepository
+}
+
+func (r *fakeRecoveryCodeRepository) Consume(ctx context.Context, userID, codeHash string) (bool, error) {
+	return false, nil
+}
+
+type fakeMFAChallengeRepository struct {
+	challenges map[string]string
+}
+
+func (r *fakeMFAChallengeRepository) Save(ctx context.Context, userID, tokenHash string, ttl time.Duration) error {
+	r.challenges[tokenHash] = userID
+	return nil
+}
+
+func (r *fakeMFAChallengeRepository) Consume(ctx context.Context, tokenHash string) (string, error) {
+	userID, ok := r.challenges[tokenHash]
+	if !ok {
+		return "", repositories.ErrMFAChallengeNotFound
+	}
+	delete(r.challenges, tokenHash)
+	return userID, nil
+}
+
+// newTestTwoFactorUser returns a user with two-factor enabled and its TOTP secret.
+func newTestTwoFactorUser(t *testing.T) (*models.CompanyUser, string) {
+	os.Setenv("ENCRYPTION_KEY", "7c633361cb709e1cf6ef0d68c914b5a5b1b540034331ab64702d2fd980dc7585")
+	t.Cleanup(func() { os.Unsetenv("ENCRYPTION_KEY") })
+
+	secret, err := auth.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	encrypted, err := crypto.Encrypt(secret)
+	assert.NoError(t, err)
+	return &models.CompanyUser{ID: "user-1", TwoFactorEnabled: true, TwoFactorSecret: &encrypted}, secret
+}
+
+func TestTwoFactorServiceVerifyRefusesReplayedCodes(t *testing.T) {
+	user, secret := newTestTwoFactorUser(t)
+	users := &fakeCompanyUserRepository{users: map[string]*models.CompanyUser{user.ID: user}}
+	service := NewTwoFactorService(users, &fakeRecoveryCodeRepository{}, &fakeAttemptRepository{counts: map[string]int64{}})
+
+	code, err := auth.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, service.Verify(context.Background(), user, code))
+	assert.ErrorIs(t, service.Verify(context.Background(), user, code), ErrInvalidTwoFactorCode)
+
+	previous, err := auth.TOTPCode(secret, time.Now().Add(-30*time.Second))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.Verify(context.Background(), user, previous), ErrInvalidTwoFactorCode)
+}
+
+func TestAuthServiceLoginTwoFactorConsumesTheChallenge(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+	user, secret := newTestTwoFactorUser(t)
+	users := &fakeCompanyUserRepository{users: map[string]*models.CompanyUser{user.ID: user}}
+	twoFactor := NewTwoFactorService(users, &fakeRecoveryCodeRepository{}, &fakeAttemptRepository{counts: map[string]int64{}})
+	challenges := &fakeMFAChallengeRepository{challenges: map[string]string{}}
+	service := NewAuthService(nil, users, nil, nil, challenges, nil, twoFactor)
+
+	token, err := auth.SignToken(user.ID, auth.PurposeMFAChallenge, mfaChallengeTTL)
+	assert.NoError(t, err)
+	assert.NoError(t, challenges.Save(context.Background(), user.ID, crypto.HashSHA256(token), mfaChallengeTTL))
+
+	_, err = service.LoginTwoFactor(context.Background(), structs.LoginTwoFactorRequest{MFAToken: token, Code: "abcdef"}, structs.ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	assert.Len(t, challenges.challenges, 1, "a wrong code hands the challenge back")
+
+	// Consume the challenge as a successful login would, then replay it.
+	code, err := auth.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	_, err = challenges.Consume(context.Background(), crypto.HashSHA256(token))
+	assert.NoError(t, err)
+	_, err = service.LoginTwoFactor(context.Background(), structs.LoginTwoFactorRequest{MFAToken: token, Code: code}, structs.ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+}
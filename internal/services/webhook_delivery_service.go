@@ -0,0 +1,192 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWebhookDeliveriesPerPage = 50
+	maxWebhookReplayWindow          = 31 * 24 * time.Hour
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("Webhook delivery not found")
+	ErrWebhookPending      = errors.New("This webhook is still being delivered")
+	ErrInvalidReplayWindow = errors.New("The replay window can span at most 31 days")
+)
+
+type IWebhookDeliveryService interface {
+	List(ctx context.Context, companyID, appID string, req structs.ListWebhookDeliveriesRequest) (*structs.WebhookDeliveryPage, error)
+	Get(ctx context.Context, companyID, appID, id string) (*structs.WebhookDelivery, error)
+	Replay(ctx context.Context, companyID, appID, id string) error
+	ReplayFailed(ctx context.Context, companyID, appID string, req structs.ReplayWebhooksRequest) (int64, error)
+}
+
+// WebhookDeliveryService lets a company see the webhooks sent to its apps,
+// with what their endpoints answered, and send them again. Replayed webhooks
+// go to the current URL of the app or webhook endpoint they were sent to,
+// with a fresh set of attempts.
+type WebhookDeliveryService struct {
+	appRepo      repositories.IAppRepository
+	endpointRepo repositories.IWebhookEndpointRepository
+	webhookRepo  repositories.IWebhookRepository
+}
+
+func NewWebhookDeliveryService(
+	appRepo repositories.IAppRepository,
+	endpointRepo repositories.IWebhookEndpointRepository,
+	webhookRepo repositories.IWebhookRepository,
+) *WebhookDeliveryService {
+	return &WebhookDeliveryService{
+		appRepo:      appRepo,
+		endpointRepo: endpointRepo,
+		webhookRepo:  webhookRepo,
+	}
+}
+
+// findCompanyApp returns the company's app, or ErrAppNotFound.
+func findCompanyApp(ctx context.Context, appRepo repositories.IAppRepository, companyID, appID string) (*models.App, error) {
+	app, err := appRepo.FindCompanyApp(ctx, companyID, appID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAppNotFound
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return app, nil
+}
+
+// List returns a page of the app's webhooks, newest first.
+func (s *WebhookDeliveryService) List(ctx context.Context, companyID, appID string, req structs.ListWebhookDeliveriesRequest) (*structs.WebhookDeliveryPage, error) {
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	page, perPage := req.Page, req.PerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultWebhookDeliveriesPerPage
+	}
+	filter := repositories.WebhookFilter{
+		EventType: req.Event,
+		Status:    models.WebhookStatus(req.Status),
+		Reference: req.Reference,
+		From:      req.From,
+		To:        req.To,
+	}
+	deliveries, total, err := s.webhookRepo.List(ctx, app.ID, filter, (page-1)*perPage, perPage)
+	if err != nil {
+		log.Printf("Failed to list webhooks of app %s: %v", app.ID, err)
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return &structs.WebhookDeliveryPage{
+		Deliveries: deliveries,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+	}, nil
+}
+
+// Get returns one of the app's webhooks with its delivery attempts.
+func (s *WebhookDeliveryService) Get(ctx context.Context, companyID, appID, id string) (*structs.WebhookDelivery, error) {
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.webhookRepo.FindAppWebhook(ctx, app.ID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	attempts, err := s.webhookRepo.ListAttempts(ctx, webhook.ID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return &structs.WebhookDelivery{Webhook: webhook, AttemptLog: attempts}, nil
+}
+
+// Replay sends one of the app's delivered or failed webhooks again. Pending
+// webhooks and those of a deleted endpoint cannot be replayed.
+func (s *WebhookDeliveryService) Replay(ctx context.Context, companyID, appID, id string) error {
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return err
+	}
+
+	webhook, err := s.webhookRepo.FindAppWebhook(ctx, app.ID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWebhookNotFound
+		}
+		return errors.New("An internal server error occurred. Please try again later")
+	}
+
+	targetURL := replayTargetURL(app)
+	if webhook.EndpointID != nil {
+		endpoint, err := s.endpointRepo.Find(ctx, app.ID, *webhook.EndpointID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWebhookEndpointNotFound
+			}
+			return errors.New("An internal server error occurred. Please try again later")
+		}
+		targetURL = endpoint.URL
+	}
+
+	if err := s.webhookRepo.Replay(ctx, app.ID, webhook.ID, targetURL); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWebhookNotFound
+		}
+		if errors.Is(err, repositories.ErrWebhookPending) {
+			return ErrWebhookPending
+		}
+		log.Printf("Failed to replay webhook %s of app %s: %v", id, app.ID, err)
+		return errors.New("Could not replay webhook. Please try again later.")
+	}
+	return nil
+}
+
+// ReplayFailed sends again every webhook of the app created in the window
+// that ran out of attempts, and returns how many there were. Those of deleted
+// endpoints are left alone.
+func (s *WebhookDeliveryService) ReplayFailed(ctx context.Context, companyID, appID string, req structs.ReplayWebhooksRequest) (int64, error) {
+	if req.To.Sub(req.From) > maxWebhookReplayWindow {
+		return 0, ErrInvalidReplayWindow
+	}
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed, err := s.webhookRepo.ReplayFailed(ctx, app.ID, req.From, req.To, replayTargetURL(app))
+	if err != nil {
+		log.Printf("Failed to replay failed webhooks of app %s: %v", app.ID, err)
+		return 0, errors.New("Could not replay webhooks. Please try again later.")
+	}
+	return replayed, nil
+}
+
+// replayTargetURL is where replayed webhooks of the app's webhook URL go: its
+// webhook URL, which may have been corrected since they were first sent. It
+// returns "" if the app no longer has one, and the repository then keeps the
+// webhooks' original URL.
+func replayTargetURL(app *models.App) string {
+	if app.WebhookURL == nil {
+		return ""
+	}
+	return *app.WebhookURL
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakeDeliveryLogRepository struct {
+	repositories.IWebhookRepository
+	webhooks map[string]*models.Webhook
+
+	filter        repositories.WebhookFilter
+	offset, limit int
+	replayedTo    string
+	window        [2]time.Time
+}
+
+func (r *fakeDeliveryLogRepository) List(ctx context.Context, appID string, filter repositories.WebhookFilter, offset, limit int) ([]models.Webhook, int64, error) {
+	r.filter, r.offset, r.limit = filter, offset, limit
+	return []models.Webhook{}, 0, nil
+}
+
+func (r *fakeDeliveryLogRepository) FindAppWebhook(ctx context.Context, appID, id string) (*models.Webhook, error) {
+	webhook, ok := r.webhooks[id]
+	if !ok || webhook.AppID == nil || *webhook.AppID != appID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return webhook, nil
+}
+
+func (r *fakeDeliveryLogRepository) ListAttempts(ctx context.Context, webhookID string) ([]models.WebhookAttempt, error) {
+	return []models.WebhookAttempt{{WebhookID: webhookID}}, nil
+}
+
+func (r *fakeDeliveryLogRepository) Replay(ctx context.Context, appID, id, targetURL string) error {
+	webhook, err := r.FindAppWebhook(ctx, appID, id)
+	if err != nil {
+		return err
+	}
+	if webhook.Status == models.WebhookStatusPending {
+		return repositories.ErrWebhookPending
+	}
+	r.replayedTo = targetURL
+	return nil
+}
+
+func (r *fakeDeliveryLogRepository) ReplayFailed(ctx context.Context, appID string, from, to time.Time, targetURL string) (int64, error) {
+	r.window = [2]time.Time{from, to}
+	r.replayedTo = targetURL
+	return 3, nil
+}
+
+func newTestWebhookDeliveryService() (*WebhookDeliveryService, *fakeDeliveryLogRepository) {
+	webhookURL := "https://example.com/fixed-hooks"
+	appID := "app-1"
+	otherAppID := "app-2"
+	apps := &fakeAppRepository{apps: map[string]*models.App{
+		"app-1": {ID: appID, CompanyID: "company-1", WebhookURL: &webhookURL},
+		"app-2": {ID: otherAppID, CompanyID: "company-2"},
+	}}
+	webhooks := &fakeDeliveryLogRepository{webhooks: map[string]*models.Webhook{
+		"webhook-1": {ID: "webhook-1", AppID: &appID, TargetURL: "https://example.com/hooks", Status: models.WebhookStatusFailed},
+		"webhook-2": {ID: "webhook-2", AppID: &otherAppID},
+		"webhook-3": {ID: "webhook-3", AppID: &appID, TargetURL: "https://example.com/hooks", Status: models.WebhookStatusPending},
+	}}
+	return NewWebhookDeliveryService(apps, &fakeWebhookEndpointRepository{}, webhooks), webhooks
+}
+
+func TestWebhookDeliveryServiceList(t *testing.T) {
+	service, webhooks := newTestWebhookDeliveryService()
+
+	page, err := service.List(context.Background(), "company-1", "app-1", structs.ListWebhookDeliveriesRequest{
+		Status:    "failed",
+		Reference: "ref-1",
+		Page:      3,
+		PerPage:   20,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, page.Page)
+	assert.Equal(t, models.WebhookStatusFailed, webhooks.filter.Status)
+	assert.Equal(t, "ref-1", webhooks.filter.Reference)
+	assert.Equal(t, 40, webhooks.offset)
+	assert.Equal(t, 20, webhooks.limit)
+
+	page, err = service.List(context.Background(), "company-1", "app-1", structs.ListWebhookDeliveriesRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Page)
+	assert.Equal(t, defaultWebhookDeliveriesPerPage, page.PerPage)
+
+	_, err = service.List(context.Background(), "company-1", "app-2", structs.ListWebhookDeliveriesRequest{})
+	assert.ErrorIs(t, err, ErrAppNotFound)
+}
+
+func TestWebhookDeliveryServiceGet(t *testing.T) {
+	service, _ := newTestWebhookDeliveryService()
+
+	delivery, err := service.Get(context.Background(), "company-1", "app-1", "webhook-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "webhook-1", delivery.ID)
+	assert.Len(t, delivery.AttemptLog, 1)
+
+	_, err = service.Get(context.Background(), "company-1", "app-1", "webhook-2")
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestWebhookDeliveryServiceReplay(t *testing.T) {
+	service, webhooks := newTestWebhookDeliveryService()
+
+	assert.NoError(t, service.Replay(context.Background(), "company-1", "app-1", "webhook-1"))
+	assert.Equal(t, "https://example.com/fixed-hooks", webhooks.replayedTo)
+
+	assert.ErrorIs(t, service.Replay(context.Background(), "company-1", "app-1", "webhook-2"), ErrWebhookNotFound)
+
+	// A pending webhook may be in the hands of a worker already.
+	webhooks.replayedTo = ""
+	assert.ErrorIs(t, service.Replay(context.Background(), "company-1", "app-1", "webhook-3"), ErrWebhookPending)
+	assert.Empty(t, webhooks.replayedTo)
+}
+
+func TestWebhookDeliveryServiceReplayFailed(t *testing.T) {
+	service, webhooks := newTestWebhookDeliveryService()
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	replayed, err := service.ReplayFailed(context.Background(), "company-1", "app-1", structs.ReplayWebhooksRequest{From: from, To: from.AddDate(0, 0, 7)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), replayed)
+	assert.Equal(t, from, webhooks.window[0])
+
+	_, err = service.ReplayFailed(context.Background(), "company-1", "app-1", structs.ReplayWebhooksRequest{From: from, To: from.AddDate(0, 2, 0)})
+	assert.ErrorIs(t, err, ErrInvalidReplayWindow)
+}
+
+func TestWebhookDeliveryServiceReplayToEndpoint(t *testing.T) {
+	service, webhooks := newTestWebhookDeliveryService()
+	service.endpointRepo = &fakeWebhookEndpointRepository{endpoints: []*models.WebhookEndpoint{
+		{ID: "endpoint-1", AppID: "app-1", URL: "https://example.com/moved"},
+	}}
+	appID := "app-1"
+	live, deleted := "endpoint-1", "endpoint-2"
+	webhooks.webhooks["webhook-3"] = &models.Webhook{ID: "webhook-3", AppID: &appID, EndpointID: &live}
+	webhooks.webhooks["webhook-4"] = &models.Webhook{ID: "webhook-4", AppID: &appID, EndpointID: &deleted}
+
+	assert.NoError(t, service.Replay(context.Background(), "company-1", "app-1", "webhook-3"))
+	assert.Equal(t, "https://example.com/moved", webhooks.replayedTo)
+
+	assert.ErrorIs(t, service.Replay(context.Background(), "company-1", "app-1", "webhook-4"), ErrWebhookEndpointNotFound)
+}
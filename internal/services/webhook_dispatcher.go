@@ -0,0 +1,300 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/pkg/webhook"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookWorkers     = 8
+	defaultWebhookMaxPerHost  = 2
+	defaultWebhookMaxAttempts = 10
+	defaultWebhookTimeout     = 10 * time.Second
+
+	webhookPollInterval  = time.Second
+	webhookClaimLease    = 2 * time.Minute
+	webhookHostBusyDelay = 2 * time.Second
+	webhookBaseBackoff   = 30 * time.Second
+	webhookMaxBackoff    = 12 * time.Hour
+	webhookMaxErrorBytes = 1024
+
+	// webhookMaxResponseBytes is how much of a response body is kept in the
+	// delivery log.
+	webhookMaxResponseBytes = 4096
+)
+
+var errNoWebhookSecret = errors.New("the app has no webhook secret to sign with")
+
+// envInt reads a positive integer from the environment variable name.
+func envInt(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// envDuration reads a positive duration, e.g. "10s", from the environment
+// variable name.
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// WebhookDispatcher delivers the webhooks in the outbox with a pool of
+// workers. A delivery succeeds on any 2xx response. Failed deliveries are
+// retried with exponential backoff and jitter until MaxAttempts is reached,
+// when the webhook is marked failed. No more than MaxPerHost deliveries run
+// at once against the same host, so one slow integrator cannot hold up the
+// others. Every delivery is signed with the app's webhook secrets, see
+// package confam-api/pkg/webhook. It is configured by WEBHOOK_WORKERS,
+// WEBHOOK_MAX_PER_HOST, WEBHOOK_MAX_ATTEMPTS and WEBHOOK_TIMEOUT.
+type WebhookDispatcher struct {
+	webhookRepo       repositories.IWebhookRepository
+	webhookSecretRepo repositories.IWebhookSecretRepository
+	client            *http.Client
+
+	Workers     int
+	MaxPerHost  int
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func NewWebhookDispatcher(webhookRepo repositories.IWebhookRepository, webhookSecretRepo repositories.IWebhookSecretRepository) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookRepo:       webhookRepo,
+		webhookSecretRepo: webhookSecretRepo,
+		client:            &http.Client{Timeout: envDuration("WEBHOOK_TIMEOUT", defaultWebhookTimeout)},
+		Workers:           envInt("WEBHOOK_WORKERS", defaultWebhookWorkers),
+		MaxPerHost:        envInt("WEBHOOK_MAX_PER_HOST", defaultWebhookMaxPerHost),
+		MaxAttempts:       envInt("WEBHOOK_MAX_ATTEMPTS", defaultWebhookMaxAttempts),
+		BaseBackoff:       webhookBaseBackoff,
+		MaxBackoff:        webhookMaxBackoff,
+		inFlight:          map[string]int{},
+	}
+}
+
+// Run claims due webhooks and hands them to the workers until ctx is
+// cancelled. Webhooks claimed but not delivered by then are retried once
+// their claim runs out.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	jobs := make(chan models.Webhook)
+	var wg sync.WaitGroup
+	for i := 0; i < d.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for webhook := range jobs {
+				if err := d.Deliver(ctx, &webhook); err != nil {
+					log.Printf("Failed to record delivery of webhook %s: %v", webhook.ID, err)
+				}
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		webhooks, err := d.webhookRepo.ClaimDue(ctx, time.Now(), webhookClaimLease, d.Workers*2)
+		if err != nil {
+			log.Printf("Failed to claim due webhooks: %v", err)
+		}
+		for _, webhook := range webhooks {
+			select {
+			case jobs <- webhook:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// A full batch means more webhooks are likely due.
+		if err == nil && len(webhooks) == d.Workers*2 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver makes one delivery attempt and records its outcome. If the
+// webhook's host already has MaxPerHost deliveries running, the webhook is
+// put back for a moment instead, without counting an attempt.
+func (d *WebhookDispatcher) Deliver(ctx context.Context, webhook *models.Webhook) error {
+	host := webhookHost(webhook.TargetURL)
+	if !d.acquireHost(host) {
+		return d.webhookRepo.Reschedule(ctx, webhook.ID, time.Now().Add(webhookHostBusyDelay))
+	}
+	defer d.releaseHost(host)
+
+	attempt, err := d.post(ctx, webhook)
+
+	now := time.Now()
+	webhook.Attempts++
+	webhook.LastAttemptAt = &now
+	webhook.ResponseStatus = attempt.ResponseStatus
+	switch {
+	case err == nil:
+		webhook.Status = models.WebhookStatusSuccess
+		webhook.ErrorMessage = nil
+	case webhook.Attempts >= d.MaxAttempts:
+		message := err.Error()
+		webhook.Status = models.WebhookStatusFailed
+		webhook.ErrorMessage = &message
+	default:
+		message := err.Error()
+		webhook.NextAttemptAt = now.Add(d.Backoff(webhook.Attempts))
+		webhook.ErrorMessage = &message
+	}
+	attempt.ErrorMessage = webhook.ErrorMessage
+	return d.webhookRepo.RecordAttempt(ctx, webhook, attempt)
+}
+
+// post sends the webhook once and returns the attempt to log, with an error
+// unless the endpoint answered with a 2xx status.
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *models.Webhook) (*models.WebhookAttempt, error) {
+	attempt := &models.WebhookAttempt{TargetURL: delivery.TargetURL}
+
+	secrets, err := d.signingSecrets(ctx, delivery)
+	if err != nil {
+		return attempt, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.TargetURL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return attempt, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Confam-Webhooks/1.0")
+	req.Header.Set("X-Confam-Event", delivery.EventType)
+	req.Header.Set("X-Confam-Delivery", delivery.ID)
+	req.Header.Set(webhook.SignatureHeader, webhook.Header(time.Now().Unix(), []byte(delivery.Payload), secrets...))
+	if headers, err := json.Marshal(req.Header); err == nil {
+		attempt.RequestHeaders = string(headers)
+	}
+
+	started := time.Now()
+	resp, err := d.client.Do(req)
+	attempt.LatencyMS = time.Since(started).Milliseconds()
+	if err != nil {
+		return attempt, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseBytes))
+	io.Copy(io.Discard, resp.Body)
+	statusCode := resp.StatusCode
+	responseBody := string(body)
+	attempt.ResponseStatus = &statusCode
+	attempt.ResponseBody = &responseBody
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > webhookMaxErrorBytes {
+			body = body[:webhookMaxErrorBytes]
+		}
+		return attempt, fmt.Errorf("webhook endpoint responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return attempt, nil
+}
+
+// signingSecrets returns the secrets of the webhook's endpoint, or of its
+// app's webhook URL, that have not expired, newest first. Deliveries are
+// never sent unsigned.
+func (d *WebhookDispatcher) signingSecrets(ctx context.Context, delivery *models.Webhook) ([]string, error) {
+	if delivery.AppID == nil {
+		return nil, errNoWebhookSecret
+	}
+	secrets, err := activeWebhookSecrets(ctx, d.webhookSecretRepo, *delivery.AppID, delivery.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, errNoWebhookSecret
+	}
+	return secrets, nil
+}
+
+// activeWebhookSecrets decrypts the unexpired secrets of the app's webhook
+// endpoint, or of its webhook URL when endpointID is nil, newest first.
+func activeWebhookSecrets(ctx context.Context, repo repositories.IWebhookSecretRepository, appID string, endpointID *string) ([]string, error) {
+	stored, err := repo.ListActive(ctx, appID, endpointID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]string, 0, len(stored))
+	for _, secret := range stored {
+		plain, err := secret.Secret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt webhook secret %s: %w", secret.ID, err)
+		}
+		secrets = append(secrets, plain)
+	}
+	return secrets, nil
+}
+
+// Backoff is how long to wait before retrying a webhook that failed the
+// given number of attempts: BaseBackoff doubled for every attempt after the
+// first, capped at MaxBackoff, of which the second half is random jitter.
+func (d *WebhookDispatcher) Backoff(attempts int) time.Duration {
+	delay := d.MaxBackoff
+	if attempts < 32 {
+		if exp := d.BaseBackoff << (attempts - 1); exp > 0 && exp < d.MaxBackoff {
+			delay = exp
+		}
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func (d *WebhookDispatcher) acquireHost(host string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inFlight[host] >= d.MaxPerHost {
+		return false
+	}
+	d.inFlight[host]++
+	return true
+}
+
+func (d *WebhookDispatcher) releaseHost(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight[host]--
+	if d.inFlight[host] <= 0 {
+		delete(d.inFlight, host)
+	}
+}
+
+func webhookHost(targetURL string) string {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return targetURL
+	}
+	return strings.ToLower(u.Host)
+}
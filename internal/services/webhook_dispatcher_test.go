@@ -0,0 +1,193 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"confam-api/pkg/webhook"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOutboxRepository struct {
+	fakeWebhookRepository
+	recorded    []models.Webhook
+	attempts    []models.WebhookAttempt
+	rescheduled []string
+}
+
+func (r *fakeOutboxRepository) RecordAttempt(ctx context.Context, webhook *models.Webhook, attempt *models.WebhookAttempt) error {
+	r.recorded = append(r.recorded, *webhook)
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *fakeOutboxRepository) Reschedule(ctx context.Context, webhookID string, at time.Time) error {
+	r.rescheduled = append(r.rescheduled, webhookID)
+	return nil
+}
+
+type fakeWebhookSecretRepository struct {
+	repositories.IWebhookSecretRepository
+	secrets map[string][]models.WebhookSecret
+}
+
+func (r *fakeWebhookSecretRepository) ListActive(ctx context.Context, appID string, endpointID *string, now time.Time) ([]models.WebhookSecret, error) {
+	if endpointID != nil {
+		return r.secrets[*endpointID], nil
+	}
+	return r.secrets[appID], nil
+}
+
+const testAppID = "app-1"
+
+// newTestWebhookDispatcher returns a dispatcher whose app-1 signs with the
+// given secrets, newest first.
+func newTestWebhookDispatcher(t *testing.T, secrets ...string) (*WebhookDispatcher, *fakeOutboxRepository) {
+	os.Setenv("ENCRYPTION_KEY", "7c633361cb709e1cf6ef0d68c914b5a5b1b540034331ab64702d2fd980dc7585")
+	t.Cleanup(func() { os.Unsetenv("ENCRYPTION_KEY") })
+
+	stored := make([]models.WebhookSecret, 0, len(secrets))
+	for _, secret := range secrets {
+		s, err := models.NewWebhookSecret(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, *s)
+	}
+
+	repo := &fakeOutboxRepository{}
+	dispatcher := NewWebhookDispatcher(repo, &fakeWebhookSecretRepository{secrets: map[string][]models.WebhookSecret{testAppID: stored}})
+	dispatcher.MaxAttempts = 3
+	return dispatcher, repo
+}
+
+func TestWebhookDispatcherDeliver(t *testing.T) {
+	var body string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, header = string(b), r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher, repo := newTestWebhookDispatcher(t, "whsec_new", "whsec_old")
+	appID := testAppID
+	delivery, err := models.NewWebhook(&appID, models.EnvironmentSandbox, server.URL, RequestStatusChangedEvent, map[string]string{"id": "request-1"})
+	assert.NoError(t, err)
+	delivery.ID = "webhook-1"
+
+	assert.NoError(t, dispatcher.Deliver(context.Background(), delivery))
+	assert.JSONEq(t, `{"event":"kyc.status.changed","data":{"id":"request-1"}}`, body)
+	assert.Equal(t, RequestStatusChangedEvent, header.Get("X-Confam-Event"))
+	assert.Equal(t, "webhook-1", header.Get("X-Confam-Delivery"))
+
+	signature := header.Get(webhook.SignatureHeader)
+	assert.NoError(t, webhook.Verify(signature, []byte(body), []string{"whsec_new"}, webhook.DefaultTolerance))
+	assert.NoError(t, webhook.Verify(signature, []byte(body), []string{"whsec_old"}, webhook.DefaultTolerance))
+	assert.ErrorIs(t, webhook.Verify(signature, []byte(body), []string{"whsec_other"}, webhook.DefaultTolerance), webhook.ErrSignatureMismatch)
+
+	assert.Len(t, repo.recorded, 1)
+	assert.Equal(t, models.WebhookStatusSuccess, repo.recorded[0].Status)
+	assert.Equal(t, 1, repo.recorded[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, *repo.recorded[0].ResponseStatus)
+	assert.Nil(t, repo.recorded[0].ErrorMessage)
+
+	assert.Len(t, repo.attempts, 1)
+	assert.Equal(t, server.URL, repo.attempts[0].TargetURL)
+	assert.Contains(t, repo.attempts[0].RequestHeaders, signature)
+	assert.Equal(t, http.StatusNoContent, *repo.attempts[0].ResponseStatus)
+	assert.GreaterOrEqual(t, repo.attempts[0].LatencyMS, int64(0))
+}
+
+func TestWebhookDispatcherRetriesUntilMaxAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dispatcher, repo := newTestWebhookDispatcher(t, "whsec_test")
+	appID := testAppID
+	webhook := &models.Webhook{ID: "webhook-1", AppID: &appID, TargetURL: server.URL, Status: models.WebhookStatusPending}
+
+	for i := 1; i < dispatcher.MaxAttempts; i++ {
+		before := time.Now()
+		assert.NoError(t, dispatcher.Deliver(context.Background(), webhook))
+		assert.Equal(t, models.WebhookStatusPending, webhook.Status)
+		assert.Equal(t, i, webhook.Attempts)
+		assert.True(t, webhook.NextAttemptAt.After(before))
+		assert.Contains(t, *webhook.ErrorMessage, "unavailable")
+	}
+
+	assert.NoError(t, dispatcher.Deliver(context.Background(), webhook))
+	assert.Equal(t, models.WebhookStatusFailed, webhook.Status)
+	assert.Equal(t, dispatcher.MaxAttempts, webhook.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, *webhook.ResponseStatus)
+	assert.Len(t, repo.recorded, dispatcher.MaxAttempts)
+	assert.Len(t, repo.attempts, dispatcher.MaxAttempts)
+	assert.Equal(t, "unavailable\n", *repo.attempts[0].ResponseBody)
+	assert.Contains(t, *repo.attempts[0].ErrorMessage, "503")
+}
+
+func TestWebhookDispatcherNeverSendsUnsigned(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	dispatcher, repo := newTestWebhookDispatcher(t)
+	appID := testAppID
+	delivery := &models.Webhook{ID: "webhook-1", AppID: &appID, TargetURL: server.URL, Status: models.WebhookStatusPending}
+
+	assert.NoError(t, dispatcher.Deliver(context.Background(), delivery))
+	assert.False(t, called)
+	assert.Equal(t, models.WebhookStatusPending, repo.recorded[0].Status)
+	assert.Equal(t, errNoWebhookSecret.Error(), *repo.recorded[0].ErrorMessage)
+}
+
+func TestWebhookDispatcherLimitsDeliveriesPerHost(t *testing.T) {
+	dispatcher, repo := newTestWebhookDispatcher(t)
+	dispatcher.MaxPerHost = 1
+	webhook := &models.Webhook{ID: "webhook-1", TargetURL: "https://Example.com/hooks", Status: models.WebhookStatusPending}
+
+	assert.True(t, dispatcher.acquireHost("example.com"))
+	assert.NoError(t, dispatcher.Deliver(context.Background(), webhook))
+	assert.Equal(t, []string{"webhook-1"}, repo.rescheduled)
+	assert.Empty(t, repo.recorded)
+	assert.Equal(t, 0, webhook.Attempts)
+
+	dispatcher.releaseHost("example.com")
+	assert.True(t, dispatcher.acquireHost("example.com"))
+}
+
+func TestWebhookDispatcherBackoff(t *testing.T) {
+	dispatcher, _ := newTestWebhookDispatcher(t)
+	dispatcher.BaseBackoff = 10 * time.Second
+	dispatcher.MaxBackoff = time.Hour
+
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			delay := dispatcher.Backoff(tt.attempts)
+			assert.GreaterOrEqual(t, delay, tt.max/2, "attempts %d", tt.attempts)
+			assert.LessOrEqual(t, delay, tt.max, "attempts %d", tt.attempts)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookTestEvent is the event sent to an endpoint to try it out. Endpoints
+// cannot subscribe to it.
+const WebhookTestEvent = "webhook.test"
+
+// WebhookEventTypes are the events webhook endpoints can subscribe to.
+var WebhookEventTypes = []string{
+	RequestInitiatedEvent,
+	RequestStatusChangedEvent,
+	IdentityVerifiedEvent,
+	ReviewRequiredEvent,
+	RequestCompletedEvent,
+	RequestFailedEvent,
+	PermissionExpiredEvent,
+	PermissionRevokedEvent,
+}
+
+var (
+	ErrWebhookEndpointNotFound = errors.New("Webhook endpoint not found")
+	ErrUnknownWebhookEvent     = errors.New("Unknown webhook event")
+)
+
+type IWebhookEndpointService interface {
+	List(ctx context.Context, companyID, appID string) ([]models.WebhookEndpoint, error)
+	Get(ctx context.Context, companyID, appID, id string) (*models.WebhookEndpoint, error)
+	Create(ctx context.Context, companyID, appID string, req structs.CreateWebhookEndpointRequest) (*structs.WebhookEndpointWithSecret, error)
+	Update(ctx context.Context, companyID, appID, id string, req structs.UpdateWebhookEndpointRequest) (*models.WebhookEndpoint, error)
+	Delete(ctx context.Context, companyID, appID, id string) error
+	SendTestEvent(ctx context.Context, companyID, appID, id string) (*models.Webhook, error)
+	ListSecrets(ctx context.Context, companyID, appID, id string) ([]structs.WebhookSecret, error)
+	RotateSecret(ctx context.Context, companyID, appID, id string, req structs.RotateWebhookSecretRequest) (*structs.RotatedWebhookSecret, error)
+}
+
+// WebhookEndpointService manages the webhook endpoints of a company's apps.
+// Each endpoint receives the events it subscribed to, signed with secrets of
+// its own, on top of the app's webhook URL receiving every event.
+type WebhookEndpointService struct {
+	appRepo           repositories.IAppRepository
+	endpointRepo      repositories.IWebhookEndpointRepository
+	webhookRepo       repositories.IWebhookRepository
+	webhookSecretRepo repositories.IWebhookSecretRepository
+}
+
+func NewWebhookEndpointService(
+	appRepo repositories.IAppRepository,
+	endpointRepo repositories.IWebhookEndpointRepository,
+	webhookRepo repositories.IWebhookRepository,
+	webhookSecretRepo repositories.IWebhookSecretRepository,
+) *WebhookEndpointService {
+	return &WebhookEndpointService{
+		appRepo:           appRepo,
+		endpointRepo:      endpointRepo,
+		webhookRepo:       webhookRepo,
+		webhookSecretRepo: webhookSecretRepo,
+	}
+}
+
+// webhookEvents checks the events an endpoint subscribes to, dropping duplicates.
+func webhookEvents(events []string) (models.WebhookEventTypes, error) {
+	known := make(map[string]bool, len(WebhookEventTypes))
+	for _, event := range WebhookEventTypes {
+		known[event] = true
+	}
+
+	subscribed := models.WebhookEventTypes{}
+	for _, event := range events {
+		if !known[event] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownWebhookEvent, event)
+		}
+		if !subscribed.Has(event) {
+			subscribed = append(subscribed, event)
+		}
+	}
+	return subscribed, nil
+}
+
+func (s *WebhookEndpointService) List(ctx context.Context, companyID, appID string) ([]models.WebhookEndpoint, error) {
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := s.endpointRepo.List(ctx, app.ID)
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return endpoints, nil
+}
+
+func (s *WebhookEndpointService) Get(ctx context.Context, companyID, appID, id string) (*models.WebhookEndpoint, error) {
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.endpointRepo.Find(ctx, app.ID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEndpointNotFound
+		}
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	return endpoint, nil
+}
+
+// Create adds an endpoint to the app, enabled unless the request says
+// otherwise, and returns it with the secret its webhooks are signed with.
+func (s *WebhookEndpointService) Create(ctx context.Context, companyID, appID string, req structs.CreateWebhookEndpointRequest) (*structs.WebhookEndpointWithSecret, error) {
+	events, err := webhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	app, err := findCompanyApp(ctx, s.appRepo, companyID, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := &models.WebhookEndpoint{
+		AppID:       app.ID,
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      events,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+	}
+	if err := s.endpointRepo.Create(ctx, endpoint, secret); err != nil {
+		log.Printf("Failed to create webhook endpoint for app %s: %v", app.ID, err)
+		return nil, errors.New("Could not create webhook endpoint. Please try again later.")
+	}
+	return &structs.WebhookEndpointWithSecret{WebhookEndpoint: endpoint, Secret: plain}, nil
+}
+
+// Update applies the fields present in req. Webhooks already waiting to be
+// delivered keep going to the URL they were created for.
+func (s *WebhookEndpointService) Update(ctx context.Context, companyID, appID, id string, req structs.UpdateWebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.Get(ctx, companyID, appID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		endpoint.URL = *req.URL
+	}
+	if req.Description != nil {
+		endpoint.Description = req.Description
+	}
+	if req.Events != nil {
+		events, err := webhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		endpoint.Events = events
+	}
+	if req.Enabled != nil {
+		endpoint.Enabled = *req.Enabled
+	}
+
+	if err := s.endpointRepo.Update(ctx, endpoint); err != nil {
+		return nil, errors.New("Could not update webhook endpoint. Please try again later.")
+	}
+	return endpoint, nil
+}
+
+// Delete removes the endpoint. Webhooks still waiting to be delivered to it
+// are failed; those already sent stay in the delivery log.
+func (s *WebhookEndpointService) Delete(ctx context.Context, companyID, appID, id string) error {
+	endpoint, err := s.Get(ctx, companyID, appID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.endpointRepo.Delete(ctx, endpoint); err != nil {
+		log.Printf("Failed to delete webhook endpoint %s: %v", endpoint.ID, err)
+		return errors.New("Could not delete webhook endpoint. Please try again later.")
+	}
+	return nil
+}
+
+// SendTestEvent queues a WebhookTestEvent for the endpoint, even a disabled
+// one, and returns the webhook so its delivery can be followed in the log.
+func (s *WebhookEndpointService) SendTestEvent(ctx context.Context, companyID, appID, id string) (*models.Webhook, error) {
+	endpoint, err := s.Get(ctx, companyID, appID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := models.NewWebhook(&endpoint.AppID, models.EnvironmentSandbox, endpoint.URL, WebhookTestEvent, map[string]interface{}{
+		"app":         endpoint.AppID,
+		"business":    companyID,
+		"endpoint":    endpoint.ID,
+		"message":     "This is a test event from Confam.",
+		"occurred_at": time.Now(),
+	})
+	if err != nil {
+		return nil, errors.New("An internal server error occurred. Please try again later")
+	}
+	webhook.EndpointID = &endpoint.ID
+
+	webhooks := []models.Webhook{*webhook}
+	if err := s.webhookRepo.Create(ctx, webhooks); err != nil {
+		log.Printf("Failed to queue test event for webhook endpoint %s: %v", endpoint.ID, err)
+		return nil, errors.New("Could not send test event. Please try again later.")
+	}
+	return &webhooks[0], nil
+}
+
+// ListSecrets returns the secrets the endpoint's webhooks are currently
+// signed with, newest first.
+func (s *WebhookEndpointService) ListSecrets(ctx context.Context, companyID, appID, id string) ([]structs.WebhookSecret, error) {
+	endpoint, err := s.Get(ctx, companyID, appID, id)
+	if err != nil {
+		return nil, err
+	}
+	return listWebhookSecrets(ctx, s.webhookSecretRepo, endpoint.AppID, &endpoint.ID)
+}
+
+// RotateSecret issues a new secret for the endpoint's webhooks.
+func (s *WebhookEndpointService) RotateSecret(ctx context.Context, companyID, appID, id string, req structs.RotateWebhookSecretRequest) (*structs.RotatedWebhookSecret, error) {
+	endpoint, err := s.Get(ctx, companyID, appID, id)
+	if err != nil {
+		return nil, err
+	}
+	return rotateWebhookSecret(ctx, s.webhookSecretRepo, endpoint.AppID, &endpoint.ID, req)
+}
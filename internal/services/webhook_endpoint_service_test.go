@@ -0,0 +1,177 @@
+package services
+
+import (
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	structs "confam-api/internal/structs"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type fakeWebhookEndpointRepository struct {
+	repositories.IWebhookEndpointRepository
+	endpoints []*models.WebhookEndpoint
+	secrets   []*models.WebhookSecret
+}
+
+func (r *fakeWebhookEndpointRepository) Create(ctx context.Context, endpoint *models.WebhookEndpoint, secret *models.WebhookSecret) error {
+	endpoint.ID = "endpoint-new"
+	secret.AppID, secret.EndpointID = endpoint.AppID, &endpoint.ID
+	r.endpoints = append(r.endpoints, endpoint)
+	r.secrets = append(r.secrets, secret)
+	return nil
+}
+
+func (r *fakeWebhookEndpointRepository) List(ctx context.Context, appID string) ([]models.WebhookEndpoint, error) {
+	endpoints := []models.WebhookEndpoint{}
+	for _, endpoint := range r.endpoints {
+		if endpoint.AppID == appID {
+			endpoints = append(endpoints, *endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+func (r *fakeWebhookEndpointRepository) Find(ctx context.Context, appID, id string) (*models.WebhookEndpoint, error) {
+	for _, endpoint := range r.endpoints {
+		if endpoint.AppID == appID && endpoint.ID == id {
+			return endpoint, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeWebhookEndpointRepository) Update(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return nil
+}
+
+func newTestWebhookEndpointService() (*WebhookEndpointService, *fakeWebhookEndpointRepository, *fakeWebhookRepository) {
+	apps := &fakeAppRepository{apps: map[string]*models.App{
+		"app-1": {ID: "app-1", CompanyID: "company-1"},
+		"app-2": {ID: "app-2", CompanyID: "company-2"},
+	}}
+	endpoints := &fakeWebhookEndpointRepository{endpoints: []*models.WebhookEndpoint{
+		{ID: "endpoint-1", AppID: "app-1", URL: "https://example.com/kyc", Events: models.WebhookEventTypes{RequestCompletedEvent}, Enabled: true},
+		{ID: "endpoint-2", AppID: "app-2", URL: "https://example.org/hooks", Events: models.WebhookEventTypes{RequestCompletedEvent}, Enabled: true},
+	}}
+	webhooks := &fakeWebhookRepository{}
+	return NewWebhookEndpointService(apps, endpoints, webhooks, nil), endpoints, webhooks
+}
+
+func TestWebhookEndpointServiceCreate(t *testing.T) {
+	os.Setenv("ENCRYPTION_KEY", "7c633361cb709e1cf6ef0d68c914b5a5b1b540034331ab64702d2fd980dc7585")
+	defer os.Unsetenv("ENCRYPTION_KEY")
+	service, endpoints, _ := newTestWebhookEndpointService()
+
+	created, err := service.Create(context.Background(), "company-1", "app-1", structs.CreateWebhookEndpointRequest{
+		URL:    "https://example.com/revocations",
+		Events: []string{PermissionRevokedEvent, PermissionRevokedEvent, RequestFailedEvent},
+	})
+	assert.NoError(t, err)
+	assert.True(t, created.Enabled)
+	assert.Equal(t, models.WebhookEventTypes{PermissionRevokedEvent, RequestFailedEvent}, created.Events)
+	assert.NotEmpty(t, created.Secret)
+
+	secret := endpoints.secrets[0]
+	assert.Equal(t, "app-1", secret.AppID)
+	assert.Equal(t, created.ID, *secret.EndpointID)
+	plain, err := secret.Secret()
+	assert.NoError(t, err)
+	assert.Equal(t, created.Secret, plain)
+
+	_, err = service.Create(context.Background(), "company-1", "app-1", structs.CreateWebhookEndpointRequest{
+		URL:    "https://example.com/hooks",
+		Events: []string{WebhookTestEvent},
+	})
+	assert.ErrorIs(t, err, ErrUnknownWebhookEvent)
+
+	_, err = service.Create(context.Background(), "company-1", "app-2", structs.CreateWebhookEndpointRequest{
+		URL:    "https://example.com/hooks",
+		Events: []string{RequestCompletedEvent},
+	})
+	assert.ErrorIs(t, err, ErrAppNotFound)
+}
+
+func TestWebhookEndpointServiceUpdate(t *testing.T) {
+	service, _, _ := newTestWebhookEndpointService()
+	disabled := false
+
+	endpoint, err := service.Update(context.Background(), "company-1", "app-1", "endpoint-1", structs.UpdateWebhookEndpointRequest{Enabled: &disabled})
+	assert.NoError(t, err)
+	assert.False(t, endpoint.Enabled)
+	assert.Equal(t, "https://example.com/kyc", endpoint.URL)
+	assert.Equal(t, models.WebhookEventTypes{RequestCompletedEvent}, endpoint.Events)
+
+	_, err = service.Update(context.Background(), "company-1", "app-1", "endpoint-2", structs.UpdateWebhookEndpointRequest{Enabled: &disabled})
+	assert.ErrorIs(t, err, ErrWebhookEndpointNotFound)
+}
+
+func TestWebhookEndpointServiceSendTestEvent(t *testing.T) {
+	service, endpoints, webhooks := newTestWebhookEndpointService()
+	endpoints.endpoints[0].Enabled = false
+
+	webhook, err := service.SendTestEvent(context.Background(), "company-1", "app-1", "endpoint-1")
+	assert.NoError(t, err)
+	assert.Len(t, webhooks.webhooks, 1)
+	assert.Equal(t, WebhookTestEvent, webhook.EventType)
+	assert.Equal(t, "https://example.com/kyc", webhook.TargetURL)
+	assert.Equal(t, "endpoint-1", *webhook.EndpointID)
+}
+
+func TestWebhookServiceFansOutToSubscribedEndpoints(t *testing.T) {
+	webhookURL := "https://example.com/all"
+	apps := &fakeAppRepository{apps: map[string]*models.App{
+		"app-1": {ID: "app-1", CompanyID: "company-1", WebhookURL: &webhookURL},
+	}}
+	endpoints := &fakeWebhookEndpointRepository{endpoints: []*models.WebhookEndpoint{
+		{ID: "endpoint-1", AppID: "app-1", URL: "https://example.com/completed", Events: models.WebhookEventTypes{RequestCompletedEvent}, Enabled: true},
+		{ID: "endpoint-2", AppID: "app-1", URL: "https://example.com/failed", Events: models.WebhookEventTypes{RequestFailedEvent}, Enabled: true},
+		{ID: "endpoint-3", AppID: "app-1", URL: "https://example.com/disabled", Events: models.WebhookEventTypes{RequestCompletedEvent}, Enabled: false},
+	}}
+	service := NewWebhookService(apps, endpoints, &fakeWebhookRepository{})
+	appID := "app-1"
+
+	webhooks, err := service.Build(context.Background(), WebhookEvent{
+		CompanyID:   "company-1",
+		AppID:       &appID,
+		Environment: models.EnvironmentSandbox,
+		Type:        RequestCompletedEvent,
+		Reference:   "ref-1",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 2)
+	assert.Equal(t, webhookURL, webhooks[0].TargetURL)
+	assert.Nil(t, webhooks[0].EndpointID)
+	assert.Equal(t, "https://example.com/completed", webhooks[1].TargetURL)
+	assert.Equal(t, "endpoint-1", *webhooks[1].EndpointID)
+	assert.Equal(t, "ref-1", *webhooks[1].Reference)
+}
+
+type failingAppRepository struct {
+	repositories.IAppRepository
+	err error
+}
+
+func (r *failingAppRepository) FindCompanyApp(ctx context.Context, companyID, id string) (*models.App, error) {
+	return nil, r.err
+}
+
+func TestWebhookServiceBuildOnlySkipsMissingApps(t *testing.T) {
+	appID := "app-1"
+	event := WebhookEvent{CompanyID: "company-1", AppID: &appID, Type: RequestCompletedEvent}
+
+	service := NewWebhookService(&failingAppRepository{err: gorm.ErrRecordNotFound}, &fakeWebhookEndpointRepository{}, &fakeWebhookRepository{})
+	webhooks, err := service.Build(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Empty(t, webhooks)
+
+	lost := errors.New("connection lost")
+	service = NewWebhookService(&failingAppRepository{err: lost}, &fakeWebhookEndpointRepository{}, &fakeWebhookRepository{})
+	_, err = service.Build(context.Background(), event)
+	assert.ErrorIs(t, err, lost)
+}
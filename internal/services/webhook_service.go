@@ -1,44 +1,108 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	models "confam-api/internal/models"
+	repositories "confam-api/internal/repositories"
+	"context"
+	"errors"
 	"log"
-	"net/http"
+
+	"gorm.io/gorm"
 )
 
-type IWebhookService interface {
-	SendWebhook(webhookURL, event string, data any)
+// WebhookEvent is something that happened that the app it concerns is told
+// about.
+type WebhookEvent struct {
+	CompanyID   string
+	AppID       *string
+	Environment models.Environment
+	Type        string
+	Reference   string // the reference of the request the event is about, if any
+	Data        any
 }
 
-// WebhookService is responsible for sending webhooks.
-type WebhookService struct{}
+type IWebhookService interface {
+	Build(ctx context.Context, event WebhookEvent) ([]models.Webhook, error)
+	Send(ctx context.Context, event WebhookEvent) error
+}
 
-func NewWebhookService() *WebhookService {
-	return &WebhookService{}
+// WebhookService turns events into webhooks for the app they concern: one
+// for the app's webhook URL, which receives every event, and one for each of
+// its enabled endpoints subscribed to the event. Nothing is sent straight
+// away: webhooks are stored in the outbox, ideally in the same transaction as
+// the change they announce, and WebhookDispatcher delivers them signed with
+// the app's webhook secrets.
+type WebhookService struct {
+	appRepo      repositories.IAppRepository
+	endpointRepo repositories.IWebhookEndpointRepository
+	webhookRepo  repositories.IWebhookRepository
 }
 
-// SendWebhook sends a POST request with the given event and data to the specified URL.
-// It is designed to be non-blocking when called with 'go'.
-func (s *WebhookService) SendWebhook(webhookURL, event string, data any) {
-	payload := map[string]any{
-		"event": event,
-		"data":  data,
+func NewWebhookService(
+	appRepo repositories.IAppRepository,
+	endpointRepo repositories.IWebhookEndpointRepository,
+	webhookRepo repositories.IWebhookRepository,
+) *WebhookService {
+	return &WebhookService{
+		appRepo:      appRepo,
+		endpointRepo: endpointRepo,
+		webhookRepo:  webhookRepo,
 	}
+}
 
-	// Marshal to JSON
-	b, err := json.Marshal(payload)
+// Build returns the webhooks to store for the event, none when there is no
+// app, the app no longer exists or there is nowhere to send the event to.
+func (s *WebhookService) Build(ctx context.Context, event WebhookEvent) ([]models.Webhook, error) {
+	if event.AppID == nil {
+		return nil, nil
+	}
+	app, err := s.appRepo.FindCompanyApp(ctx, event.CompanyID, *event.AppID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Failed to find app %s to notify: %v", *event.AppID, err)
+		return nil, nil
+	}
 	if err != nil {
-		log.Println("Failed to marshal webhook payload:", err)
-		return
+		return nil, err
 	}
+	endpoints, err := s.endpointRepo.List(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhooks []models.Webhook
+	add := func(endpointID *string, targetURL string) error {
+		webhook, err := models.NewWebhook(event.AppID, event.Environment, targetURL, event.Type, event.Data)
+		if err != nil {
+			return err
+		}
+		webhook.EndpointID = endpointID
+		if event.Reference != "" {
+			webhook.Reference = &event.Reference
+		}
+		webhooks = append(webhooks, *webhook)
+		return nil
+	}
+	if app.WebhookURL != nil && *app.WebhookURL != "" {
+		if err := add(nil, *app.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+	for i := range endpoints {
+		if endpoints[i].Subscribes(event.Type) {
+			if err := add(&endpoints[i].ID, endpoints[i].URL); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return webhooks, nil
+}
 
-	// Send POST request
-	response, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(b))
+// Send stores the webhooks for an event that is not part of a state change
+// of its own.
+func (s *WebhookService) Send(ctx context.Context, event WebhookEvent) error {
+	webhooks, err := s.Build(ctx, event)
 	if err != nil {
-		log.Println("Failed to send webhook:", err)
-		return
+		return err
 	}
-	defer response.Body.Close()
-	log.Println("Webhook sent, status:", response.Status)
+	return s.webhookRepo.Create(ctx, webhooks)
 }
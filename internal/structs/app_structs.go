@@ -0,0 +1,66 @@
+package structs
+
+import (
+	models "confam-api/internal/models"
+	"time"
+)
+
+type CreateAppRequest struct {
+	Name        string  `json:"name" binding:"required,min=2,max=255"`
+	DisplayName string  `json:"display_name" binding:"required,min=2,max=255"`
+	Logo        *string `json:"logo" binding:"omitempty,url,max=255"`
+	WebhookURL  *string `json:"webhook_url" binding:"omitempty,url,max=255"`
+	Mode        string  `json:"mode" binding:"omitempty,oneof=SANDBOX LIVE"`
+}
+
+// UpdateAppRequest changes only the fields that are present in the request body.
+type UpdateAppRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,min=2,max=255"`
+	Logo        *string `json:"logo" binding:"omitempty,url,max=255"`
+	WebhookURL  *string `json:"webhook_url" binding:"omitempty,url,max=255"`
+	Mode        *string `json:"mode" binding:"omitempty,oneof=SANDBOX LIVE"`
+	Status      *string `json:"status" binding:"omitempty,oneof=ACTIVE INACTIVE"`
+}
+
+// AppWithKeys is returned when an app is created, the only time its secret
+// keys are shown. LiveSecretKey is left empty for roles that may not view live
+// keys and WebhookSecret for roles that may not manage webhooks.
+type AppWithKeys struct {
+	*models.App
+	TestSecretKey string `json:"test_secret_key"`
+	LiveSecretKey string `json:"live_secret_key,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+type RotateKeysRequest struct {
+	Environment        string `json:"environment" binding:"required,oneof=test live"`
+	GracePeriodMinutes *int   `json:"grace_period_minutes" binding:"omitempty,min=0,max=10080"`
+}
+
+// RotatedKeys is the new key pair for one environment. The previous secret
+// keeps working until PreviousKeyExpiresAt.
+type RotatedKeys struct {
+	Environment          string    `json:"environment"`
+	PublicKey            string    `json:"public_key"`
+	SecretKey            string    `json:"secret_key"`
+	PreviousKeyExpiresAt time.Time `json:"previous_key_expires_at"`
+}
+
+type RotateWebhookSecretRequest struct {
+	GracePeriodMinutes *int `json:"grace_period_minutes" binding:"omitempty,min=0,max=10080"`
+}
+
+// WebhookSecret is a secret the app's webhooks are currently signed with.
+type WebhookSecret struct {
+	ID        string     `json:"id"`
+	Secret    string     `json:"secret"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RotatedWebhookSecret is the app's new webhook secret. Webhooks are signed
+// with both it and the previous secret until PreviousSecretExpiresAt.
+type RotatedWebhookSecret struct {
+	Secret                  string    `json:"secret"`
+	PreviousSecretExpiresAt time.Time `json:"previous_secret_expires_at"`
+}
@@ -1,6 +1,7 @@
 package structs
 
 type RegisterRequest struct {
+	CompanyName     string `json:"company_name" binding:"required,min=2,max=255"`
 	FirstName       string `json:"firstname" binding:"required,min=2,max=50"`
 	LastName        string `json:"lastname" binding:"required,min=2,max=50"`
 	Email           string `json:"email" binding:"required,email"`
@@ -25,8 +26,56 @@ type PasswordResetRequest struct {
 	ConfirmPassword string `json:"confirm_password" binding:"required"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `form:"token" binding:"required"`
+}
+
 type ChangePasswordRequest struct {
 	OldPassword     string `json:"old_password" binding:"required"`
 	NewPassword     string `json:"new_password" binding:"required,min=8,max=100"`
 	ConfirmPassword string `json:"confirm_password" binding:"required"`
 }
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ClientInfo describes the client a session is created for.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// AuthTokens is the result of a login. When the user has two-factor
+// authentication enabled only MFARequired and MFAToken are set, and the
+// token pair is issued once the challenge is completed.
+type AuthTokens struct {
+	AccessToken  string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type LoginTwoFactorRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorSetupRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type EnableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
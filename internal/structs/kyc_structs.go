@@ -16,4 +16,31 @@ type KycRequestInput struct {
 	RedirectURL  string        `json:"redirect_url"`
 	KYCLevel     string        `json:"kyc_level"`
 	BankAccounts bool          `json:"bank_accounts"`
+	Scopes       []string      `json:"scopes" binding:"omitempty,dive,oneof=identity phone address bank_accounts"`
+	AccessType   string        `json:"access_type" binding:"omitempty,oneof=temporary permanent"`
+	AccessDays   int           `json:"access_days" binding:"omitempty,min=1,max=365"`
+}
+
+type SendOTPRequest struct {
+	Channel string `json:"channel" binding:"omitempty,oneof=sms email"`
+}
+
+type VerifyOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type FailKycRequest struct {
+	Reason string `json:"reason" binding:"omitempty,max=191"`
+}
+
+type PortalLoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// PortalVerifyLoginRequest holds either the token of a magic link or the
+// email and code sent with it.
+type PortalVerifyLoginRequest struct {
+	Token string `json:"token" binding:"required_without=Code"`
+	Email string `json:"email" binding:"required_with=Code,omitempty,email"`
+	Code  string `json:"code" binding:"required_without=Token,omitempty,len=6,numeric"`
 }
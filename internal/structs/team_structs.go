@@ -0,0 +1,18 @@
+package structs
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=admin developer reviewer viewer"`
+}
+
+type AcceptInvitationRequest struct {
+	Token           string `json:"token" binding:"required"`
+	FirstName       string `json:"firstname" binding:"required,min=2,max=50"`
+	LastName        string `json:"lastname" binding:"required,min=2,max=50"`
+	Password        string `json:"password" binding:"required,min=8,max=100"`
+	ConfirmPassword string `json:"confirm_password" binding:"required"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin developer reviewer viewer"`
+}
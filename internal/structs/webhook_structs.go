@@ -0,0 +1,64 @@
+package structs
+
+import (
+	models "confam-api/internal/models"
+	"time"
+)
+
+// ListWebhookDeliveriesRequest filters the delivery log. From and To are
+// RFC 3339 times bounding when the webhooks were created.
+type ListWebhookDeliveriesRequest struct {
+	Event     string     `form:"event" binding:"omitempty,max=100"`
+	Status    string     `form:"status" binding:"omitempty,oneof=pending success failed"`
+	Reference string     `form:"reference" binding:"omitempty,max=255"`
+	From      *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page      int        `form:"page" binding:"omitempty,min=1"`
+	PerPage   int        `form:"per_page" binding:"omitempty,min=1,max=100"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []models.Webhook `json:"deliveries"`
+	Page       int              `json:"page"`
+	PerPage    int              `json:"per_page"`
+	Total      int64            `json:"total"`
+}
+
+// WebhookDelivery is a webhook with every attempt made at delivering it,
+// oldest first.
+type WebhookDelivery struct {
+	*models.Webhook
+	AttemptLog []models.WebhookAttempt `json:"attempt_log"`
+}
+
+// ReplayWebhooksRequest replays the failed webhooks created in [From, To).
+type ReplayWebhooksRequest struct {
+	From time.Time `json:"from" binding:"required"`
+	To   time.Time `json:"to" binding:"required,gtfield=From"`
+}
+
+type ReplayedWebhooks struct {
+	Replayed int64 `json:"replayed"`
+}
+
+type CreateWebhookEndpointRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=255"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Events      []string `json:"events" binding:"required,min=1,dive,required,max=100"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// UpdateWebhookEndpointRequest changes only the fields that are present in the request body.
+type UpdateWebhookEndpointRequest struct {
+	URL         *string  `json:"url" binding:"omitempty,url,max=255"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Events      []string `json:"events" binding:"omitempty,min=1,dive,required,max=100"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// WebhookEndpointWithSecret is returned when an endpoint is created, with
+// the secret its webhooks are signed with.
+type WebhookEndpointWithSecret struct {
+	*models.WebhookEndpoint
+	Secret string `json:"secret"`
+}
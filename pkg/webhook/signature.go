@@ -0,0 +1,154 @@
+// Package webhook verifies the signature Confam puts on every webhook it
+// delivers, so receivers can tell a request really came from Confam and was
+// not replayed.
+//
+// Each delivery carries an X-Confam-Signature header of the form
+//
+//	t=1700000000,v1=02277cd5d4bc22b04a1abe3a2d56a1dd8f7ee693c8e60cd2773c32661cf9fe30
+//
+// where t is the Unix time the delivery was signed and each v1 is the hex
+// HMAC-SHA256 of
+//
+//	<t>.<raw request body>
+//
+// keyed with one of the app's webhook secrets. While a secret is being
+// rotated the header carries a v1 signature for the old and the new secret,
+// so a receiver configured with either keeps verifying deliveries.
+//
+// A typical receiver:
+//
+//	func handle(w http.ResponseWriter, r *http.Request) {
+//		body, err := webhook.VerifyRequest(r, []string{os.Getenv("CONFAM_WEBHOOK_SECRET")}, webhook.DefaultTolerance)
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusBadRequest)
+//			return
+//		}
+//		// ... handle body, ignoring X-Confam-Delivery IDs already processed
+//	}
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header the signature is sent in.
+const SignatureHeader = "X-Confam-Signature"
+
+// DefaultTolerance is how old a signature may be before Verify rejects it as
+// a possible replay.
+const DefaultTolerance = 5 * time.Minute
+
+const signatureScheme = "v1"
+
+var (
+	ErrInvalidHeader     = errors.New("webhook: invalid signature header")
+	ErrNoSignature       = errors.New("webhook: no v1 signature in header")
+	ErrTimestampExpired  = errors.New("webhook: signature timestamp outside the tolerance")
+	ErrSignatureMismatch = errors.New("webhook: no signature matches the payload")
+)
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Header builds the X-Confam-Signature value for body signed at timestamp,
+// with one v1 signature per secret.
+func Header(timestamp int64, body []byte, secrets ...string) string {
+	parts := make([]string, 0, len(secrets)+1)
+	parts = append(parts, "t="+strconv.FormatInt(timestamp, 10))
+	for _, secret := range secrets {
+		parts = append(parts, signatureScheme+"="+Sign(secret, timestamp, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify checks the X-Confam-Signature header of a delivery against its raw
+// body. It succeeds when any v1 signature matches any of the secrets and the
+// timestamp is no further than tolerance from the current time; a tolerance
+// of zero or less disables the timestamp check.
+func Verify(header string, body []byte, secrets []string, tolerance time.Duration) error {
+	return VerifyAt(header, body, secrets, tolerance, time.Now())
+}
+
+// VerifyAt is Verify with the current time given as now.
+func VerifyAt(header string, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+	timestamp, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrTimestampExpired
+		}
+	}
+
+	for _, secret := range secrets {
+		expected := []byte(Sign(secret, timestamp, body))
+		for _, signature := range signatures {
+			if hmac.Equal(expected, []byte(signature)) {
+				return nil
+			}
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+// VerifyRequest reads the body of a webhook request and verifies it with
+// Verify. The body is returned and also put back on r, so it can be read again.
+func VerifyRequest(r *http.Request, secrets []string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := Verify(r.Header.Get(SignatureHeader), body, secrets, tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func parseHeader(header string) (int64, []string, error) {
+	var timestamp int64
+	var signatures []string
+	hasTimestamp := false
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return 0, nil, ErrInvalidHeader
+		}
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, nil, ErrInvalidHeader
+			}
+			timestamp, hasTimestamp = t, true
+		case signatureScheme:
+			signatures = append(signatures, value)
+		}
+	}
+	if !hasTimestamp {
+		return 0, nil, ErrInvalidHeader
+	}
+	if len(signatures) == 0 {
+		return 0, nil, ErrNoSignature
+	}
+	return timestamp, signatures, nil
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vector is one case of testdata/vectors.json. Integrators porting the
+// verification to another language can run the same cases.
+type vector struct {
+	Name             string   `json:"name"`
+	Secrets          []string `json:"secrets"`
+	Body             string   `json:"body"`
+	Header           string   `json:"header"`
+	Now              int64    `json:"now"`
+	ToleranceSeconds int      `json:"tolerance_seconds"`
+	Error            string   `json:"error"`
+}
+
+var vectorErrors = map[string]error{
+	"":                   nil,
+	"invalid_header":     ErrInvalidHeader,
+	"no_signature":       ErrNoSignature,
+	"timestamp_expired":  ErrTimestampExpired,
+	"signature_mismatch": ErrSignatureMismatch,
+}
+
+func loadVectors(t *testing.T) []vector {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func TestVerifyVectors(t *testing.T) {
+	for _, v := range loadVectors(t) {
+		t.Run(v.Name, func(t *testing.T) {
+			expected, ok := vectorErrors[v.Error]
+			if !ok {
+				t.Fatalf("unknown error %q", v.Error)
+			}
+			err := VerifyAt(v.Header, []byte(v.Body), v.Secrets, time.Duration(v.ToleranceSeconds)*time.Second, time.Unix(v.Now, 0))
+			if expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestHeaderMatchesVectors(t *testing.T) {
+	v := loadVectors(t)[0]
+	assert.Equal(t, v.Header, Header(1700000000, []byte(v.Body), v.Secrets...))
+}
+
+func TestHeaderSignsWithEverySecret(t *testing.T) {
+	body := []byte(`{"event":"permission.revoked"}`)
+	header := Header(1700000000, body, "new-secret", "old-secret")
+
+	assert.Equal(t, 2, strings.Count(header, "v1="))
+	now := time.Unix(1700000000, 0)
+	assert.NoError(t, VerifyAt(header, body, []string{"new-secret"}, DefaultTolerance, now))
+	assert.NoError(t, VerifyAt(header, body, []string{"old-secret"}, DefaultTolerance, now))
+	assert.ErrorIs(t, VerifyAt(header, body, []string{"other-secret"}, DefaultTolerance, now), ErrSignatureMismatch)
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := `{"event":"kyc.status.changed"}`
+	req := httptest.NewRequest("POST", "/hooks", strings.NewReader(body))
+	req.Header.Set(SignatureHeader, Header(time.Now().Unix(), []byte(body), "secret"))
+
+	read, err := VerifyRequest(req, []string{"secret"}, DefaultTolerance)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(read))
+
+	again, err := io.ReadAll(req.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(again))
+}